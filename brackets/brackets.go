@@ -0,0 +1,111 @@
+// Package brackets finds the pairs of brackets in Go code. Brackets in
+// strings, runes and comments are ignored.
+package brackets
+
+import (
+	"go/scanner"
+	"go/token"
+	"sort"
+)
+
+// Pair is an opening bracket and its closing bracket, given as byte offsets.
+type Pair struct {
+	Open  int
+	Close int
+}
+
+// Brackets are the brackets of some code.
+type Brackets struct {
+	// Pairs are sorted by the opening bracket.
+	Pairs []Pair
+	// Unbalanced are the offsets of brackets that have no partner, e.g. an
+	// opening bracket that is never closed or a closing bracket that does not
+	// match the last open bracket. They are sorted.
+	Unbalanced []int
+	partner    map[int]int
+}
+
+// Scan finds the brackets in code. The code does not have to be valid Go.
+func Scan(code string) *Brackets {
+	src := []byte(code)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	b := &Brackets{partner: map[int]int{}}
+	type open struct {
+		offset int
+		close  token.Token
+	}
+	var stack []open
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		offset := file.Offset(pos)
+		switch tok {
+		case token.LPAREN:
+			stack = append(stack, open{offset, token.RPAREN})
+		case token.LBRACK:
+			stack = append(stack, open{offset, token.RBRACK})
+		case token.LBRACE:
+			stack = append(stack, open{offset, token.RBRACE})
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if len(stack) == 0 || stack[len(stack)-1].close != tok {
+				b.Unbalanced = append(b.Unbalanced, offset)
+				continue
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			b.Pairs = append(b.Pairs, Pair{Open: top.offset, Close: offset})
+			b.partner[top.offset] = offset
+			b.partner[offset] = top.offset
+		}
+	}
+	for _, o := range stack {
+		b.Unbalanced = append(b.Unbalanced, o.offset)
+	}
+	sort.Ints(b.Unbalanced)
+	sort.Slice(b.Pairs, func(i, j int) bool {
+		return b.Pairs[i].Open < b.Pairs[j].Open
+	})
+	return b
+}
+
+// Partner returns the offset of the bracket that matches the bracket at
+// offset. It returns false if there is no bracket with a partner at offset.
+func (b *Brackets) Partner(offset int) (int, bool) {
+	p, ok := b.partner[offset]
+	return p, ok
+}
+
+// AtCaret returns the bracket next to the caret and its partner. The bracket
+// after the caret is preferred over the one before it. It returns false if
+// there is no bracket with a partner next to the caret.
+func (b *Brackets) AtCaret(caret int) (bracket, partner int, ok bool) {
+	if p, ok := b.partner[caret]; ok {
+		return caret, p, true
+	}
+	if p, ok := b.partner[caret-1]; ok {
+		return caret - 1, p, true
+	}
+	return 0, 0, false
+}
+
+// Enclosing returns the innermost pair of brackets around offset.
+func (b *Brackets) Enclosing(offset int) (Pair, bool) {
+	var inner Pair
+	found := false
+	for _, p := range b.Pairs {
+		if p.Open >= offset {
+			break
+		}
+		if offset <= p.Close {
+			inner = p
+			found = true
+		}
+	}
+	return inner, found
+}
@@ -0,0 +1,106 @@
+package brackets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		pairs      []Pair
+		unbalanced []int
+	}{
+		{
+			name:  "nested",
+			code:  "f(a[1], {})",
+			pairs: []Pair{{1, 10}, {3, 5}, {8, 9}},
+		},
+		{
+			name:  "strings, runes and comments are ignored",
+			code:  "f(\"(\", ')', `[`) // {\n/* } */",
+			pairs: []Pair{{1, 15}},
+		},
+		{
+			name:       "unclosed",
+			code:       "func f() {\n\tg(\n}",
+			pairs:      []Pair{{6, 7}},
+			unbalanced: []int{9, 13, 15},
+		},
+		{
+			name:       "wrong closing bracket",
+			code:       "(a]",
+			unbalanced: []int{0, 2},
+		},
+		{
+			name:       "closing without opening",
+			code:       "a) (b)",
+			pairs:      []Pair{{3, 5}},
+			unbalanced: []int{1},
+		},
+		{
+			name:       "unterminated string",
+			code:       "f(\"abc)",
+			unbalanced: []int{1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := Scan(test.code)
+			if !reflect.DeepEqual(b.Pairs, test.pairs) {
+				t.Errorf("want pairs %v but have %v", test.pairs, b.Pairs)
+			}
+			if !reflect.DeepEqual(b.Unbalanced, test.unbalanced) {
+				t.Errorf("want unbalanced %v but have %v", test.unbalanced, b.Unbalanced)
+			}
+		})
+	}
+}
+
+func TestAtCaret(t *testing.T) {
+	b := Scan("f(x) {}")
+	tests := []struct {
+		caret            int
+		bracket, partner int
+		ok               bool
+	}{
+		{0, 0, 0, false},
+		{1, 1, 3, true},
+		{2, 1, 3, true},
+		{3, 3, 1, true},
+		{4, 3, 1, true},
+		// The bracket after the caret is preferred.
+		{6, 6, 5, true},
+		{7, 6, 5, true},
+	}
+	for _, test := range tests {
+		bracket, partner, ok := b.AtCaret(test.caret)
+		if bracket != test.bracket || partner != test.partner || ok != test.ok {
+			t.Errorf("caret %d: want %d %d %v but have %d %d %v", test.caret,
+				test.bracket, test.partner, test.ok, bracket, partner, ok)
+		}
+	}
+	if _, _, ok := Scan("(]").AtCaret(1); ok {
+		t.Error("unbalanced brackets have no partner")
+	}
+}
+
+func TestEnclosing(t *testing.T) {
+	b := Scan("{ f(x) [] }")
+	for offset, want := range map[int]Pair{
+		1:  {0, 10},
+		4:  {3, 5},
+		5:  {3, 5},
+		6:  {0, 10},
+		8:  {7, 8},
+		10: {0, 10},
+	} {
+		if p, ok := b.Enclosing(offset); !ok || p != want {
+			t.Errorf("offset %d: want %v but have %v %v", offset, want, p, ok)
+		}
+	}
+	if p, ok := b.Enclosing(0); ok {
+		t.Errorf("nothing encloses the start but have %v", p)
+	}
+}
@@ -0,0 +1,271 @@
+// Package check finds the problems in a Go package without building it. It
+// parses and type-checks the source code, so it is fast enough to run while
+// the user types.
+package check
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gonutz/gool/paths"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	// Warning is a problem that does not keep the checker from understanding
+	// the code, e.g. an unused variable. The compiler still rejects most
+	// of them.
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Problem is an error or warning in a file. Line and Column start at 1, the
+// column counts bytes.
+type Problem struct {
+	Path     string
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", filepath.Base(p.Path), p.Line, p.Column, p.Message)
+}
+
+// Checker checks the packages in a folder. It remembers the imported packages
+// between checks, so only the first check of a program has to parse the
+// imported source code. A Checker must not be used concurrently.
+type Checker struct {
+	imports *sourceImporter
+	// moduleDir and importTime are used to notice changes to the imported
+	// packages of the module.
+	moduleDir  string
+	importTime time.Time
+}
+
+// New returns a checker without any imported packages.
+func New() *Checker {
+	return &Checker{}
+}
+
+// Check type-checks the package in the folder dir. Files that are open in the
+// editor can be given in overlay, which maps their paths to the unsaved text.
+// Test files are ignored. The problems are sorted by file and position.
+func (c *Checker) Check(dir string, overlay map[string]string) ([]Problem, error) {
+	ctxt := build.Default
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		if text, ok := overlayText(overlay, path); ok {
+			return io.NopCloser(strings.NewReader(text)), nil
+		}
+		return os.Open(path)
+	}
+
+	paths, err := goFiles(&ctxt, dir, overlay)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	var (
+		files    []*ast.File
+		problems []Problem
+	)
+	for _, path := range paths {
+		code, ok := overlayText(overlay, path)
+		if !ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			code = string(data)
+		}
+		f, err := parser.ParseFile(fset, path, code, parser.AllErrors|parser.SkipObjectResolution)
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			for _, e := range list {
+				problems = append(problems, problemAt(e.Pos, Error, e.Msg))
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(problems) > 0 {
+		// Type errors in code that does not parse are mostly noise.
+		sortProblems(problems)
+		return problems, nil
+	}
+
+	c.refreshImports(dir)
+	conf := types.Config{
+		Importer: importerAt{c.imports, dir},
+		Error: func(err error) {
+			if e, ok := err.(types.Error); ok {
+				severity := Error
+				if e.Soft {
+					severity = Warning
+				}
+				problems = append(problems, problemAt(fset.Position(e.Pos), severity, e.Msg))
+			}
+		},
+	}
+	conf.Check(files[0].Name.Name, fset, files, nil)
+	sortProblems(problems)
+	return problems, nil
+}
+
+// refreshImports forgets the imported packages if some of the module's files
+// have changed since they were loaded. The standard library is assumed to
+// never change. Changes to the checked folder itself do not matter, it is
+// not imported.
+func (c *Checker) refreshImports(dir string) {
+	moduleDir := findModule(dir)
+	if c.imports != nil && moduleDir == c.moduleDir &&
+		!changedSince(moduleDir, dir, c.importTime) {
+		return
+	}
+	c.imports = newSourceImporter(moduleDir)
+	c.moduleDir = moduleDir
+	c.importTime = time.Now()
+}
+
+// importerAt resolves imports relative to a folder, which is needed for
+// packages in modules.
+type importerAt struct {
+	types.ImporterFrom
+	dir string
+}
+
+func (i importerAt) Import(path string) (*types.Package, error) {
+	return i.ImportFrom(path, i.dir, 0)
+}
+
+// goFiles returns the paths of the Go files in dir that belong to the package
+// for the current platform.
+func goFiles(ctxt *build.Context, dir string, overlay map[string]string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			names[e.Name()] = true
+		}
+	}
+	// Files that were not saved yet only exist in the overlay.
+	for path := range overlay {
+		if paths.Same(filepath.Dir(path), dir) {
+			names[filepath.Base(path)] = true
+		}
+	}
+
+	var paths []string
+	for name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := ctxt.MatchFile(dir, name); err == nil && !ok {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// findModule returns the folder of the go.mod file that dir belongs to, or
+// dir if there is none.
+func findModule(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// changedSince reports whether a Go file in root, but outside of skip, was
+// modified after t.
+func changedSince(root, skip string, t time.Time) bool {
+	errChanged := errors.New("changed")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (paths.Same(path, skip) || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".go") || d.Name() == "go.mod" {
+			if info, err := d.Info(); err == nil && info.ModTime().After(t) {
+				return errChanged
+			}
+		}
+		return nil
+	})
+	return err == errChanged
+}
+
+func overlayText(overlay map[string]string, path string) (string, bool) {
+	for p, text := range overlay {
+		if paths.Same(p, path) {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func problemAt(pos token.Position, severity Severity, message string) Problem {
+	return Problem{
+		Path:     pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: severity,
+		Message:  message,
+	}
+}
+
+func sortProblems(list []Problem) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
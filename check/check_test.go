@@ -0,0 +1,148 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates the files, given as path and content pairs, in dir.
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for i := 0; i < len(files); i += 2 {
+		path := filepath.Join(dir, filepath.FromSlash(files[i]))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(files[i+1]), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// messages returns the problems in a short form for comparison.
+func messages(list []Problem) string {
+	var s []string
+	for _, p := range list {
+		s = append(s, p.Severity.String()+" "+p.String())
+	}
+	return strings.Join(s, "\n")
+}
+
+func checkDir(t *testing.T, c *Checker, dir string, overlay map[string]string) string {
+	t.Helper()
+	list, err := c.Check(dir, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages(list)
+}
+
+func TestProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "no problems",
+			code: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1)\n}\n",
+			want: "",
+		},
+		{
+			name: "type error",
+			code: "package main\n\nfunc main() {\n\tvar s string = 1\n\t_ = s\n}\n",
+			want: "error main.go:4:17: cannot use 1 (untyped int constant) as string value in variable declaration",
+		},
+		{
+			name: "undefined name",
+			code: "package main\n\nfunc main() {\n\tprintln(x)\n}\n",
+			want: "error main.go:4:10: undefined: x",
+		},
+		{
+			name: "unused variable is a warning",
+			code: "package main\n\nfunc main() {\n\tx := 1\n}\n",
+			want: "warning main.go:4:2: declared and not used: x",
+		},
+		{
+			name: "syntax errors hide type errors",
+			code: "package main\n\nfunc main() {\n\tx := 1\n\tif {\n\t}\n}\n",
+			want: "error main.go:5:5: missing condition in if statement",
+		},
+	}
+	c := New()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, "main.go", test.code)
+			if got := checkDir(t, c, dir, nil); got != test.want {
+				t.Errorf("want\n%s\nbut have\n%s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPackageFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"main.go", "package main\n\nfunc main() {\n\thelp()\n}\n",
+		"help.go", "package main\n\nfunc help() {}\n",
+		"main_test.go", "package main\n\nfunc broken() { x }\n",
+		"ignored.go", "//go:build ignore\n\npackage main\n\nfunc main() {}\n",
+		"sub/other.go", "package other\n\nvar x int = \"\"\n",
+	)
+	if got := checkDir(t, New(), dir, nil); got != "" {
+		t.Errorf("only the package's own files must be checked, have\n%s", got)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	overlay := map[string]string{
+		filepath.Join(dir, "main.go"): "package main\n\nfunc main() {\n\tvar n int = name()\n\t_ = n\n}\n",
+		// Files that were never saved are part of the package, too.
+		filepath.Join(dir, "new.go"): "package main\n\nfunc name() string { return \"\" }\n",
+	}
+	want := "error main.go:4:14: cannot use name() (value of type string) as int value in variable declaration"
+	if got := checkDir(t, New(), dir, overlay); got != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, got)
+	}
+}
+
+func TestModuleImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"go.mod", "module example.com/app\n\ngo 1.19\n",
+		"main.go", "package main\n\nimport \"example.com/app/lib\"\n\nfunc main() {\n\tlib.Hello()\n}\n",
+		"lib/lib.go", "package lib\n\nfunc Hello() {}\n",
+	)
+	c := New()
+	if got := checkDir(t, c, dir, nil); got != "" {
+		t.Fatalf("the module's packages must be found, have\n%s", got)
+	}
+
+	// Changing an imported package makes the checker load it again.
+	lib := filepath.Join(dir, "lib", "lib.go")
+	writeFiles(t, dir, "lib/lib.go", "package lib\n\nfunc Goodbye() {}\n")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(lib, later, later); err != nil {
+		t.Fatal(err)
+	}
+	want := "error main.go:6:6: undefined: lib.Hello"
+	if got := checkDir(t, c, dir, nil); got != want {
+		t.Errorf("want\n%s\nbut have\n%s", want, got)
+	}
+}
+
+func TestEmptyFolder(t *testing.T) {
+	list, err := New().Check(t.TempDir(), nil)
+	if err != nil || len(list) != 0 {
+		t.Errorf("want no problems but have %v, %v", list, err)
+	}
+	if _, err := New().Check(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("error expected for a missing folder")
+	}
+}
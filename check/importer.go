@@ -0,0 +1,87 @@
+package check
+
+import (
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sourceImporter loads imported packages from their source code. Only the
+// declarations are checked, function bodies are skipped.
+//
+// The source importer of the standard library cannot find the packages of a
+// module, go/build runs the go command in the current folder instead of the
+// module's folder.
+type sourceImporter struct {
+	ctxt build.Context
+	fset *token.FileSet
+	// packages are the imported packages by path. It holds nil for packages
+	// that are being imported, which finds import cycles.
+	packages map[string]*types.Package
+}
+
+func newSourceImporter(moduleDir string) *sourceImporter {
+	ctxt := build.Default
+	ctxt.Dir = moduleDir
+	// Without cgo, go/build picks the pure Go files of packages like net.
+	ctxt.CgoEnabled = false
+	if ctxt.GOROOT == "" {
+		// Programs that were built with -trimpath do not know their GOROOT.
+		if out, err := exec.Command("go", "env", "GOROOT").Output(); err == nil {
+			ctxt.GOROOT = strings.TrimSpace(string(out))
+		}
+	}
+	return &sourceImporter{
+		ctxt:     ctxt,
+		fset:     token.NewFileSet(),
+		packages: map[string]*types.Package{},
+	}
+}
+
+func (s *sourceImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, s.ctxt.Dir, 0)
+}
+
+func (s *sourceImporter) ImportFrom(path, dir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if p, ok := s.packages[path]; ok {
+		if p == nil {
+			return nil, errors.New("import cycle through package " + path)
+		}
+		return p, nil
+	}
+
+	bp, err := s.ctxt.Import(path, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(s.fset, filepath.Join(bp.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	s.packages[path] = nil
+	conf := types.Config{
+		Importer:         importerAt{s, bp.Dir},
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		// Problems in imported packages are not the user's concern, the
+		// package is usable anyway.
+		Error: func(error) {},
+	}
+	p, _ := conf.Check(bp.ImportPath, s.fset, files, nil)
+	s.packages[path] = p
+	return p, nil
+}
@@ -0,0 +1,143 @@
+// Package codefmt formats Go code like gofmt or goimports and describes why
+// code could not be formatted.
+package codefmt
+
+import (
+	"bytes"
+	"errors"
+	"go/format"
+	"go/scanner"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNoGoimports is returned if imports should be fixed but the goimports
+// program is not installed.
+var ErrNoGoimports = errors.New(
+	"goimports was not found, install it with: " +
+		"go install golang.org/x/tools/cmd/goimports@latest",
+)
+
+// Diagnostic is a problem in the code. Line and Column are counted from 1.
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error lists the reasons why code could not be formatted.
+type Error struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *Error) Error() string {
+	var lines []string
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d Diagnostic) String() string {
+	return strconv.Itoa(d.Line) + ":" + strconv.Itoa(d.Column) + ": " + d.Message
+}
+
+// Source formats the code like gofmt. Code that does not parse yields an
+// *Error.
+func Source(code string) (string, error) {
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return "", toError(err)
+	}
+	return string(formatted), nil
+}
+
+// Imports formats the code like goimports, which also adds missing imports and
+// removes unused ones. dir is the folder of the file, it is used to find the
+// packages of its module.
+func Imports(code, dir string) (string, error) {
+	// Parse errors are reported like for Source, goimports would report them
+	// with a file name that does not exist.
+	if _, err := Source(code); err != nil {
+		return "", err
+	}
+
+	path, err := exec.LookPath("goimports")
+	if err != nil {
+		return "", ErrNoGoimports
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "-srcdir", dir)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(code)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return "", errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+func toError(err error) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return err
+	}
+	e := &Error{}
+	for _, item := range list {
+		e.Diagnostics = append(e.Diagnostics, Diagnostic{
+			Line:    item.Pos.Line,
+			Column:  item.Pos.Column,
+			Message: item.Msg,
+		})
+	}
+	return e
+}
+
+// MapOffset returns the position in formatted that corresponds to pos in code.
+// Formatting only changes white space, so the position is found by counting
+// the other characters before it. White space right before pos is kept as far
+// as possible, e.g. a caret in the indentation of a line stays in that line.
+func MapOffset(code, formatted string, pos int) int {
+	if pos > len(code) {
+		pos = len(code)
+	}
+
+	count, gapStart := 0, 0
+	for i, r := range code[:pos] {
+		if !unicode.IsSpace(r) {
+			count++
+			gapStart = i + utf8.RuneLen(r)
+		}
+	}
+	newlines := strings.Count(code[gapStart:pos], "\n")
+	indented := pos > gapStart && code[pos-1] != '\n'
+
+	i := 0
+	for i < len(formatted) && count > 0 {
+		r, size := utf8.DecodeRuneInString(formatted[i:])
+		if !unicode.IsSpace(r) {
+			count--
+		}
+		i += size
+	}
+	for i < len(formatted) {
+		c := formatted[i]
+		if c == '\n' {
+			if newlines == 0 {
+				break
+			}
+			newlines--
+		} else if c != ' ' && c != '\t' && c != '\r' || newlines == 0 && !indented {
+			break
+		}
+		i++
+	}
+	return i
+}
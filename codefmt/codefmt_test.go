@@ -0,0 +1,74 @@
+package codefmt
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	formatted, err := Source("package main\nfunc main(){\nx:=1\n_=x}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n"
+	if formatted != want {
+		t.Errorf("want\n%q\nbut have\n%q", want, formatted)
+	}
+}
+
+func TestParseErrorsAreDiagnostics(t *testing.T) {
+	_, err := Source("package main\n\nfunc main() {\n\tx := \n}\n")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("want *Error but have %v", err)
+	}
+	if len(e.Diagnostics) == 0 || e.Diagnostics[0].Line != 5 {
+		t.Errorf("want a diagnostic in line 5 but have %v", e.Diagnostics)
+	}
+	if !strings.HasPrefix(e.Error(), "5:1: ") {
+		t.Errorf("unexpected message %q", e.Error())
+	}
+}
+
+func TestImports(t *testing.T) {
+	if _, err := exec.LookPath("goimports"); err != nil {
+		if _, err := Imports("package main\n", t.TempDir()); err != ErrNoGoimports {
+			t.Errorf("want ErrNoGoimports but have %v", err)
+		}
+		t.Skip("goimports is not installed")
+	}
+	formatted, err := Imports("package main\nimport \"os\"\nfunc main(){fmt.Println()}", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(formatted, `"fmt"`) || strings.Contains(formatted, `"os"`) {
+		t.Errorf("imports were not fixed:\n%s", formatted)
+	}
+}
+
+func TestMapOffset(t *testing.T) {
+	// | marks the caret.
+	tests := []struct{ code, formatted string }{
+		{"x:=|1", "x :=| 1"},
+		{"x :=   |1", "x := |1"},
+		{"x := 1|", "x := 1|"},
+		{"x := 1   |\ny", "x := 1|\ny"},
+		{"a\n|  b", "a\n|\tb"},
+		{"a\n  |b", "a\n\t|b"},
+		{"a\n\n\n\n|b", "a\n\n|b"},
+		{"f(ä,|ö)", "f(ä,| ö)"},
+		{"|x", "|x"},
+		{"x\n|", "x\n|"},
+	}
+	for _, test := range tests {
+		pos := strings.Index(test.code, "|")
+		code := strings.Replace(test.code, "|", "", 1)
+		want := strings.Index(test.formatted, "|")
+		formatted := strings.Replace(test.formatted, "|", "", 1)
+		if got := MapOffset(code, formatted, pos); got != want {
+			t.Errorf("%q -> %q: want %d but have %d", test.code, test.formatted, want, got)
+		}
+	}
+}
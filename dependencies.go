@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/gonutz/gool/deps"
+	"github.com/gonutz/w32/v3"
+)
+
+const (
+	dependencyAddID = 100 + iota
+	dependencyRemoveID
+	dependencyUpgradeID
+	dependencyCheckID
+)
+
+// dependencyJobMessage is posted to the dependency window when a job is done.
+const dependencyJobMessage = w32.WM_USER
+
+// dependencyJobTimeout limits how long a go command or the update check may
+// take.
+const dependencyJobTimeout = 5 * time.Minute
+
+// dependencyWindow is a tool window that shows the requirements of a project's
+// go.mod file and lets the user add, remove and upgrade them.
+type dependencyWindow struct {
+	window        w32.HWND
+	list          w32.HWND
+	pathEdit      w32.HWND
+	versionEdit   w32.HWND
+	addButton     w32.HWND
+	removeButton  w32.HWND
+	upgradeButton w32.HWND
+	checkButton   w32.HWND
+
+	projectPath  string
+	env          []string
+	requirements []deps.Requirement
+	updates      map[string]string
+
+	// cancelJob is non-nil while a job runs in the background. The job
+	// sends its result to jobDone.
+	cancelJob context.CancelFunc
+	jobDone   chan jobResult
+}
+
+type jobResult struct {
+	err error
+	// then is called on the UI thread if err is nil.
+	then func()
+}
+
+var (
+	dependencyWindowClass w32.ATOM
+	openDependencyWindow  *dependencyWindow
+)
+
+// showDependencies opens the dependency window for the project, or re-uses it
+// if it is already open. env is the environment for the go command.
+func showDependencies(owner w32.HWND, font w32.HFONT, projectPath string, env []string) error {
+	d := openDependencyWindow
+	if d == nil {
+		var err error
+		d, err = newDependencyWindow(owner, font)
+		if err != nil {
+			return err
+		}
+		openDependencyWindow = d
+	}
+
+	d.cancel()
+	d.projectPath = projectPath
+	d.env = env
+	d.updates = nil
+	w32.SetWindowText(
+		d.window,
+		w32.String("Abhängigkeiten - "+filepath.Base(projectPath)),
+	)
+	d.reload()
+	w32.ShowWindow(d.window, w32.SW_SHOWNORMAL)
+	w32.SetForegroundWindow(d.window)
+	return nil
+}
+
+func newDependencyWindow(owner w32.HWND, font w32.HFONT) (*dependencyWindow, error) {
+	if dependencyWindowClass == 0 {
+		cursor, err := w32.LoadCursor(0, w32.MakeIntResource(w32.IDC_ARROW))
+		if err != nil {
+			return nil, err
+		}
+		background, err := w32.GetSysColorBrush(w32.COLOR_BTNFACE)
+		if err != nil {
+			return nil, err
+		}
+		dependencyWindowClass, err = w32.RegisterClassEx(&w32.WNDCLASSEX{
+			ClassName:  w32.String("gool_dependency_window_class"),
+			Cursor:     cursor,
+			Background: background,
+			WndProc: w32.NewWindowProcedure(
+				func(window w32.HWND, message uint32, w, l uintptr) uintptr {
+					if d := openDependencyWindow; d != nil && d.window == window {
+						return d.handleMessage(message, w, l)
+					}
+					return w32.DefWindowProc(window, message, w, l)
+				},
+			),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d := &dependencyWindow{}
+
+	var err error
+	d.window, err = w32.CreateWindowEx(
+		0,
+		w32.StringAtom(dependencyWindowClass),
+		w32.String("Abhängigkeiten"),
+		w32.WS_OVERLAPPEDWINDOW,
+		w32.CW_USEDEFAULT, w32.CW_USEDEFAULT, 800, 500,
+		owner, 0, 0, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	create := func(class, text string, style uint32, id uintptr) w32.HWND {
+		var exStyle uint32
+		if class == "EDIT" || class == WC_LISTVIEW {
+			exStyle = w32.WS_EX_CLIENTEDGE
+		}
+		child, e := w32.CreateWindowEx(
+			exStyle,
+			w32.String(class),
+			w32.String(text),
+			w32.WS_VISIBLE|w32.WS_CHILD|w32.WS_TABSTOP|style,
+			0, 0, 10, 10,
+			d.window,
+			w32.HMENU(id), 0, nil,
+		)
+		if e != nil && err == nil {
+			err = e
+		}
+		w32.SendMessage(child, w32.WM_SETFONT, uintptr(font), 1)
+		return child
+	}
+
+	d.list = create(
+		WC_LISTVIEW, "", LVS_REPORT|LVS_SINGLESEL|LVS_SHOWSELALWAYS, 0,
+	)
+	d.pathEdit = create("EDIT", "", w32.ES_AUTOHSCROLL, 0)
+	d.versionEdit = create("EDIT", "", w32.ES_AUTOHSCROLL, 0)
+	d.addButton = create("BUTTON", "Hinzufügen", 0, dependencyAddID)
+	d.removeButton = create("BUTTON", "Entfernen", 0, dependencyRemoveID)
+	d.upgradeButton = create("BUTTON", "Aktualisieren", 0, dependencyUpgradeID)
+	d.checkButton = create("BUTTON", "Nach Updates suchen", 0, dependencyCheckID)
+	if err != nil {
+		w32.DestroyWindow(d.window)
+		return nil, err
+	}
+
+	Edit_SetCueBannerText(d.pathEdit, "Modul, z.B. github.com/gonutz/prototype")
+	Edit_SetCueBannerText(d.versionEdit, "Version (latest)")
+
+	w32.SendMessage(
+		d.list,
+		LVM_SETEXTENDEDLISTVIEWSTYLE,
+		LVS_EX_FULLROWSELECT|LVS_EX_GRIDLINES,
+		LVS_EX_FULLROWSELECT|LVS_EX_GRIDLINES,
+	)
+	ListView_InsertColumn(d.list, 0, "Modul", 350)
+	ListView_InsertColumn(d.list, 1, "Version", 150)
+	ListView_InsertColumn(d.list, 2, "Art", 80)
+	ListView_InsertColumn(d.list, 3, "Neueste Version", 150)
+
+	d.layout()
+	return d, nil
+}
+
+func (d *dependencyWindow) handleMessage(message uint32, w, l uintptr) uintptr {
+	switch message {
+	case w32.WM_SIZE:
+		d.layout()
+		return 0
+	case w32.WM_COMMAND:
+		switch w & 0xFFFF {
+		case dependencyAddID:
+			d.add()
+		case dependencyRemoveID:
+			d.remove()
+		case dependencyUpgradeID:
+			d.upgrade()
+		case dependencyCheckID:
+			if d.cancelJob != nil {
+				d.cancel()
+			} else {
+				d.checkForUpdates()
+			}
+		}
+		return 0
+	case dependencyJobMessage:
+		d.finishJob()
+		return 0
+	case w32.WM_DESTROY:
+		d.cancel()
+		openDependencyWindow = nil
+		return 0
+	default:
+		return w32.DefWindowProc(d.window, message, w, l)
+	}
+}
+
+func (d *dependencyWindow) layout() {
+	r, err := w32.GetClientRect(d.window)
+	if err != nil {
+		return
+	}
+	width, height := int(r.Right-r.Left), int(r.Bottom-r.Top)
+
+	setPos := func(window w32.HWND, x, y, width, height int) {
+		w32.SetWindowPos(
+			window, 0,
+			int32(x), int32(y), int32(width), int32(height),
+			w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER,
+		)
+	}
+
+	const margin, rowH, buttonW, versionW = 10, 28, 180, 160
+	row1y := height - 2*(rowH+margin)
+	row2y := height - rowH - margin
+	pathW := width - 4*margin - versionW - buttonW
+
+	setPos(d.list, margin, margin, width-2*margin, row1y-2*margin)
+	setPos(d.pathEdit, margin, row1y, pathW, rowH)
+	setPos(d.versionEdit, 2*margin+pathW, row1y, versionW, rowH)
+	setPos(d.addButton, width-margin-buttonW, row1y, buttonW, rowH)
+	setPos(d.removeButton, margin, row2y, buttonW, rowH)
+	setPos(d.upgradeButton, 2*margin+buttonW, row2y, buttonW, rowH)
+	setPos(d.checkButton, width-margin-buttonW, row2y, buttonW, rowH)
+}
+
+// reload reads go.mod again and shows its requirements.
+func (d *dependencyWindow) reload() {
+	ListView_DeleteAllItems(d.list)
+	list, err := deps.List(d.projectPath)
+	if err != nil {
+		d.requirements = nil
+		ListView_AddRow(d.list, "go.mod kann nicht gelesen werden: "+err.Error())
+		return
+	}
+	d.requirements = list
+	for _, r := range list {
+		kind := "direkt"
+		if r.Indirect {
+			kind = "indirekt"
+		}
+		ListView_AddRow(d.list, r.Path, r.Version, kind, d.updates[r.Path])
+	}
+}
+
+func (d *dependencyWindow) selected() (deps.Requirement, bool) {
+	i := ListView_GetSelection(d.list)
+	if 0 <= i && i < len(d.requirements) {
+		return d.requirements[i], true
+	}
+	return deps.Requirement{}, false
+}
+
+func (d *dependencyWindow) add() {
+	path, _ := w32.GetWindowText(d.pathEdit)
+	version, _ := w32.GetWindowText(d.versionEdit)
+	if path == "" {
+		d.showError(errors.New("Bitte gib den Pfad des Moduls ein."))
+		return
+	}
+	dir, env := d.projectPath, d.env
+	d.run(func(ctx context.Context) error {
+		return deps.Add(ctx, dir, env, path, version)
+	}, nil)
+	w32.SetWindowText(d.pathEdit, nil)
+	w32.SetWindowText(d.versionEdit, nil)
+}
+
+func (d *dependencyWindow) remove() {
+	if r, ok := d.selected(); ok {
+		dir, env := d.projectPath, d.env
+		d.run(func(ctx context.Context) error {
+			return deps.Remove(ctx, dir, env, r.Path)
+		}, nil)
+	}
+}
+
+func (d *dependencyWindow) upgrade() {
+	if r, ok := d.selected(); ok {
+		dir, env := d.projectPath, d.env
+		d.run(func(ctx context.Context) error {
+			return deps.Upgrade(ctx, dir, env, r.Path)
+		}, nil)
+	}
+}
+
+func (d *dependencyWindow) checkForUpdates() {
+	dir, env, list := d.projectPath, d.env, d.requirements
+	var updates map[string]string
+	d.run(func(ctx context.Context) (err error) {
+		updates, err = deps.Updates(ctx, dir, env, list)
+		return err
+	}, func() {
+		d.updates = updates
+	})
+}
+
+// run executes job, which might call the go command or go online, in the
+// background. When it is done, then is called, if it is not nil and the job
+// succeeded, and the new state of go.mod is shown. Only one job runs at a
+// time, while it runs the update button cancels it.
+func (d *dependencyWindow) run(job func(ctx context.Context) error, then func()) {
+	if d.cancelJob != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyJobTimeout)
+	done := make(chan jobResult, 1)
+	d.cancelJob = cancel
+	d.jobDone = done
+	d.enableButtons(false)
+
+	window := d.window
+	go func() {
+		err := job(ctx)
+		done <- jobResult{err: err, then: then}
+		PostMessage(window, dependencyJobMessage, 0, 0)
+	}()
+}
+
+// finishJob shows the result of the job that started with run.
+func (d *dependencyWindow) finishJob() {
+	var result jobResult
+	select {
+	case result = <-d.jobDone:
+	default:
+		return
+	}
+	d.cancelJob()
+	d.cancelJob = nil
+	d.enableButtons(true)
+
+	if result.err == nil && result.then != nil {
+		result.then()
+	}
+	d.reload()
+	if result.err != nil && !errors.Is(result.err, context.Canceled) {
+		d.showError(result.err)
+	}
+}
+
+// cancel stops the running job, if any. Its result is ignored.
+func (d *dependencyWindow) cancel() {
+	if d.cancelJob == nil {
+		return
+	}
+	d.cancelJob()
+	d.cancelJob = nil
+	d.jobDone = nil
+	d.enableButtons(true)
+}
+
+// enableButtons enables the buttons while no job runs. During a job, the
+// update check button cancels it.
+func (d *dependencyWindow) enableButtons(enable bool) {
+	for _, b := range []w32.HWND{d.addButton, d.removeButton, d.upgradeButton} {
+		w32.EnableWindow(b, enable)
+	}
+	text := "Nach Updates suchen"
+	if !enable {
+		text = "Abbrechen"
+	}
+	w32.SetWindowText(d.checkButton, w32.String(text))
+}
+
+func (d *dependencyWindow) showError(err error) {
+	w32.MessageBox(
+		d.window,
+		w32.String(err.Error()),
+		w32.String("Fehler"),
+		w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+	)
+}
@@ -0,0 +1,221 @@
+// Package deps lists and changes the requirements of a project's go.mod file
+// and checks the configured module proxies for newer versions.
+package deps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// requestTimeout limits each request to a module proxy, so unreachable proxies
+// do not keep the user waiting.
+var requestTimeout = 15 * time.Second
+
+// Requirement is a module required by go.mod.
+type Requirement struct {
+	Path     string
+	Version  string
+	Indirect bool
+}
+
+// List returns the requirements of the go.mod file in dir. Direct requirements
+// come first, each group is sorted by module path.
+func List(dir string) ([]Requirement, error) {
+	path := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Requirement
+	for _, r := range mod.Require {
+		list = append(list, Requirement{
+			Path:     r.Mod.Path,
+			Version:  r.Mod.Version,
+			Indirect: r.Indirect,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Indirect != list[j].Indirect {
+			return !list[i].Indirect
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list, nil
+}
+
+// Add requires the module at the given version, "latest" if version is empty.
+func Add(ctx context.Context, dir string, env []string, path, version string) error {
+	if err := module.CheckPath(path); err != nil {
+		return err
+	}
+	if version == "" {
+		version = "latest"
+	}
+	return goGet(ctx, dir, env, path+"@"+version)
+}
+
+// Remove removes the module and everything that only it needed.
+func Remove(ctx context.Context, dir string, env []string, path string) error {
+	return goGet(ctx, dir, env, path+"@none")
+}
+
+// Upgrade updates the module to its latest version.
+func Upgrade(ctx context.Context, dir string, env []string, path string) error {
+	return goGet(ctx, dir, env, path+"@latest")
+}
+
+func goGet(ctx context.Context, dir string, env []string, query string) error {
+	get := exec.CommandContext(ctx, "go", "get", query)
+	get.Dir = dir
+	get.Env = env
+	output, err := get.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go get %s failed: %s\r\n%s", query, err, output)
+	}
+	return nil
+}
+
+// Updates returns the latest versions of all requirements that have a newer
+// version than the required one, keyed by module path. Requirements for which
+// the proxies cannot be reached are left out.
+func Updates(ctx context.Context, dir string, env []string, list []Requirement) (map[string]string, error) {
+	proxies, err := goEnv(ctx, dir, env, "GOPROXY")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]string{}
+	var lastErr error
+	for _, r := range list {
+		latest, err := Latest(ctx, proxies, dir, env, r.Path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if semver.Compare(latest, r.Version) > 0 {
+			updates[r.Path] = latest
+		}
+	}
+	if len(updates) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return updates, nil
+}
+
+// Latest asks the module proxies for the latest version of the module. proxies
+// is a GOPROXY list: proxy URLs separated by ',' fall through to the next proxy
+// only if the module was not found, URLs separated by '|' fall through on any
+// error. "direct" asks the go command, which contacts the version control
+// system, and "off" stops the search.
+func Latest(ctx context.Context, proxies, dir string, env []string, path string) (string, error) {
+	escaped, err := module.EscapePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = errors.New("GOPROXY is empty")
+	for proxies != "" {
+		var proxy string
+		fallThroughAlways := false
+		if i := strings.IndexAny(proxies, ",|"); i >= 0 {
+			proxy = proxies[:i]
+			fallThroughAlways = proxies[i] == '|'
+			proxies = proxies[i+1:]
+		} else {
+			proxy, proxies = proxies, ""
+		}
+		proxy = strings.TrimSpace(proxy)
+
+		var version string
+		var notFound bool
+		switch proxy {
+		case "":
+			continue
+		case "off":
+			return "", fmt.Errorf("module lookup disabled by GOPROXY=off")
+		case "direct":
+			version, err = latestDirect(ctx, dir, env, path)
+		default:
+			version, notFound, err = latestFromProxy(ctx, proxy, escaped)
+		}
+		if err == nil {
+			return version, nil
+		}
+		if !notFound && !fallThroughAlways {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+func latestFromProxy(ctx context.Context, proxy, escapedPath string) (version string, notFound bool, err error) {
+	url := strings.TrimSuffix(proxy, "/") + "/" + escapedPath + "/@latest"
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return "", true, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	var info struct{ Version string }
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", false, err
+	}
+	return info.Version, false, nil
+}
+
+func latestDirect(ctx context.Context, dir string, env []string, path string) (string, error) {
+	list := exec.CommandContext(ctx, "go", "list", "-m", "-json", path+"@latest")
+	list.Dir = dir
+	list.Env = append(append([]string{}, env...), "GOPROXY=direct")
+	output, err := list.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list -m %s@latest failed: %s", path, err)
+	}
+	var info struct{ Version string }
+	if err := json.Unmarshal(output, &info); err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+func goEnv(ctx context.Context, dir string, env []string, name string) (string, error) {
+	cmd := exec.CommandContext(ctx, "go", "env", name)
+	cmd.Dir = dir
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go env %s failed: %s", name, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,107 @@
+package deps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxy is a module proxy that answers every request with the given status
+// and, for 200, the version.
+func proxy(t *testing.T, status int, version string, requests *[]string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			*requests = append(*requests, r.URL.Path)
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"Version":"` + version + `","Time":"2024-01-01T00:00:00Z"}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLatest(t *testing.T) {
+	found := proxy(t, http.StatusOK, "v1.2.3", nil)
+	missing := proxy(t, http.StatusNotFound, "", nil)
+	gone := proxy(t, http.StatusGone, "", nil)
+	broken := proxy(t, http.StatusInternalServerError, "", nil)
+
+	tests := []struct {
+		proxies string
+		want    string
+		// err is part of the expected error, the version is ignored then.
+		err string
+	}{
+		{found.URL, "v1.2.3", ""},
+		{found.URL + "/", "v1.2.3", ""},
+		{missing.URL + "," + found.URL, "v1.2.3", ""},
+		{gone.URL + ", " + found.URL, "v1.2.3", ""},
+		{broken.URL + "|" + found.URL, "v1.2.3", ""},
+		{missing.URL + "|" + found.URL, "v1.2.3", ""},
+		{broken.URL + "," + found.URL, "", "500"},
+		{missing.URL, "", "404"},
+		{missing.URL + ",off", "", "GOPROXY=off"},
+		{"off," + found.URL, "", "GOPROXY=off"},
+		{",," + found.URL, "v1.2.3", ""},
+		{"", "", "GOPROXY is empty"},
+	}
+	for _, test := range tests {
+		got, err := Latest(context.Background(), test.proxies, "", nil, "example.com/m")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: want error %q but have %q, %v", test.proxies, test.err, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%q: want %s but have %q, %v", test.proxies, test.want, got, err)
+		}
+	}
+}
+
+func TestLatestEscapesPath(t *testing.T) {
+	var requests []string
+	p := proxy(t, http.StatusOK, "v0.1.0", &requests)
+	if _, err := Latest(context.Background(), p.URL, "", nil, "github.com/User/Repo"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/github.com/!user/!repo/@latest"; len(requests) != 1 || requests[0] != want {
+		t.Errorf("want request %s but have %v", want, requests)
+	}
+	if _, err := Latest(context.Background(), p.URL, "", nil, "not a path"); err == nil {
+		t.Error("error expected for an invalid module path")
+	}
+}
+
+func TestLatestTimeout(t *testing.T) {
+	defer func(old time.Duration) { requestTimeout = old }(requestTimeout)
+	requestTimeout = 50 * time.Millisecond
+
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(unblock)
+	found := proxy(t, http.StatusOK, "v2.0.0", nil)
+
+	start := time.Now()
+	if _, err := Latest(context.Background(), slow.URL, "", nil, "example.com/m"); err == nil {
+		t.Error("error expected for a proxy that does not answer")
+	}
+	got, err := Latest(context.Background(), slow.URL+"|"+found.URL, "", nil, "example.com/m")
+	if err != nil || got != "v2.0.0" {
+		t.Errorf("want the next proxy's version but have %q, %v", got, err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("the requests took %v", d)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gonutz/gool/symbols"
+	"github.com/gonutz/w32/v3"
+)
+
+const (
+	dialogOKID = 200 + iota
+	dialogCancelID
+	dialogListID
+)
+
+var (
+	dialogWindowClass w32.ATOM
+	// openDialogs are the dialogs that are shown, the last one is on top.
+	openDialogs []*dialog
+)
+
+// dialog is a modal window. It disables its owner while it is shown and runs
+// its own message loop until the user confirms or cancels it.
+type dialog struct {
+	window   w32.HWND
+	owner    w32.HWND
+	font     w32.HFONT
+	done     bool
+	accepted bool
+
+	// onCommand handles WM_COMMAND for the controls of the dialog, except
+	// for the OK and Cancel buttons, which finish it.
+	onCommand func(w, l uintptr)
+	// onKey is called for key presses before they are dispatched. It
+	// returns true if it handled the key. Enter and Escape always finish
+	// the dialog.
+	onKey func(msg *w32.MSG) bool
+	// onFinish is called once, before the dialog closes.
+	onFinish func(accepted bool)
+}
+
+// newDialog creates the window of a dialog, centered over the owner. It is
+// shown by run.
+func newDialog(owner w32.HWND, font w32.HFONT, title string, width, height int) (*dialog, error) {
+	if dialogWindowClass == 0 {
+		cursor, _ := w32.LoadCursor(0, w32.MakeIntResource(w32.IDC_ARROW))
+		background, _ := w32.GetSysColorBrush(w32.COLOR_BTNFACE)
+		class, err := w32.RegisterClassEx(&w32.WNDCLASSEX{
+			ClassName:  w32.String("gool_dialog_window_class"),
+			Cursor:     cursor,
+			Background: background,
+			WndProc: w32.NewWindowProcedure(
+				func(window w32.HWND, message uint32, w, l uintptr) uintptr {
+					for _, d := range openDialogs {
+						if d.window == window {
+							return d.handleMessage(message, w, l)
+						}
+					}
+					return w32.DefWindowProc(window, message, w, l)
+				},
+			),
+		})
+		if err != nil {
+			return nil, err
+		}
+		dialogWindowClass = class
+	}
+
+	x, y := w32.CW_USEDEFAULT, w32.CW_USEDEFAULT
+	if r, err := w32.GetWindowRect(owner); err == nil {
+		x = int((r.Left + r.Right - int32(width)) / 2)
+		y = int((r.Top + r.Bottom - int32(height)) / 2)
+	}
+
+	window, err := w32.CreateWindowEx(
+		w32.WS_EX_DLGMODALFRAME,
+		w32.StringAtom(dialogWindowClass),
+		w32.String(title),
+		w32.WS_POPUP|w32.WS_CAPTION|w32.WS_SYSMENU,
+		x, y, width, height,
+		owner, 0, 0, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &dialog{window: window, owner: owner, font: font}, nil
+}
+
+func (d *dialog) handleMessage(message uint32, w, l uintptr) uintptr {
+	switch message {
+	case w32.WM_COMMAND:
+		switch w & 0xFFFF {
+		case dialogOKID:
+			d.finish(true)
+		case dialogCancelID:
+			d.finish(false)
+		default:
+			if d.onCommand != nil {
+				d.onCommand(w, l)
+			}
+		}
+		return 0
+	case w32.WM_CLOSE:
+		d.finish(false)
+		return 0
+	}
+	return w32.DefWindowProc(d.window, message, w, l)
+}
+
+// clientSize returns the width and height of the dialog's client area.
+func (d *dialog) clientSize() (int, int) {
+	r, _ := w32.GetClientRect(d.window)
+	return int(r.Right - r.Left), int(r.Bottom - r.Top)
+}
+
+// create adds a control to the dialog.
+func (d *dialog) create(exStyle uint32, class, text string, style uint32, id uintptr, x, y, w, h int) w32.HWND {
+	child, _ := w32.CreateWindowEx(
+		exStyle,
+		w32.String(class),
+		w32.String(text),
+		w32.WS_VISIBLE|w32.WS_CHILD|style,
+		x, y, w, h,
+		d.window,
+		w32.HMENU(id), 0, nil,
+	)
+	w32.SendMessage(child, w32.WM_SETFONT, uintptr(d.font), 1)
+	return child
+}
+
+// addButtons adds OK and Cancel buttons at the right of the row at y.
+func (d *dialog) addButtons(y, height int) {
+	const margin, buttonW = 10, 110
+	clientW, _ := d.clientSize()
+	d.create(
+		0, "BUTTON", "OK", w32.BS_DEFPUSHBUTTON|w32.WS_TABSTOP, dialogOKID,
+		clientW-2*margin-2*buttonW, y, buttonW, height,
+	)
+	d.create(
+		0, "BUTTON", "Abbrechen", w32.WS_TABSTOP, dialogCancelID,
+		clientW-margin-buttonW, y, buttonW, height,
+	)
+}
+
+// finish ends the dialog. accepted tells whether the user confirmed it.
+func (d *dialog) finish(accepted bool) {
+	if d.done {
+		return
+	}
+	d.done = true
+	d.accepted = accepted
+	if d.onFinish != nil {
+		d.onFinish(accepted)
+	}
+}
+
+// run shows the dialog with the keyboard focus on the given control and
+// returns when it is finished. The dialog window is destroyed afterwards. It
+// returns true if the user confirmed the dialog.
+func (d *dialog) run(focus w32.HWND) bool {
+	openDialogs = append(openDialogs, d)
+	defer func() { openDialogs = openDialogs[:len(openDialogs)-1] }()
+
+	w32.EnableWindow(d.owner, false)
+	w32.ShowWindow(d.window, w32.SW_SHOW)
+	w32.SetFocus(focus)
+
+	for !d.done {
+		var msg w32.MSG
+		ok, err := w32.GetMessage(&msg, 0, 0, 0)
+		if err != nil {
+			d.finish(false)
+			break
+		}
+		if !ok {
+			// Forward WM_QUIT to the main message loop.
+			w32.PostQuitMessage(int(msg.WParam))
+			d.finish(false)
+			break
+		}
+		if msg.Message == w32.WM_KEYDOWN {
+			switch msg.WParam {
+			case w32.VK_RETURN:
+				d.finish(true)
+				continue
+			case w32.VK_ESCAPE:
+				d.finish(false)
+				continue
+			}
+			if d.onKey != nil && d.onKey(&msg) {
+				continue
+			}
+		}
+		w32.TranslateMessage(&msg)
+		w32.DispatchMessage(&msg)
+	}
+
+	// Enable the owner before destroying the dialog, otherwise Windows
+	// activates some other application's window.
+	w32.EnableWindow(d.owner, true)
+	w32.SetForegroundWindow(d.owner)
+	w32.DestroyWindow(d.window)
+
+	return d.accepted
+}
+
+// inputBox shows a modal dialog that asks the user for a line of text. text is
+// the initial content of the text field. The entered text is returned along
+// with false if the user canceled the dialog.
+func inputBox(owner w32.HWND, font w32.HFONT, title, prompt, text string) (string, bool) {
+	const margin, rowH = 10, 28
+
+	d, err := newDialog(owner, font, title, 500, 160)
+	if err != nil {
+		return "", false
+	}
+	clientW, _ := d.clientSize()
+
+	d.create(0, "STATIC", prompt, 0, 0, margin, margin, clientW-2*margin, rowH)
+	edit := d.create(
+		w32.WS_EX_CLIENTEDGE, "EDIT", text, w32.ES_AUTOHSCROLL|w32.WS_TABSTOP, 0,
+		margin, margin+rowH, clientW-2*margin, rowH,
+	)
+	d.addButtons(2*margin+2*rowH, rowH)
+	w32.SendMessage(edit, w32.EM_SETSEL, 0, ^uintptr(0))
+
+	var result string
+	d.onFinish = func(bool) {
+		result, _ = w32.GetWindowText(edit)
+	}
+	ok := d.run(edit)
+	return result, ok
+}
+
+// listPicker describes a modal dialog that lets the user choose an item from
+// a list.
+type listPicker struct {
+	title string
+	// prompt is shown above the list if it is not empty.
+	prompt string
+	// cue is the hint in the text field that filters the list. Pickers
+	// without cue have no text field, but OK and Cancel buttons instead.
+	cue string
+	// items returns the items to show for the text in the filter field. It
+	// gets "" if there is no filter field.
+	items  func(filter string) []string
+	height int
+}
+
+// pickFromList shows the dialog. The index of the chosen item in the last
+// list that items returned is returned along with false if the user canceled
+// the dialog.
+func pickFromList(owner w32.HWND, font w32.HFONT, p listPicker) (int, bool) {
+	const margin, rowH = 10, 28
+
+	d, err := newDialog(owner, font, p.title, 500, p.height)
+	if err != nil {
+		return 0, false
+	}
+	clientW, clientH := d.clientSize()
+
+	top, bottom := margin, clientH-margin
+	if p.prompt != "" {
+		d.create(0, "STATIC", p.prompt, 0, 0, margin, top, clientW-2*margin, rowH)
+		top += rowH
+	}
+	var edit w32.HWND
+	if p.cue != "" {
+		edit = d.create(
+			w32.WS_EX_CLIENTEDGE, "EDIT", "", w32.ES_AUTOHSCROLL|w32.WS_TABSTOP, 0,
+			margin, top, clientW-2*margin, rowH,
+		)
+		Edit_SetCueBannerText(edit, p.cue)
+		top += rowH + margin
+	} else {
+		bottom -= rowH
+		d.addButtons(bottom, rowH)
+		bottom -= margin
+	}
+	list := d.create(
+		w32.WS_EX_CLIENTEDGE, "LISTBOX", "",
+		w32.WS_VSCROLL|w32.WS_TABSTOP|LBS_NOTIFY|LBS_NOINTEGRALHEIGHT, dialogListID,
+		margin, top, clientW-2*margin, bottom-top,
+	)
+
+	var shown []string
+	filter := func() {
+		query := ""
+		if edit != 0 {
+			query, _ = w32.GetWindowText(edit)
+		}
+		shown = p.items(query)
+		ListBox_ResetContent(list)
+		for _, item := range shown {
+			ListBox_AddString(list, item)
+		}
+		ListBox_SetCurSel(list, 0)
+	}
+	filter()
+
+	d.onCommand = func(w, l uintptr) {
+		if edit != 0 && l == uintptr(edit) && (w>>16)&0xFFFF == w32.EN_CHANGE {
+			filter()
+		}
+		if w&0xFFFF == dialogListID && (w>>16)&0xFFFF == LBN_DBLCLK {
+			d.finish(true)
+		}
+	}
+	d.onKey = func(msg *w32.MSG) bool {
+		// Move through the list while typing in the text field.
+		if msg.Hwnd != edit || edit == 0 ||
+			msg.WParam != w32.VK_UP && msg.WParam != w32.VK_DOWN {
+			return false
+		}
+		i := ListBox_GetCurSel(list)
+		if msg.WParam == w32.VK_UP && i > 0 {
+			ListBox_SetCurSel(list, i-1)
+		}
+		if msg.WParam == w32.VK_DOWN && i+1 < ListBox_GetCount(list) {
+			ListBox_SetCurSel(list, i+1)
+		}
+		return true
+	}
+	var result int
+	d.onFinish = func(bool) {
+		result = ListBox_GetCurSel(list)
+	}
+
+	focus := list
+	if edit != 0 {
+		focus = edit
+	}
+	ok := d.run(focus)
+	return result, ok && 0 <= result && result < len(shown)
+}
+
+// pickSymbol shows a modal dialog that lists the given symbols. Typing into the
+// text field filters the list. The chosen symbol is returned along with false
+// if the user canceled the dialog.
+func pickSymbol(owner w32.HWND, font w32.HFONT, all []symbols.Symbol) (symbols.Symbol, bool) {
+	var shown []symbols.Symbol
+	i, ok := pickFromList(owner, font, listPicker{
+		title: "Gehe zu Symbol",
+		cue:   "Name filtern, z.B. bl für Buffer.Len",
+		items: func(filter string) []string {
+			shown = symbols.Filter(all, filter)
+			var items []string
+			for _, s := range shown {
+				items = append(items, fmt.Sprintf(
+					"%s %s   (Zeile %d)", s.Kind, s.Name, s.Line,
+				))
+			}
+			return items
+		},
+		height: 450,
+	})
+	if !ok {
+		return symbols.Symbol{}, false
+	}
+	return shown[i], true
+}
+
+// pickItem shows a modal dialog that lets the user choose one of the items.
+// The index of the chosen item is returned along with false if the user
+// canceled the dialog.
+func pickItem(owner w32.HWND, font w32.HFONT, title, prompt string, items []string) (int, bool) {
+	return pickFromList(owner, font, listPicker{
+		title:  title,
+		prompt: prompt,
+		items:  func(string) []string { return items },
+		height: 400,
+	})
+}
@@ -0,0 +1,200 @@
+// Package fileops creates, saves, renames, duplicates and deletes the files and
+// folders of projects. Deleted files are moved to a trash folder, so they can
+// be restored.
+package fileops
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonutz/gool/paths"
+)
+
+var (
+	ErrInvalidName = errors.New("invalid file name")
+	ErrExists      = errors.New("a file or folder with this name already exists")
+)
+
+// InvalidChars are the characters that Windows does not allow in file names.
+const InvalidChars = `\/:*?"<>|`
+
+// CheckName returns ErrInvalidName if name cannot be used as a file or folder
+// name.
+func CheckName(name string) error {
+	if name == "" || name == "." || name == ".." ||
+		strings.ContainsAny(name, InvalidChars) ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") ||
+		strings.TrimSpace(name) != name {
+		return ErrInvalidName
+	}
+	for _, r := range name {
+		if r < ' ' {
+			return ErrInvalidName
+		}
+	}
+	return nil
+}
+
+// NewFile creates a file in dir and returns its path. Names without extension
+// get ".go". Go files start with the package clause of the other files in dir.
+func NewFile(dir, name string) (string, error) {
+	if err := CheckName(name); err != nil {
+		return "", err
+	}
+	if filepath.Ext(name) == "" {
+		name += ".go"
+	}
+	path := filepath.Join(dir, name)
+	if exists(path) {
+		return "", ErrExists
+	}
+	var content string
+	if strings.HasSuffix(strings.ToLower(name), ".go") {
+		content = "package " + PackageName(dir) + "\n"
+	}
+	return path, os.WriteFile(path, []byte(content), 0666)
+}
+
+// PackageName returns the name of the package in dir or "main" if there are
+// no Go files.
+func PackageName(dir string) string {
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(
+			token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly,
+		)
+		if err == nil {
+			return f.Name.Name
+		}
+	}
+	return "main"
+}
+
+// Rename gives the file or folder at path a new name in the same folder and
+// returns the new path.
+func Rename(path, name string) (string, error) {
+	if err := CheckName(name); err != nil {
+		return "", err
+	}
+	newPath := filepath.Join(filepath.Dir(path), name)
+	// Changing only the case is fine, Windows file names ignore it.
+	if exists(newPath) && !paths.Same(newPath, path) {
+		return "", ErrExists
+	}
+	return newPath, os.Rename(path, newPath)
+}
+
+// Duplicate copies the file or folder at path next to it, e.g. main.go to
+// main_kopie.go, and returns the path of the copy.
+func Duplicate(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	ext := ""
+	if !info.IsDir() {
+		ext = filepath.Ext(path)
+	}
+	base := strings.TrimSuffix(path, ext) + "_kopie"
+	copyPath := freePath(base, ext)
+
+	if !info.IsDir() {
+		return copyPath, copyFile(path, copyPath)
+	}
+	return copyPath, filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(copyPath, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		return copyFile(p, target)
+	})
+}
+
+// Delete moves the file or folder at path into a new folder in trash, which
+// is named after the current time. It returns the new path.
+func Delete(path, trash string) (string, error) {
+	dir := freePath(filepath.Join(trash, time.Now().Format("2006-01-02_15-04-05")), "")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	return target, nil
+}
+
+// WriteAtomic writes data to a temporary file next to path and then renames it
+// to path. This way path never contains half-written data, even if gool
+// crashes while writing.
+func WriteAtomic(path string, data []byte) error {
+	dir, name := filepath.Split(path)
+	// The dot hides the temporary file in the project tree.
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// freePath returns base+ext or, if that exists, base2+ext, base3+ext and so
+// on.
+func freePath(base, ext string) string {
+	path := base + ext
+	for i := 2; exists(path); i++ {
+		path = base + strconv.Itoa(i) + ext
+	}
+	return path
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,216 @@
+package fileops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCheckName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"main.go":      true,
+		"mein projekt": true,
+		"Übung_1":      true,
+		"":             false,
+		".":            false,
+		"..":           false,
+		"a/b":          false,
+		`a\b`:          false,
+		"what?":        false,
+		"end.":         false,
+		" start":       false,
+		"end ":         false,
+		"tab\there":    false,
+	} {
+		err := CheckName(name)
+		if valid && err != nil || !valid && !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%q: want valid %v but have %v", name, valid, err)
+		}
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "a_test.go"), "package other_test\n")
+	write(t, filepath.Join(dir, "a.go"), "package other\n\nfunc A() {}\n")
+
+	path, err := NewFile(dir, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "b.go") {
+		t.Errorf("names without extension must get .go, have %s", path)
+	}
+	if got := read(t, path); got != "package other\n" {
+		t.Errorf("want the folder's package but have %q", got)
+	}
+
+	path, err = NewFile(dir, "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, path); got != "" {
+		t.Errorf("text files must be empty, have %q", got)
+	}
+
+	if _, err := NewFile(dir, "a.go"); !errors.Is(err, ErrExists) {
+		t.Errorf("want ErrExists but have %v", err)
+	}
+	if _, err := NewFile(dir, "x:y"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("want ErrInvalidName but have %v", err)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	dir := t.TempDir()
+	if name := PackageName(dir); name != "main" {
+		t.Errorf("empty folders have package main, have %s", name)
+	}
+	write(t, filepath.Join(dir, "x.go"), "// Package game is fun.\npackage game\n")
+	if name := PackageName(dir); name != "game" {
+		t.Errorf("want game but have %s", name)
+	}
+}
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.go")
+	write(t, old, "x")
+	write(t, filepath.Join(dir, "taken.go"), "y")
+
+	if _, err := Rename(old, "taken.go"); !errors.Is(err, ErrExists) {
+		t.Errorf("want ErrExists but have %v", err)
+	}
+	if _, err := Rename(old, "bad|name.go"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("want ErrInvalidName but have %v", err)
+	}
+	path, err := Rename(old, "new.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "new.go") || read(t, path) != "x" {
+		t.Errorf("file was not renamed to %s", path)
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Error("the old file still exists")
+	}
+	if _, err := Rename(path, "New.go"); err != nil {
+		t.Errorf("changing the case must work, have %v", err)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	write(t, file, "code")
+
+	first, err := Duplicate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Duplicate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != filepath.Join(dir, "main_kopie.go") || second != filepath.Join(dir, "main_kopie2.go") {
+		t.Errorf("unexpected copies %s and %s", first, second)
+	}
+	if read(t, second) != "code" {
+		t.Error("the content was not copied")
+	}
+
+	project := filepath.Join(dir, "game")
+	write(t, filepath.Join(project, "main.go"), "main")
+	write(t, filepath.Join(project, "assets", "x.png"), "png")
+	copied, err := Duplicate(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != filepath.Join(dir, "game_kopie") {
+		t.Errorf("unexpected folder copy %s", copied)
+	}
+	if read(t, filepath.Join(copied, "main.go")) != "main" ||
+		read(t, filepath.Join(copied, "assets", "x.png")) != "png" {
+		t.Error("the folder was not copied completely")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+	trash := filepath.Join(dir, "trash")
+	file := filepath.Join(dir, "project", "main.go")
+	write(t, file, "code")
+
+	var moved []string
+	for i := 0; i < 2; i++ {
+		write(t, file, "code")
+		path, err := Delete(file, trash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moved = append(moved, path)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("the file still exists")
+	}
+	for _, path := range moved {
+		rel, _ := filepath.Rel(trash, path)
+		if filepath.Base(path) != "main.go" || filepath.Dir(rel) == "." || read(t, path) != "code" {
+			t.Errorf("the file was not moved into a trash folder, have %s", path)
+		}
+	}
+	if moved[0] == moved[1] {
+		t.Error("deleting twice must not overwrite the first file in the trash")
+	}
+
+	if _, err := Delete(filepath.Join(dir, "missing"), trash); err == nil {
+		t.Error("error expected for missing files")
+	}
+}
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := WriteAtomic(path, []byte("package main\n")); err != nil {
+		t.Fatal(err)
+	}
+	write(t, filepath.Join(dir, "other.go"), "package other\n")
+	if err := WriteAtomic(path, []byte("package game\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, path); got != "package game\n" {
+		t.Errorf("want the new content but have %q", got)
+	}
+
+	if err := WriteAtomic(filepath.Join(dir, "missing", "x.go"), nil); err == nil {
+		t.Error("writing into a missing folder must fail")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("temporary files must be removed, have %v", names)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/gonutz/gool
 
 go 1.19
 
-require github.com/gonutz/w32/v3 v3.0.0-beta8
+require (
+	github.com/gonutz/w32/v3 v3.0.0-beta8
+	golang.org/x/mod v0.10.0
+)
@@ -0,0 +1,205 @@
+// Package highlight splits Go source code into styled spans for syntax
+// highlighting. A Document keeps the spans of every line along with the
+// scanner state at the start and end of the line, so after an edit only the
+// changed lines and the lines whose state changed are scanned again.
+package highlight
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Plain Kind = iota
+	Keyword
+	String
+	Comment
+	Number
+	Builtin
+)
+
+// Span is a styled part of a line, Start and End are byte offsets into the
+// line. Code between spans is Plain.
+type Span struct {
+	Start int
+	End   int
+	Kind  Kind
+}
+
+// state is what the scanner is in the middle of at the end of a line.
+type state uint8
+
+const (
+	inCode state = iota
+	inBlockComment
+	inRawString
+)
+
+type line struct {
+	text  string
+	start state
+	end   state
+	spans []Span
+}
+
+// Document is the highlighted code. The zero value is an empty document.
+type Document struct {
+	lines []line
+}
+
+// LineCount returns the number of lines. It is 0 for a new Document and at
+// least 1 after SetText.
+func (d *Document) LineCount() int {
+	return len(d.lines)
+}
+
+// Line returns the text of line i without line break.
+func (d *Document) Line(i int) string {
+	return d.lines[i].text
+}
+
+// Spans returns the styled parts of line i in ascending order.
+func (d *Document) Spans(i int) []Span {
+	return d.lines[i].spans
+}
+
+// SetText replaces the document's code. Lines may end in "\n", "\r\n" or "\r".
+// Only the lines that differ from the old code are scanned again. SetText
+// returns the range of lines [from, to) whose spans may have changed.
+func (d *Document) SetText(code string) (from, to int) {
+	lines := splitLines(code)
+
+	prefix := 0
+	for prefix < len(d.lines) && prefix < len(lines) &&
+		d.lines[prefix].text == lines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(d.lines)-prefix && suffix < len(lines)-prefix &&
+		d.lines[len(d.lines)-1-suffix].text == lines[len(lines)-1-suffix] {
+		suffix++
+	}
+
+	return d.Replace(prefix, len(d.lines)-prefix-suffix, lines[prefix:len(lines)-suffix])
+}
+
+// Replace replaces the lines [first, first+removed) with the given lines and
+// scans them. Following lines are scanned again until their start state is
+// the same as before, e.g. all lines after a newly opened block comment. The
+// range of lines [from, to) whose spans may have changed is returned.
+func (d *Document) Replace(first, removed int, inserted []string) (from, to int) {
+	newLines := make([]line, 0, len(d.lines)-removed+len(inserted))
+	newLines = append(newLines, d.lines[:first]...)
+	for _, text := range inserted {
+		newLines = append(newLines, line{text: text})
+	}
+	newLines = append(newLines, d.lines[first+removed:]...)
+	d.lines = newLines
+
+	s := inCode
+	if first > 0 {
+		s = d.lines[first-1].end
+	}
+	i := first
+	for ; i < len(d.lines); i++ {
+		l := &d.lines[i]
+		if i >= first+len(inserted) && l.start == s {
+			break
+		}
+		l.start = s
+		l.spans, l.end = scanLine(l.text, s)
+		s = l.end
+	}
+	return first, i
+}
+
+func splitLines(code string) []string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	code = strings.ReplaceAll(code, "\r", "\n")
+	return strings.Split(code, "\n")
+}
+
+// scanLine returns the spans of a line that starts in state s and the state at
+// the end of the line.
+func scanLine(text string, s state) ([]Span, state) {
+	var spans []Span
+
+	// Finish a comment or raw string from a previous line.
+	pos := 0
+	if s != inCode {
+		end, kind := "*/", Comment
+		if s == inRawString {
+			end, kind = "`", String
+		}
+		i := strings.Index(text, end)
+		if i == -1 {
+			if text != "" {
+				spans = append(spans, Span{Start: 0, End: len(text), Kind: kind})
+			}
+			return spans, s
+		}
+		pos = i + len(end)
+		spans = append(spans, Span{Start: 0, End: pos, Kind: kind})
+	}
+
+	rest := text[pos:]
+	file := token.NewFileSet().AddFile("", -1, len(rest))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(rest), func(token.Position, string) {}, scanner.ScanComments)
+
+	s = inCode
+	for {
+		p, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		start := pos + file.Offset(p)
+		end := start + len(lit)
+
+		kind := Plain
+		switch {
+		case tok == token.COMMENT:
+			kind = Comment
+			if strings.HasPrefix(lit, "/*") &&
+				(len(lit) < 4 || !strings.HasSuffix(lit, "*/")) {
+				s = inBlockComment
+			}
+		case tok == token.STRING:
+			kind = String
+			if lit[0] == '`' && (len(lit) == 1 || !strings.HasSuffix(lit, "`")) {
+				s = inRawString
+			}
+		case tok == token.CHAR:
+			kind = String
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			kind = Number
+		case tok.IsKeyword():
+			kind = Keyword
+		case tok == token.IDENT && builtins[lit]:
+			kind = Builtin
+		}
+		if kind != Plain {
+			spans = append(spans, Span{Start: start, End: end, Kind: kind})
+		}
+	}
+	return spans, s
+}
+
+// builtins are Go's predeclared identifiers.
+var builtins = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true,
+	"complex64": true, "complex128": true, "error": true, "float32": true,
+	"float64": true, "int": true, "int8": true, "int16": true, "int32": true,
+	"int64": true, "rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+
+	"true": true, "false": true, "iota": true, "nil": true,
+
+	"append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "panic": true,
+	"print": true, "println": true, "real": true, "recover": true,
+}
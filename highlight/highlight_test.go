@@ -0,0 +1,168 @@
+package highlight
+
+import (
+	"reflect"
+	"testing"
+)
+
+// styled returns the text and kind of all spans of line i.
+func styled(d *Document, i int) []string {
+	var list []string
+	for _, s := range d.Spans(i) {
+		list = append(list, kindNames[s.Kind]+":"+d.Line(i)[s.Start:s.End])
+	}
+	return list
+}
+
+var kindNames = map[Kind]string{
+	Plain:   "plain",
+	Keyword: "keyword",
+	String:  "string",
+	Comment: "comment",
+	Number:  "number",
+	Builtin: "builtin",
+}
+
+func checkLine(t *testing.T, d *Document, i int, want ...string) {
+	t.Helper()
+	if got := styled(d, i); !reflect.DeepEqual(got, want) {
+		t.Errorf("line %d: want %q but have %q", i, want, got)
+	}
+}
+
+func checkRange(t *testing.T, from, to, wantFrom, wantTo int) {
+	t.Helper()
+	if from != wantFrom || to != wantTo {
+		t.Errorf("want changed lines [%d, %d) but have [%d, %d)",
+			wantFrom, wantTo, from, to)
+	}
+}
+
+func TestTokenKinds(t *testing.T) {
+	var d Document
+	d.SetText(`package main
+
+func f(s string) int {
+	x := 'a' + 0x1F + 1.5e3 + 2i // comment
+	return len("a\"b") + /* inline */ x
+}`)
+	checkLine(t, &d, 0, "keyword:package")
+	checkLine(t, &d, 1)
+	checkLine(t, &d, 2, "keyword:func", "builtin:string", "builtin:int")
+	checkLine(t, &d, 3,
+		"string:'a'", "number:0x1F", "number:1.5e3", "number:2i",
+		"comment:// comment",
+	)
+	checkLine(t, &d, 4,
+		"keyword:return", "builtin:len", `string:"a\"b"`,
+		"comment:/* inline */",
+	)
+	checkLine(t, &d, 5)
+}
+
+func TestShadowedBuiltinsAreStillHighlighted(t *testing.T) {
+	var d Document
+	d.SetText("var nil, true, myLen = 1, 2, 3")
+	checkLine(t, &d, 0, "keyword:var", "builtin:nil", "builtin:true",
+		"number:1", "number:2", "number:3")
+}
+
+func TestMultiLineComment(t *testing.T) {
+	var d Document
+	from, to := d.SetText("x /* start\nmiddle\nend */ y 1\nz 2")
+	checkRange(t, from, to, 0, 4)
+	checkLine(t, &d, 0, "comment:/* start")
+	checkLine(t, &d, 1, "comment:middle")
+	checkLine(t, &d, 2, "comment:end */", "number:1")
+	checkLine(t, &d, 3, "number:2")
+}
+
+func TestMultiLineRawString(t *testing.T) {
+	var d Document
+	d.SetText("s := `first\n\n// no comment\nlast` + `one line`")
+	checkLine(t, &d, 0, "string:`first")
+	checkLine(t, &d, 1)
+	checkLine(t, &d, 2, "string:// no comment")
+	checkLine(t, &d, 3, "string:last`", "string:`one line`")
+}
+
+func TestUnterminatedCommentAtEndOfLine(t *testing.T) {
+	var d Document
+	d.SetText("/*\n1\n/*/\n2")
+	checkLine(t, &d, 0, "comment:/*")
+	checkLine(t, &d, 1, "comment:1")
+	checkLine(t, &d, 2, "comment:/*/")
+	checkLine(t, &d, 3, "number:2")
+}
+
+func TestLineEndings(t *testing.T) {
+	var d Document
+	d.SetText("1\r\n2\r3\n4")
+	if d.LineCount() != 4 {
+		t.Fatalf("want 4 lines but have %d", d.LineCount())
+	}
+	for i, n := range []string{"1", "2", "3", "4"} {
+		checkLine(t, &d, i, "number:"+n)
+	}
+}
+
+func TestEditingOneLineOnlyScansThatLine(t *testing.T) {
+	var d Document
+	d.SetText("a\nb\nc\nd")
+	from, to := d.SetText("a\nb 1\nc\nd")
+	checkRange(t, from, to, 1, 2)
+	checkLine(t, &d, 1, "number:1")
+}
+
+func TestUnchangedTextScansNothing(t *testing.T) {
+	var d Document
+	d.SetText("a\nb")
+	from, to := d.SetText("a\nb")
+	checkRange(t, from, to, 2, 2)
+}
+
+func TestInsertingLines(t *testing.T) {
+	var d Document
+	d.SetText("a\nd")
+	from, to := d.SetText("a\nb\nc\nd")
+	checkRange(t, from, to, 1, 3)
+	if d.LineCount() != 4 {
+		t.Errorf("want 4 lines but have %d", d.LineCount())
+	}
+}
+
+func TestDeletingLines(t *testing.T) {
+	var d Document
+	d.SetText("a\nb\nc\n1")
+	from, to := d.SetText("a\n1")
+	checkRange(t, from, to, 1, 1)
+	checkLine(t, &d, 1, "number:1")
+}
+
+func TestOpeningCommentRescansFollowingLines(t *testing.T) {
+	var d Document
+	d.SetText("1\n2\n3\n4")
+	from, to := d.SetText("1 /*\n2\n3\n4")
+	checkRange(t, from, to, 0, 4)
+	checkLine(t, &d, 3, "comment:4")
+
+	// Closing the comment again scans up to the end of the comment.
+	d.SetText("1 /*\n2\n3 */\n4")
+	from, to = d.SetText("1 /* */\n2\n3 */\n4")
+	checkRange(t, from, to, 0, 3)
+	checkLine(t, &d, 1, "number:2")
+	checkLine(t, &d, 2, "number:3")
+	checkLine(t, &d, 3, "number:4")
+}
+
+func TestReplace(t *testing.T) {
+	var d Document
+	d.SetText("a\nb\nc")
+	from, to := d.Replace(1, 1, []string{"`x", "y`"})
+	checkRange(t, from, to, 1, 3)
+	checkLine(t, &d, 1, "string:`x")
+	checkLine(t, &d, 2, "string:y`")
+	if d.LineCount() != 4 {
+		t.Errorf("want 4 lines but have %d", d.LineCount())
+	}
+}
@@ -0,0 +1,162 @@
+// Package ignore decides which files the project tree hides. The patterns use
+// the syntax of .gitignore files and come from .goolignore files in the
+// project folders, in addition to the defaults for build outputs.
+package ignore
+
+import (
+	"path"
+	"strings"
+)
+
+// FileName is the name of the files with ignore patterns. The patterns in a
+// folder's file apply to that folder and its sub-folders.
+const FileName = ".goolignore"
+
+// Defaults hides what builds create and files that students do not edit. A
+// .goolignore file can show them again with patterns like "!go.sum".
+const Defaults = `
+*.exe
+*.test
+*.prof
+__debug_bin*
+export/
+go.sum
+*.tmp
+*~
+~$*
+Thumbs.db
+desktop.ini
+`
+
+// Matcher matches paths against the patterns of several ignore files.
+// Matching ignores case, like Windows file names.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	// base is the folder of the ignore file, relative to the root folder.
+	base     []string
+	parts    []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// New returns a matcher without patterns.
+func New() *Matcher {
+	return &Matcher{}
+}
+
+// Add adds the patterns of an ignore file. dir is the folder that the file
+// is in, as a slash-separated path relative to the root folder, "" for the
+// root itself. Patterns that are added later take precedence.
+func (m *Matcher) Add(dir, content string) {
+	var base []string
+	if dir != "" {
+		base = strings.Split(strings.ToLower(dir), "/")
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if p, ok := parsePattern(line); ok {
+			p.base = base
+			m.patterns = append(m.patterns, p)
+		}
+	}
+}
+
+func parsePattern(line string) (pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	var p pattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// Patterns with a slash are relative to the ignore file's folder, others
+	// match names at any depth.
+	p.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern{}, false
+	}
+	p.parts = strings.Split(strings.ToLower(line), "/")
+	return p, true
+}
+
+// Match reports whether the file or folder at path, which is slash-separated
+// and relative to the root folder, is ignored. Everything in an ignored
+// folder is ignored as well.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	parts := strings.Split(strings.ToLower(path), "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(parts[:i], true) {
+			return true
+		}
+	}
+	return m.match(parts, isDir)
+}
+
+func (m *Matcher) match(parts []string, isDir bool) bool {
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if m.patterns[i].matches(parts, isDir) {
+			return !m.patterns[i].negate
+		}
+	}
+	return false
+}
+
+func (p *pattern) matches(parts []string, isDir bool) bool {
+	if p.dirOnly && !isDir || len(parts) <= len(p.base) {
+		return false
+	}
+	for i := range p.base {
+		if p.base[i] != parts[i] {
+			return false
+		}
+	}
+	rel := parts[len(p.base):]
+	if !p.anchored {
+		ok, _ := path.Match(p.parts[0], rel[len(rel)-1])
+		return ok
+	}
+	return matchParts(p.parts, rel)
+}
+
+// matchParts matches the path parts against the pattern parts, where "**"
+// matches any number of folders.
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// "dir/**" matches everything in dir, but not dir itself.
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,111 @@
+package ignore
+
+import "testing"
+
+type check struct {
+	path    string
+	isDir   bool
+	ignored bool
+}
+
+func run(t *testing.T, m *Matcher, checks []check) {
+	t.Helper()
+	for _, c := range checks {
+		if got := m.Match(c.path, c.isDir); got != c.ignored {
+			t.Errorf("%s (folder %v): want ignored %v but have %v",
+				c.path, c.isDir, c.ignored, got)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	m := New()
+	m.Add("", Defaults)
+	run(t, m, []check{
+		{"game/game.exe", false, true},
+		{"game/GAME.EXE", false, true},
+		{"group/game/game.exe", false, true},
+		{"game/go.sum", false, true},
+		{"game/go.mod", false, false},
+		{"game/main.go", false, false},
+		{"game/export", true, true},
+		{"game/export/game_linux", false, true},
+		{"game/export.go", false, false},
+		{"game/main.go~", false, true},
+		{"game/~$notes.docx", false, true},
+		{"game/data.txt", false, false},
+	})
+}
+
+func TestSyntax(t *testing.T) {
+	m := New()
+	m.Add("", `
+# comment
+\#hash
+\!bang
+build/
+/top.txt
+docs/*.md
+**/cache
+logs/**
+a/**/z
+*.log
+!keep.log
+file?.go
+[xy].txt
+`)
+	m.Add("", "trailing.txt   \r\n")
+	run(t, m, []check{
+		{"#hash", false, true},
+		{"!bang", false, true},
+		{"# comment", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"p/build/out.bin", false, true},
+		{"top.txt", false, true},
+		{"p/top.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/sub/a.md", false, false},
+		{"p/docs/a.md", false, false},
+		{"cache", true, true},
+		{"p/q/cache", false, true},
+		{"logs", true, false},
+		{"logs/x/y.txt", false, true},
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"b/a/z", false, false},
+		{"x.log", false, true},
+		{"p/keep.log", false, false},
+		{"trailing.txt", false, true},
+		{"file1.go", false, true},
+		{"file12.go", false, false},
+		{"x.txt", false, true},
+		{"z.txt", false, false},
+	})
+}
+
+func TestNestedFiles(t *testing.T) {
+	m := New()
+	m.Add("", "*.exe\n")
+	m.Add("game", "!game.exe\n/assets/raw/\nnotes.txt\n")
+	run(t, m, []check{
+		{"game/game.exe", false, false},
+		{"other/game.exe", false, true},
+		{"game/tool.exe", false, true},
+		{"game/assets/raw/a.png", false, true},
+		{"game/sub/assets/raw", true, false},
+		{"game/sub/notes.txt", false, true},
+		{"other/notes.txt", false, false},
+		{"game", true, false},
+	})
+}
+
+func TestIgnoredFolder(t *testing.T) {
+	m := New()
+	m.Add("", "tmp/\n!tmp/keep.go\n")
+	// Like in git, files cannot be shown again if their folder is hidden.
+	run(t, m, []check{
+		{"tmp/keep.go", false, true},
+		{"tmp/other.go", false, true},
+	})
+}
@@ -0,0 +1,234 @@
+// Package indent decides how Go code changes while the user types. It keeps
+// lines indented like gofmt does and closes brackets and quotes.
+//
+// All functions work on the whole code and byte offsets, lines are separated
+// by "\n".
+package indent
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// Edit replaces the code between Start and End with Text. Caret is the offset
+// of the caret in the changed code. An Edit with empty Text and Start == End
+// only moves the caret.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+	Caret int
+}
+
+var closing = map[byte]byte{
+	'(':  ')',
+	'[':  ']',
+	'{':  '}',
+	'"':  '"',
+	'\'': '\'',
+	'`':  '`',
+}
+
+// Newline returns the edit for pressing Enter with the selection from start to
+// end. The new line keeps the indentation of the current line. After an
+// opening bracket or a case label it is indented one more level. Between a
+// pair of brackets, the closing bracket goes on its own line.
+func Newline(code string, start, end int) Edit {
+	lineStart := strings.LastIndexByte(code[:start], '\n') + 1
+	indentation := leadingSpace(code[lineStart:start])
+	before := strings.TrimRight(code[lineStart:start], " \t")
+
+	// Blanks around the caret would end up as trailing blanks or at the start
+	// of the new line.
+	start = lineStart + len(before)
+	for end < len(code) && (code[end] == ' ' || code[end] == '\t') {
+		end++
+	}
+
+	inner := indentation
+	if opensBlock(before) {
+		inner += "\t"
+	}
+	text := "\n" + inner
+	caret := start + len(text)
+	if inner != indentation && end < len(code) && isCloser(code[end]) &&
+		strings.HasSuffix(before, string(opener(code[end]))) {
+		text += "\n" + indentation
+	}
+	return Edit{Start: start, End: end, Text: text, Caret: caret}
+}
+
+// opensBlock reports whether the line, without the trailing blanks, ends in a
+// way that indents the next line.
+func opensBlock(line string) bool {
+	if line == "" {
+		return false
+	}
+	switch line[len(line)-1] {
+	case '{', '(', '[':
+		return true
+	case ':':
+		return isCaseLabel(line)
+	}
+	return false
+}
+
+func isCaseLabel(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "case ") || strings.HasPrefix(line, "default:")
+}
+
+// Type returns the edit for typing the character r with the selection from
+// start to end. It returns false if the character is simply inserted.
+//
+// Opening brackets and quotes are closed, typing a closing one that is
+// already there steps over it. A closing brace at the start of a line and a
+// case label are moved to the indentation of their block.
+func Type(code string, start, end int, r rune) (Edit, bool) {
+	if start != end || r >= 0x80 {
+		return Edit{}, false
+	}
+	c := byte(r)
+	next := byte(0)
+	if end < len(code) {
+		next = code[end]
+	}
+	prev := byte(0)
+	if start > 0 {
+		prev = code[start-1]
+	}
+
+	if isCloser(c) && next == c {
+		return Edit{Start: start, End: start, Caret: start + 1}, true
+	}
+
+	if c == '}' || c == ':' {
+		if e, ok := dedent(code, start, c); ok {
+			return e, true
+		}
+	}
+
+	close, ok := closing[c]
+	if !ok {
+		return Edit{}, false
+	}
+	// Brackets are closed in front of blanks and other closing brackets,
+	// not in front of code that the user wants to wrap.
+	if next != 0 && !isSpace(next) && !isCloser(next) && next != ',' && next != ';' {
+		return Edit{}, false
+	}
+	// Quotes are not closed after letters, e.g. in "don't".
+	if isQuote(c) && (isWordByte(prev) || prev == '\\') {
+		return Edit{}, false
+	}
+	return Edit{Start: start, End: end, Text: string([]byte{c, close}), Caret: start + 1}, true
+}
+
+// dedent moves a line that starts a block's end or a case label to the
+// indentation of the block. c is the character typed at pos.
+func dedent(code string, pos int, c byte) (Edit, bool) {
+	lineStart := strings.LastIndexByte(code[:pos], '\n') + 1
+	line := code[lineStart:pos]
+	indentation := leadingSpace(line)
+	if c == '}' && indentation != line {
+		return Edit{}, false
+	}
+	if c == ':' && !isCaseLabel(line+":") {
+		return Edit{}, false
+	}
+	brace := openBrace(code, lineStart)
+	if brace < 0 {
+		return Edit{}, false
+	}
+	braceLine := strings.LastIndexByte(code[:brace], '\n') + 1
+	want := leadingSpace(code[braceLine:brace])
+	if want == indentation {
+		return Edit{}, false
+	}
+	text := want + strings.TrimLeft(line, " \t") + string(c)
+	return Edit{
+		Start: lineStart,
+		End:   pos,
+		Text:  text,
+		Caret: lineStart + len(text),
+	}, true
+}
+
+// Backspace returns the edit for pressing backspace with the selection from
+// start to end. Between an empty pair of brackets or quotes, both are
+// deleted. It returns false if backspace works as usual.
+func Backspace(code string, start, end int) (Edit, bool) {
+	if start != end || start == 0 || end >= len(code) {
+		return Edit{}, false
+	}
+	if close, ok := closing[code[start-1]]; ok && code[end] == close {
+		return Edit{Start: start - 1, End: end + 1, Caret: start - 1}, true
+	}
+	return Edit{}, false
+}
+
+// Apply returns the code after the edit.
+func (e Edit) Apply(code string) string {
+	return code[:e.Start] + e.Text + code[e.End:]
+}
+
+// openBrace returns the offset of the innermost brace that is still open at
+// pos, or -1 if there is none. Braces in strings and comments do not count.
+func openBrace(code string, pos int) int {
+	src := []byte(code[:pos])
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var open []int
+	for {
+		p, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch tok {
+		case token.LBRACE:
+			open = append(open, file.Offset(p))
+		case token.RBRACE:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	if len(open) == 0 {
+		return -1
+	}
+	return open[len(open)-1]
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func opener(c byte) byte {
+	for open, close := range closing {
+		if close == c {
+			return open
+		}
+	}
+	return 0
+}
+
+func isCloser(c byte) bool {
+	return c == ')' || c == ']' || c == '}' || isQuote(c)
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\'' || c == '`'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
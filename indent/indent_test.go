@@ -0,0 +1,152 @@
+package indent
+
+import (
+	"strings"
+	"testing"
+)
+
+// caret splits code at the | that marks the caret.
+func caret(t *testing.T, code string) (string, int) {
+	t.Helper()
+	i := strings.Index(code, "|")
+	if i == -1 {
+		t.Fatalf("no caret in %q", code)
+	}
+	return code[:i] + code[i+1:], i
+}
+
+// result returns the code after the edit with the caret marked by |.
+func result(code string, e Edit) string {
+	code = e.Apply(code)
+	return code[:e.Caret] + "|" + code[e.Caret:]
+}
+
+func TestNewline(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{"empty code", "|", "\n|"},
+		{"keep indentation", "\tx := 1|", "\tx := 1\n\t|"},
+		{"keep space indentation", "  x|", "  x\n  |"},
+		{"indent after brace", "func f() {|", "func f() {\n\t|"},
+		{"indent after parenthesis", "\tf(|", "\tf(\n\t\t|"},
+		{"indent after bracket", "x := []int{|", "x := []int{\n\t|"},
+		{"indent after case", "\tcase 1:|", "\tcase 1:\n\t\t|"},
+		{"indent after default", "\tdefault:|", "\tdefault:\n\t\t|"},
+		{"no indent after label", "loop:|", "loop:\n|"},
+		{"no indent after slice", "\tx := a[1:|", "\tx := a[1:\n\t|"},
+		{"split brace pair", "func f() {|}", "func f() {\n\t|\n}"},
+		{"split parenthesis pair", "\tf(|)", "\tf(\n\t\t|\n\t)"},
+		{"no split of other brackets", "\tf(|]", "\tf(\n\t\t|]"},
+		{"blanks at the caret are removed", "\tx := 1  |  y", "\tx := 1\n\t|y"},
+		{"blank line loses its blanks", "\t{\n\t\t|", "\t{\n\n\t\t|"},
+		{"in the middle of a line", "\tab|cd", "\tab\n\t|cd"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, pos := caret(t, test.before)
+			if got := result(code, Newline(code, pos, pos)); got != test.after {
+				t.Errorf("want\n%q\nbut have\n%q", test.after, got)
+			}
+		})
+	}
+}
+
+func TestNewlineReplacesSelection(t *testing.T) {
+	code := "\tabcd"
+	if got := result(code, Newline(code, 2, 4)); got != "\ta\n\t|d" {
+		t.Errorf("the selection must be replaced, have %q", got)
+	}
+}
+
+func TestType(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		typed  rune
+		// after is empty if the character is inserted as usual.
+		after string
+	}{
+		{"close parenthesis", "f|", '(', "f(|)"},
+		{"close bracket", "x := |", '[', "x := [|]"},
+		{"close brace", "func f() |", '{', "func f() {|}"},
+		{"close string", "x := |", '"', `x := "|"`},
+		{"close raw string", "x := |", '`', "x := `|`"},
+		{"close rune", "x := |", '\'', "x := '|'"},
+		{"close before closing bracket", "f(|)", '"', `f("|")`},
+		{"close before comma", "f(|, 1)", '(', "f((|), 1)"},
+		{"no close before code", "|x", '(', ""},
+		{"no close after letter", "don|", '\'', ""},
+		{"no close after backslash", `"\|`, '"', ""},
+		{"step over parenthesis", "f(|)", ')', "f()|"},
+		{"step over quote", `"abc|"`, '"', `"abc"|`},
+		{"other characters", "x|", 'y', ""},
+		{"non-ASCII", "x|", 'ä', ""},
+		{"dedent brace", "func f() {\n\tx := 1\n\t|", '}', "func f() {\n\tx := 1\n}|"},
+		{"dedent nested brace", "{\n\t{\n\t\t\t|", '}', "{\n\t{\n\t}|"},
+		{"no dedent after code", "func f() {\n\tx|", '}', ""},
+		{"no dedent without block", "\t|", '}', ""},
+		{"braces in strings do not count", "{\n\tx := \"{\"\n\t\t|", '}', "{\n\tx := \"{\"\n}|"},
+		{"braces in comments do not count", "{\n\t// {\n\t\t|", '}', "{\n\t// {\n}|"},
+		{"dedent case", "switch x {\n\tcase 1|", ':', "switch x {\ncase 1:|"},
+		{"dedent default", "\tswitch {\n\t\tdefault|", ':', "\tswitch {\n\tdefault:|"},
+		{"case already in place", "switch x {\ncase 1|", ':', ""},
+		{"no dedent for other colons", "{\n\t\tx := a[1|", ':', ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, pos := caret(t, test.before)
+			e, ok := Type(code, pos, pos, test.typed)
+			if test.after == "" {
+				if ok {
+					t.Errorf("want normal typing but have %q", result(code, e))
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("want %q but the character is inserted as usual", test.after)
+			}
+			if got := result(code, e); got != test.after {
+				t.Errorf("want\n%q\nbut have\n%q", test.after, got)
+			}
+		})
+	}
+}
+
+func TestTypeOverSelection(t *testing.T) {
+	if _, ok := Type("abc", 0, 2, '('); ok {
+		t.Error("typing over a selection must replace it as usual")
+	}
+}
+
+func TestBackspace(t *testing.T) {
+	tests := []struct {
+		before string
+		// after is empty if backspace works as usual.
+		after string
+	}{
+		{"f(|)", "f|"},
+		{`x := "|"`, "x := |"},
+		{"{|}", "|"},
+		{"f(|x)", ""},
+		{"f(x|)", ""},
+		{"(|]", ""},
+		{"|", ""},
+		{"(|", ""},
+	}
+	for _, test := range tests {
+		code, pos := caret(t, test.before)
+		e, ok := Backspace(code, pos, pos)
+		if test.after == "" {
+			if ok {
+				t.Errorf("%q: want normal backspace but have %q", test.before, result(code, e))
+			}
+			continue
+		}
+		if got := result(code, e); !ok || got != test.after {
+			t.Errorf("%q: want %q but have %q", test.before, test.after, got)
+		}
+	}
+}
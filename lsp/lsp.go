@@ -0,0 +1,472 @@
+// Package lsp is a client for language servers, e.g. gopls. It starts the
+// server, keeps it up to date with the open documents and asks it for
+// completions, hover information and definitions.
+//
+// The client knows nothing about the user interface. Paths are file paths,
+// positions are in the protocol's line and UTF-16 character format, use
+// PositionAt and OffsetOf to convert them.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned after the connection to the server was closed.
+var ErrClosed = errors.New("the language server connection is closed")
+
+// Client is a connection to a language server.
+type Client struct {
+	in  *bufio.Reader
+	out io.WriteCloser
+	cmd *exec.Cmd
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	nextID        int
+	pending       map[int]chan message
+	err           error
+	onDiagnostics func(path string, list []Diagnostic)
+	// documents holds the last text that was sent for each open path.
+	documents map[string]document
+}
+
+type document struct {
+	version int
+	text    string
+}
+
+// Start runs gopls in dir and connects to it. The client must be initialized
+// before use.
+func Start(dir string) (*Client, error) {
+	path, err := exec.LookPath("gopls")
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, "serve")
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := NewClient(stdout, stdin)
+	c.cmd = cmd
+	return c, nil
+}
+
+// NewClient talks to a server that reads from w and writes to r.
+func NewClient(r io.Reader, w io.WriteCloser) *Client {
+	c := &Client{
+		in:        bufio.NewReader(r),
+		out:       w,
+		pending:   map[int]chan message{},
+		documents: map[string]document{},
+	}
+	go c.read()
+	return c
+}
+
+// OnDiagnostics sets the function that is called when the server reports the
+// problems of a file. It is called from a different goroutine.
+func (c *Client) OnDiagnostics(f func(path string, list []Diagnostic)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onDiagnostics = f
+}
+
+// Initialize starts the session for the project in the folder root.
+func (c *Client) Initialize(ctx context.Context, root string) error {
+	params := map[string]any{
+		"processId": nil,
+		"rootUri":   fileURI(root),
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization": map[string]any{},
+				"completion": map[string]any{
+					"completionItem": map[string]any{"snippetSupport": false},
+				},
+				"hover": map[string]any{
+					"contentFormat": []string{"plaintext"},
+				},
+				"definition":         map[string]any{"linkSupport": false},
+				"publishDiagnostics": map[string]any{},
+			},
+		},
+		"workspaceFolders": []map[string]string{
+			{"uri": fileURI(root), "name": root},
+		},
+	}
+	if err := c.call(ctx, "initialize", params, nil); err != nil {
+		return err
+	}
+	return c.notify("initialized", struct{}{})
+}
+
+// Sync tells the server the current text of the file. The first call opens the
+// document, later calls send the whole text again, but only if it changed.
+func (c *Client) Sync(path, text string) error {
+	c.mu.Lock()
+	doc, open := c.documents[path]
+	if open && doc.text == text {
+		c.mu.Unlock()
+		return nil
+	}
+	doc.version++
+	doc.text = text
+	c.documents[path] = doc
+	c.mu.Unlock()
+
+	if !open {
+		return c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri":        fileURI(path),
+				"languageId": "go",
+				"version":    doc.version,
+				"text":       text,
+			},
+		})
+	}
+	return c.notify("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{
+			"uri":     fileURI(path),
+			"version": doc.version,
+		},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+// Version returns the version of the file's text that the server knows. It
+// grows with every Sync that changes the text and is 0 if the file is not
+// open. A reply is stale if the version changed after the request was sent.
+func (c *Client) Version(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.documents[path].version
+}
+
+// CloseDocument tells the server that the file is no longer edited.
+func (c *Client) CloseDocument(path string) error {
+	c.mu.Lock()
+	_, open := c.documents[path]
+	delete(c.documents, path)
+	c.mu.Unlock()
+
+	if !open {
+		return nil
+	}
+	return c.notify("textDocument/didClose", map[string]any{
+		"textDocument": textDocumentIdentifier{URI: fileURI(path)},
+	})
+}
+
+// Completion returns the completions at the position in the file.
+func (c *Client) Completion(ctx context.Context, path string, pos Position) ([]CompletionItem, error) {
+	var result json.RawMessage
+	if err := c.call(ctx, "textDocument/completion", positionParams(path, pos), &result); err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []CompletionItem `json:"items"`
+	}
+	if err := json.Unmarshal(result, &list.Items); err == nil {
+		return list.Items, nil
+	}
+	if err := json.Unmarshal(result, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Hover returns information about the code at the position in the file, e.g.
+// the declaration and documentation of an identifier. The text is empty if
+// there is nothing to show.
+func (c *Client) Hover(ctx context.Context, path string, pos Position) (string, error) {
+	var result struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.call(ctx, "textDocument/hover", positionParams(path, pos), &result); err != nil {
+		return "", err
+	}
+	return markupText(result.Contents), nil
+}
+
+// markupText returns the text of hover contents, which can be markup content,
+// a string or a list of marked strings.
+func markupText(data json.RawMessage) string {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s
+	}
+	var content struct {
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(data, &content) == nil && content.Value != "" {
+		return content.Value
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(data, &list) == nil {
+		var parts []string
+		for _, item := range list {
+			if text := markupText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// Definition returns the places where the identifier at the position in the
+// file is declared.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.call(ctx, "textDocument/definition", positionParams(path, pos), &result); err != nil {
+		return nil, err
+	}
+
+	var locations []Location
+	var list []json.RawMessage
+	if json.Unmarshal(result, &list) != nil {
+		list = []json.RawMessage{result}
+	}
+	for _, item := range list {
+		var l location
+		var link locationLink
+		if json.Unmarshal(item, &l) == nil && l.URI != "" {
+			locations = append(locations, Location{Path: uriPath(l.URI), Range: l.Range})
+		} else if json.Unmarshal(item, &link) == nil && link.TargetURI != "" {
+			locations = append(locations, Location{
+				Path:  uriPath(link.TargetURI),
+				Range: link.TargetSelectionRange,
+			})
+		}
+	}
+	return locations, nil
+}
+
+func positionParams(path string, pos Position) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: fileURI(path)},
+		Position:     pos,
+	}
+}
+
+// Shutdown ends the session and stops the server.
+func (c *Client) Shutdown(ctx context.Context) error {
+	err := c.call(ctx, "shutdown", nil, nil)
+	if err == nil {
+		err = c.notify("exit", nil)
+	}
+	c.out.Close()
+	if c.cmd != nil {
+		if ctx.Err() != nil || err != nil {
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	}
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	response := make(chan message, 1)
+	c.pending[id] = response
+	c.mu.Unlock()
+
+	rawID := json.RawMessage(strconv.Itoa(id))
+	if err := c.send(message{ID: &rawID, Method: method}, params); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+
+	select {
+	case m, ok := <-response:
+		if !ok {
+			return c.closedErr()
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result == nil || len(m.Result) == 0 || string(m.Result) == "null" {
+			return nil
+		}
+		return json.Unmarshal(m.Result, result)
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		c.notify("$/cancelRequest", map[string]int{"id": id})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params any) error {
+	return c.send(message{Method: method}, params)
+}
+
+func (c *Client) send(m message, params any) error {
+	m.JSONRPC = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		m.Params = data
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := writeMessage(c.out, data); err != nil {
+		return c.closedErr()
+	}
+	return nil
+}
+
+// read handles the messages from the server until the connection breaks.
+func (c *Client) read() {
+	for {
+		data, err := readMessage(c.in)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		var m message
+		if json.Unmarshal(data, &m) != nil {
+			continue
+		}
+
+		switch {
+		case m.Method == "" && m.ID != nil:
+			id, err := strconv.Atoi(string(*m.ID))
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			response, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				response <- m
+			}
+		case m.ID != nil:
+			c.answer(m)
+		case m.Method == "textDocument/publishDiagnostics":
+			var params publishDiagnosticsParams
+			if json.Unmarshal(m.Params, &params) != nil {
+				continue
+			}
+			c.mu.Lock()
+			f := c.onDiagnostics
+			c.mu.Unlock()
+			if f != nil {
+				f(uriPath(params.URI), params.Diagnostics)
+			}
+		}
+	}
+}
+
+// answer replies to a request from the server. The client does not offer any
+// features to the server, so the answers are empty.
+func (c *Client) answer(request message) {
+	result := json.RawMessage("null")
+	if request.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(request.Params, &params)
+		nulls := make([]any, len(params.Items))
+		result, _ = json.Marshal(nulls)
+	}
+	c.send(message{ID: request.ID, Result: result}, nil)
+}
+
+func (c *Client) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err == io.EOF {
+		c.err = ErrClosed
+	} else {
+		c.err = fmt.Errorf("language server connection: %w", err)
+	}
+	for id, response := range c.pending {
+		close(response)
+		delete(c.pending, id)
+	}
+}
+
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return ErrClosed
+}
+
+// writeMessage writes data with the header of the base protocol.
+func writeMessage(w io.Writer, data []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readMessage reads the content of the next message.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid message header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
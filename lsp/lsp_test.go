@@ -0,0 +1,353 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers requests with canned results and records the
+// notifications it gets.
+type fakeServer struct {
+	t   *testing.T
+	in  *bufio.Reader
+	out io.Writer
+	// results are the raw JSON results by method, "error: x" is returned as
+	// an error response with the message x.
+	results map[string]string
+	// received gets the method and params of every notification.
+	received chan message
+	// answers gets the responses to the server's own requests.
+	answers chan message
+}
+
+// newFakeServer returns a client that is connected to a fake server.
+func newFakeServer(t *testing.T, results map[string]string) (*Client, *fakeServer) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := &fakeServer{
+		t:        t,
+		in:       bufio.NewReader(serverIn),
+		out:      serverOut,
+		results:  results,
+		received: make(chan message, 100),
+		answers:  make(chan message, 100),
+	}
+	go s.serve()
+	c := NewClient(clientIn, clientOut)
+	t.Cleanup(func() {
+		clientOut.Close()
+		serverOut.Close()
+	})
+	return c, s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		data, err := readMessage(s.in)
+		if err != nil {
+			return
+		}
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			s.t.Errorf("invalid message %s", data)
+			return
+		}
+		switch {
+		case m.Method == "":
+			s.answers <- m
+		case m.ID == nil:
+			s.received <- m
+		default:
+			result, ok := s.results[m.Method]
+			if !ok {
+				result = "null"
+			}
+			if strings.HasPrefix(result, "error: ") {
+				text := strings.TrimPrefix(result, "error: ")
+				s.send(message{ID: m.ID, Error: &responseError{Code: -32603, Message: text}})
+			} else {
+				s.send(message{ID: m.ID, Result: json.RawMessage(result)})
+			}
+		}
+	}
+}
+
+func (s *fakeServer) send(m message) {
+	m.JSONRPC = "2.0"
+	data, _ := json.Marshal(m)
+	writeMessage(s.out, data)
+}
+
+func (s *fakeServer) next() message {
+	s.t.Helper()
+	select {
+	case m := <-s.received:
+		return m
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no notification received")
+		return message{}
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestInitialize(t *testing.T) {
+	c, s := newFakeServer(t, map[string]string{"initialize": `{"capabilities":{}}`})
+	if err := c.Initialize(testContext(t), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if m := s.next(); m.Method != "initialized" {
+		t.Errorf("want initialized notification but have %q", m.Method)
+	}
+}
+
+func TestSyncSendsOnlyChanges(t *testing.T) {
+	c, s := newFakeServer(t, nil)
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	if v := c.Version(path); v != 0 {
+		t.Errorf("closed document must have version 0, have %d", v)
+	}
+	c.Sync(path, "package main")
+	m := s.next()
+	var open struct {
+		TextDocument struct {
+			URI     string `json:"uri"`
+			Version int    `json:"version"`
+			Text    string `json:"text"`
+		} `json:"textDocument"`
+	}
+	json.Unmarshal(m.Params, &open)
+	if m.Method != "textDocument/didOpen" || open.TextDocument.Text != "package main" ||
+		uriPath(open.TextDocument.URI) != path || open.TextDocument.Version != 1 {
+		t.Errorf("unexpected open message %s %s", m.Method, m.Params)
+	}
+
+	c.Sync(path, "package main")
+	c.Sync(path, "package x")
+	m = s.next()
+	var change struct {
+		TextDocument struct {
+			Version int `json:"version"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	json.Unmarshal(m.Params, &change)
+	if m.Method != "textDocument/didChange" || change.TextDocument.Version != 2 ||
+		len(change.ContentChanges) != 1 || change.ContentChanges[0].Text != "package x" {
+		t.Errorf("unexpected change message %s %s", m.Method, m.Params)
+	}
+	if v := c.Version(path); v != 2 {
+		t.Errorf("want version 2 but have %d", v)
+	}
+
+	c.CloseDocument(path)
+	if m := s.next(); m.Method != "textDocument/didClose" {
+		t.Errorf("want didClose but have %s", m.Method)
+	}
+	c.Sync(path, "package x")
+	if m := s.next(); m.Method != "textDocument/didOpen" {
+		t.Errorf("closed document must be opened again, have %s", m.Method)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, result := range []string{
+		`[{"label":"Println","kind":3,"detail":"func(a ...any)"}]`,
+		`{"isIncomplete":false,"items":[{"label":"Println","kind":3,"detail":"func(a ...any)"}]}`,
+	} {
+		c, _ := newFakeServer(t, map[string]string{"textDocument/completion": result})
+		items, err := c.Completion(testContext(t), "main.go", Position{Line: 1, Character: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []CompletionItem{{Label: "Println", Kind: 3, Detail: "func(a ...any)"}}
+		if !reflect.DeepEqual(items, want) {
+			t.Errorf("want %v but have %v", want, items)
+		}
+	}
+}
+
+func TestCompletionItemText(t *testing.T) {
+	if s := (CompletionItem{Label: "a", InsertText: "b"}).Text(); s != "b" {
+		t.Errorf("want insert text but have %q", s)
+	}
+	if s := (CompletionItem{Label: "a", InsertText: "b", TextEdit: &TextEdit{NewText: "c"}}).Text(); s != "c" {
+		t.Errorf("want text edit but have %q", s)
+	}
+	if s := (CompletionItem{Label: "a"}).Text(); s != "a" {
+		t.Errorf("want label but have %q", s)
+	}
+}
+
+func TestHover(t *testing.T) {
+	for result, want := range map[string]string{
+		`{"contents":{"kind":"plaintext","value":"func Println()"}}`: "func Println()",
+		`{"contents":"x int"}`:                             "x int",
+		`{"contents":["a",{"language":"go","value":"b"}]}`: "a\n\nb",
+		`null`: "",
+	} {
+		c, _ := newFakeServer(t, map[string]string{"textDocument/hover": result})
+		text, err := c.Hover(testContext(t), "main.go", Position{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != want {
+			t.Errorf("%s: want %q but have %q", result, want, text)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	dir := t.TempDir()
+	uri := fileURI(filepath.Join(dir, "a b.go"))
+	r := `{"start":{"line":3,"character":5},"end":{"line":3,"character":9}}`
+	want := []Location{{
+		Path: filepath.Join(dir, "a b.go"),
+		Range: Range{
+			Start: Position{Line: 3, Character: 5},
+			End:   Position{Line: 3, Character: 9},
+		},
+	}}
+	for _, result := range []string{
+		`{"uri":"` + uri + `","range":` + r + `}`,
+		`[{"uri":"` + uri + `","range":` + r + `}]`,
+		`[{"targetUri":"` + uri + `","targetRange":` + r + `,"targetSelectionRange":` + r + `}]`,
+	} {
+		c, _ := newFakeServer(t, map[string]string{"textDocument/definition": result})
+		locations, err := c.Definition(testContext(t), "main.go", Position{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(locations, want) {
+			t.Errorf("%s: want %v but have %v", result, want, locations)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	c, _ := newFakeServer(t, map[string]string{"textDocument/hover": "error: no"})
+	if _, err := c.Hover(testContext(t), "main.go", Position{}); err == nil || err.Error() != "no" {
+		t.Errorf("want error response but have %v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, s := newFakeServer(t, nil)
+	got := make(chan []Diagnostic, 1)
+	var gotPath string
+	c.OnDiagnostics(func(path string, list []Diagnostic) {
+		gotPath = path
+		got <- list
+	})
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	params, _ := json.Marshal(publishDiagnosticsParams{
+		URI: fileURI(path),
+		Diagnostics: []Diagnostic{{
+			Severity: SeverityError,
+			Message:  "undefined: x",
+			Range:    Range{End: Position{Character: 1}},
+		}},
+	})
+	s.send(message{Method: "textDocument/publishDiagnostics", Params: params})
+
+	select {
+	case list := <-got:
+		if gotPath != path || len(list) != 1 || list[0].Message != "undefined: x" {
+			t.Errorf("unexpected diagnostics for %s: %v", gotPath, list)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no diagnostics received")
+	}
+}
+
+func TestServerRequestsAreAnswered(t *testing.T) {
+	_, s := newFakeServer(t, nil)
+	id := json.RawMessage(`"config-1"`)
+	s.send(message{
+		ID:     &id,
+		Method: "workspace/configuration",
+		Params: json.RawMessage(`{"items":[{"section":"gopls"},{"section":"go"}]}`),
+	})
+	select {
+	case m := <-s.answers:
+		if string(*m.ID) != `"config-1"` || string(m.Result) != "[null,null]" {
+			t.Errorf("unexpected answer %s %s", *m.ID, m.Result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer received")
+	}
+}
+
+func TestClosedConnection(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := NewClient(clientIn, clientOut)
+	// A server that exits closes both ends of the connection.
+	serverOut.Close()
+	serverIn.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Completion(context.Background(), "main.go", Position{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("error expected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request must fail when the connection closes")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	text := "ab\n😀x\n\nlast"
+	for offset, want := range map[int]Position{
+		0:  {0, 0},
+		2:  {0, 2},
+		3:  {1, 0},
+		7:  {1, 2},
+		8:  {1, 3},
+		10: {3, 0},
+		14: {3, 4},
+	} {
+		if got := PositionAt(text, offset); got != want {
+			t.Errorf("PositionAt(%d): want %v but have %v", offset, want, got)
+		}
+		if got := OffsetOf(text, want); got != offset {
+			t.Errorf("OffsetOf(%v): want %d but have %d", want, offset, got)
+		}
+	}
+	if got := OffsetOf(text, Position{Line: 0, Character: 99}); got != 2 {
+		t.Errorf("position after the line end must be at the end, have %d", got)
+	}
+	if got := OffsetOf(text, Position{Line: 99}); got != len(text) {
+		t.Errorf("position after the text must be at the end, have %d", got)
+	}
+}
+
+func TestURIs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "with space", "ä.go")
+	if got := uriPath(fileURI(path)); got != path {
+		t.Errorf("want %q but have %q", path, got)
+	}
+	if got := uriPath("file:///C:/x/main.go"); got != filepath.FromSlash("C:/x/main.go") {
+		t.Errorf("drive letter path: have %q", got)
+	}
+}
@@ -18,8 +18,29 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
+	"github.com/gonutz/gool/brackets"
+	"github.com/gonutz/gool/check"
+	"github.com/gonutz/gool/codefmt"
+	"github.com/gonutz/gool/fileops"
+	"github.com/gonutz/gool/highlight"
+	"github.com/gonutz/gool/ignore"
+	"github.com/gonutz/gool/indent"
+	"github.com/gonutz/gool/lsp"
+	"github.com/gonutz/gool/modpath"
+	"github.com/gonutz/gool/openfiles"
+	"github.com/gonutz/gool/paths"
+	"github.com/gonutz/gool/project"
+	"github.com/gonutz/gool/search"
+	"github.com/gonutz/gool/symbols"
+	"github.com/gonutz/gool/templates"
+	"github.com/gonutz/gool/textbuf"
+	"github.com/gonutz/gool/textfile"
+	"github.com/gonutz/gool/treediff"
+	"github.com/gonutz/gool/watch"
+	"github.com/gonutz/gool/workspace"
 	"github.com/gonutz/w32/v3"
 )
 
@@ -48,15 +69,78 @@ const (
 	commandLineShortcutID
 	programTimerID
 	scrollCheckTimerID
+	workspaceMenuID
+	dependenciesMenuID
+	dependenciesShortcutID
+	raceMenuID
+	noOptimizationsMenuID
+	guiMenuID
+	buildTagsMenuID
+	buildProfileShortcutID
+	exportShortcutID
+	saveShortcutID
+	autoSaveMenuID
+	quitMenuID
+	autoSaveTimerID
+	closeTabShortcutID
+	nextTabShortcutID
+	previousTabShortcutID
+	moveTabLeftShortcutID
+	moveTabRightShortcutID
+	undoShortcutID
+	redoShortcutID
+	findShortcutID
+	replaceShortcutID
+	findNextShortcutID
+	findPreviousShortcutID
+	findNextButtonID
+	findPreviousButtonID
+	replaceButtonID
+	replaceAllButtonID
+	closeFindButtonID
+	projectSearchShortcutID
+	goToLineShortcutID
+	goToSymbolShortcutID
+	outlineID
+	outlineTimerID
+	formatShortcutID
+	formatBeforeRunMenuID
+	fixImportsMenuID
+	completionShortcutID
+	hoverShortcutID
+	definitionShortcutID
+	hoverTimerID
+	bracketShortcutID
+	newProjectShortcutID
+	templatesMenuID
+	problemsID
+	checkTimerID
+	newFileMenuID
+	renameMenuID
+	duplicateMenuID
+	deleteMenuID
+	revealMenuID
+	showHiddenMenuID
+	ignorePatternsMenuID
 )
 
 const (
 	programStartMessage = w32.WM_USER + iota
 	programStopMessage
+	languageServerMessage
+	diagnosticsMessage
+	checkMessage
+	bracketsMessage
+	filesChangedMessage
+	languageReplyMessage
 )
 
 var fontSize float64 = 17
 
+// autoSaveInterval is the time between automatic saves of the open file, if
+// auto-save is enabled.
+const autoSaveInterval = 30 * 1000 // In milliseconds.
+
 const (
 	minFontSize = 8
 	maxFontSize = 1000
@@ -74,6 +158,11 @@ func run() error {
 		stopProgram     = func() {}
 		programStdin    io.WriteCloser
 		openFilePath    string
+		openFileDirty   bool
+		autoSave        bool
+		formatBeforeRun bool
+		fixImports      bool
+		showHidden      bool
 		labelFont       w32.HFONT
 		codeFont        w32.HFONT
 		lastLineCount         = -1
@@ -88,22 +177,47 @@ func run() error {
 		return filepath.Join(filepath.Dir(exe), "gool_projects"), nil
 	}
 
+	// templatesDir holds the project templates that teachers add, one folder
+	// per template.
+	templatesDir := func() (string, error) {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(exe), "gool_templates"), nil
+	}
+
+	// trashDir holds the files and folders that were deleted in the project
+	// tree, so they can be restored.
+	trashDir := func() (string, error) {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(exe), "gool_trash"), nil
+	}
+
 	fileToOpen := ""
 	if root, err := projectsDir(); err != nil {
 		return err
 	} else if !pathExists(root) {
 		// Create the projects folder and a hello world project.
 		os.MkdirAll(root, 0666)
-		os.MkdirAll(filepath.Join(root, "hello_world"), 0666)
-		hello := filepath.Join(root, "hello_world", "main.go")
-		os.WriteFile(hello, []byte(helloWorldCode), 0666)
-		fileToOpen = hello
+		list, _ := templates.List("")
+		if t, ok := templates.Find(list, templates.HelloWorld); ok {
+			files, _ := t.Create(filepath.Join(root, "hello_world"))
+			fileToOpen = templates.MainFile(files)
+		}
 	}
 
 	if err := setManifest(); err != nil {
 		return err
 	}
 
+	// The code editor is a rich edit control so it can show colors.
+	w32.Init()
+	defer w32.Close()
+
 	if err := w32.InitCommonControlsEx(&w32.INITCOMMONCONTROLSEX{
 		ICC: w32.ICC_TREEVIEW_CLASSES |
 			w32.ICC_UPDOWN_CLASS |
@@ -208,6 +322,65 @@ func run() error {
 		nil,
 	)
 
+	outlineCaption, err := w32.CreateWindowEx(
+		0,
+		w32.String("STATIC"),
+		w32.String("Gliederung"),
+		w32.WS_VISIBLE|w32.WS_CHILD|w32.ES_CENTER,
+		10, 250, 200, 25,
+		window,
+		0, 0, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	outlineTree, err := w32.CreateWindowEx(
+		0,
+		w32.WC_TREEVIEW,
+		nil,
+		w32.WS_VISIBLE|w32.WS_CHILD|w32.WS_BORDER|
+			w32.TVS_HASLINES|w32.TVS_HASBUTTONS|w32.TVS_LINESATROOT|
+			w32.TVS_SHOWSELALWAYS,
+		10, 280, 200, 100,
+		window,
+		outlineID,
+		0,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	problemsCaption, err := w32.CreateWindowEx(
+		0,
+		w32.String("STATIC"),
+		w32.String("Probleme"),
+		w32.WS_VISIBLE|w32.WS_CHILD|w32.ES_CENTER,
+		10, 390, 200, 25,
+		window,
+		0, 0, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	problemsList, err := w32.CreateWindowEx(
+		0,
+		w32.String("LISTBOX"),
+		nil,
+		w32.WS_VISIBLE|w32.WS_CHILD|w32.WS_BORDER|w32.WS_VSCROLL|w32.WS_HSCROLL|
+			LBS_NOTIFY|LBS_NOINTEGRALHEIGHT,
+		10, 420, 200, 100,
+		window,
+		problemsID,
+		0,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
 	startButton, err := w32.CreateWindowEx(
 		0,
 		w32.String("BUTTON"),
@@ -234,13 +407,26 @@ func run() error {
 		return err
 	}
 
+	tabControl, err := w32.CreateWindowEx(
+		0,
+		w32.String(WC_TABCONTROL),
+		nil,
+		w32.WS_VISIBLE|w32.WS_CHILD|w32.WS_CLIPSIBLINGS,
+		220, 40, 300, 25,
+		window,
+		0, 0, nil,
+	)
+	if err != nil {
+		return err
+	}
+
 	codeEdit, err := w32.CreateWindowEx(
 		w32.WS_EX_CLIENTEDGE,
-		w32.String("EDIT"),
+		w32.MSFTEDIT_CLASS,
 		nil,
 		w32.WS_VISIBLE|w32.WS_CHILD|w32.ES_MULTILINE|w32.ES_WANTRETURN|
 			w32.WS_HSCROLL|w32.ES_AUTOHSCROLL|w32.WS_VSCROLL|w32.ES_AUTOVSCROLL|
-			w32.WS_DISABLED,
+			w32.ES_NOHIDESEL|w32.WS_DISABLED,
 		220, 40, 300, 300,
 		window,
 		0, 0, nil,
@@ -249,12 +435,15 @@ func run() error {
 		return err
 	}
 	w32.SetFocus(codeEdit)
-	w32.Edit_LimitText(codeEdit, 0x7FFFFFFF)
+	w32.SendMessage(codeEdit, w32.EM_EXLIMITTEXT, 0, 0x7FFFFFFF)
+	w32.SendMessage(codeEdit, w32.EM_SETEVENTMASK, 0, ENM_CHANGE|ENM_SCROLL|ENM_SELCHANGE)
+	// The rich edit's own undo is not used, codeText records all changes.
+	w32.SendMessage(codeEdit, w32.EM_SETUNDOLIMIT, 0, 0)
 
 	lineNumbers, err := w32.CreateWindowEx(
 		0,
 		w32.String("EDIT"),
-		w32.String(numberRange(1, 1)),
+		w32.String(numberRange(1, 1, nil)),
 		w32.WS_CHILD|w32.ES_MULTILINE|w32.WS_DISABLED|
 			w32.ES_AUTOHSCROLL|w32.ES_RIGHT,
 		210, 40, 10, 300,
@@ -270,7 +459,8 @@ func run() error {
 		w32.String("EDIT"),
 		w32.String("Programm-Output..."),
 		w32.WS_VISIBLE|w32.WS_CHILD|w32.ES_MULTILINE|w32.ES_WANTRETURN|w32.ES_READONLY|
-			w32.WS_HSCROLL|w32.ES_AUTOHSCROLL|w32.WS_VSCROLL|w32.ES_AUTOVSCROLL,
+			w32.WS_HSCROLL|w32.ES_AUTOHSCROLL|w32.WS_VSCROLL|w32.ES_AUTOVSCROLL|
+			w32.ES_NOHIDESEL,
 		220, 320, 300, 100,
 		window,
 		0, 0, nil,
@@ -298,6 +488,95 @@ func run() error {
 		uintptr(unsafe.Pointer(w32.String("Programm-Input..."))),
 	)
 
+	// The find bar is shown below the code editor. It searches codeEdit or
+	// consoleOutput, whichever had the focus when it was opened.
+	var (
+		findBarVisible bool
+		findTarget     = codeEdit
+		findCreateErr  error
+	)
+	createFindControl := func(class, text string, style uint32, id uintptr) w32.HWND {
+		var exStyle uint32
+		if class == "EDIT" {
+			exStyle = w32.WS_EX_CLIENTEDGE
+		}
+		control, err := w32.CreateWindowEx(
+			exStyle,
+			w32.String(class),
+			w32.String(text),
+			w32.WS_CHILD|w32.WS_TABSTOP|style,
+			0, 0, 10, 10,
+			window,
+			w32.HMENU(id), 0, nil,
+		)
+		if err != nil && findCreateErr == nil {
+			findCreateErr = err
+		}
+		return control
+	}
+	findEdit := createFindControl("EDIT", "", w32.ES_AUTOHSCROLL, 0)
+	replaceEdit := createFindControl("EDIT", "", w32.ES_AUTOHSCROLL, 0)
+	findPreviousButton := createFindControl("BUTTON", "Zurück", 0, findPreviousButtonID)
+	findNextButton := createFindControl("BUTTON", "Weiter", 0, findNextButtonID)
+	replaceButton := createFindControl("BUTTON", "Ersetzen", 0, replaceButtonID)
+	replaceAllButton := createFindControl("BUTTON", "Alle ersetzen", 0, replaceAllButtonID)
+	findCaseCheck := createFindControl("BUTTON", "Groß-/Kleinschreibung", w32.BS_AUTOCHECKBOX, 0)
+	findWordCheck := createFindControl("BUTTON", "Ganzes Wort", w32.BS_AUTOCHECKBOX, 0)
+	findRegexCheck := createFindControl("BUTTON", "Regulärer Ausdruck", w32.BS_AUTOCHECKBOX, 0)
+	findStatus := createFindControl("STATIC", "", 0, 0)
+	closeFindButton := createFindControl("BUTTON", "Schließen", 0, closeFindButtonID)
+	if findCreateErr != nil {
+		return findCreateErr
+	}
+	Edit_SetCueBannerText(findEdit, "Suchen...")
+	Edit_SetCueBannerText(replaceEdit, "Ersetzen durch...")
+	findControls := []w32.HWND{
+		findEdit, replaceEdit, findPreviousButton, findNextButton,
+		replaceButton, replaceAllButton, findCaseCheck, findWordCheck,
+		findRegexCheck, findStatus, closeFindButton,
+	}
+
+	// The completion list and hover information float over the code. They
+	// must not take the focus away from codeEdit.
+	completionList, err := w32.CreateWindowEx(
+		w32.WS_EX_TOOLWINDOW|w32.WS_EX_NOACTIVATE|w32.WS_EX_TOPMOST,
+		w32.String("LISTBOX"),
+		nil,
+		w32.WS_POPUP|w32.WS_BORDER|w32.WS_VSCROLL|LBS_NOTIFY|LBS_NOINTEGRALHEIGHT,
+		0, 0, 400, 200,
+		window,
+		0, 0, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	hoverPopup, err := w32.CreateWindowEx(
+		w32.WS_EX_TOOLWINDOW|w32.WS_EX_NOACTIVATE|w32.WS_EX_TOPMOST,
+		w32.String("STATIC"),
+		nil,
+		w32.WS_POPUP|w32.WS_BORDER,
+		0, 0, 400, 200,
+		window,
+		0, 0, nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	// codeText is the code shown in codeEdit. It is the source of truth, edits
+	// that the user makes in codeEdit are recorded in it. updatingCode is true
+	// while codeEdit is changed to show codeText.
+	var (
+		codeText     = textbuf.New("")
+		updatingCode bool
+	)
+
+	// problems are the errors and warnings that the background checker found
+	// in the package of the open file. They are marked next to the line
+	// numbers.
+	var problems []check.Problem
+
 	// We might need to sync our line numbers with the code when:
 	// - the user scrolls the code
 	// - the code changes
@@ -317,14 +596,246 @@ func run() error {
 		// couple of lines. This is fine, a couple extra lines outside the
 		// visible area do not hurt. +1 for very large fonts.
 		bottomLine := topLine + (r.Bottom-r.Top)/int32(fontSize) + 1
-		lineCount := int32(w32.Edit_GetLineCount(codeEdit))
+		lineCount := int32(codeText.LineCount())
 		if lineCount < bottomLine {
 			bottomLine = lineCount
 		}
+		markers := map[int]string{}
+		for _, p := range problems {
+			if !paths.Same(p.Path, openFilePath) {
+				continue
+			}
+			if p.Severity == check.Error {
+				markers[p.Line] = errorMarker
+			} else if markers[p.Line] == "" {
+				markers[p.Line] = warningMarker
+			}
+		}
 		w32.SetWindowText(
 			lineNumbers,
-			w32.String(numberRange(int(topLine)+1, int(bottomLine))),
+			w32.String(numberRange(int(topLine)+1, int(bottomLine), markers)),
+		)
+	}
+
+	// syntax holds the highlighted code of codeEdit. highlighting is true while
+	// the colors are applied, which changes the selection of codeEdit.
+	var (
+		syntax       highlight.Document
+		highlighting bool
+	)
+
+	syntaxColors := map[highlight.Kind]w32.COLORREF{
+		highlight.Keyword: w32.RGB(0, 0, 192),
+		highlight.String:  w32.RGB(163, 21, 21),
+		highlight.Comment: w32.RGB(0, 128, 0),
+		highlight.Number:  w32.RGB(9, 134, 88),
+		highlight.Builtin: w32.RGB(0, 112, 160),
+	}
+
+	// styleCode calls style, which formats the text in codeEdit, without
+	// flickering and without moving the selection.
+	styleCode := func(style func()) {
+		highlighting = true
+		defer func() { highlighting = false }()
+
+		// Coloring is not an edit that the user wants to undo.
+		if doc, ok := RichEdit_GetTextDocument(codeEdit); ok {
+			doc.SuspendUndo()
+			defer doc.Release()
+			defer doc.ResumeUndo()
+		}
+
+		w32.SendMessage(codeEdit, w32.WM_SETREDRAW, 0, 0)
+		sel := RichEdit_GetSel(codeEdit)
+		scroll := RichEdit_GetScrollPos(codeEdit)
+
+		style()
+
+		RichEdit_SetSel(codeEdit, sel)
+		RichEdit_SetScrollPos(codeEdit, scroll)
+		w32.SendMessage(codeEdit, w32.WM_SETREDRAW, 1, 0)
+		w32.InvalidateRect(codeEdit, nil, true)
+	}
+
+	// colorLines colors the lines from, to of syntax in codeEdit.
+	colorLines := func(from, to int) {
+		// Rich edit positions count UTF-16 code units and one character for
+		// each line break.
+		start := 0
+		for i := 0; i < from; i++ {
+			start += utf16Len(syntax.Line(i)) + 1
+		}
+		end := start
+		for i := from; i < to; i++ {
+			end += utf16Len(syntax.Line(i)) + 1
+		}
+		// Resetting the format also removes formatting from pasted text.
+		RichEdit_SetCharFormat(
+			codeEdit, start, end, RichEdit_GetDefaultCharFormat(codeEdit),
+		)
+
+		lineStart := start
+		for i := from; i < to; i++ {
+			line := syntax.Line(i)
+			for _, span := range syntax.Spans(i) {
+				RichEdit_SetCharFormat(
+					codeEdit,
+					lineStart+utf16Len(line[:span.Start]),
+					lineStart+utf16Len(line[:span.End]),
+					CHARFORMAT{Mask: CFM_COLOR, TextColor: syntaxColors[span.Kind]},
+				)
+			}
+			lineStart += utf16Len(line) + 1
+		}
+	}
+
+	// highlightCode colors the lines of codeEdit that changed since the last
+	// call. Only Go code is colored.
+	highlightCode := func() {
+		if !isGoFile(openFilePath) {
+			return
+		}
+		from, to := syntax.SetText(codeText.String())
+		if from == to {
+			return
+		}
+		styleCode(func() { colorLines(from, to) })
+	}
+
+	// codeBrackets are the brackets of bracketsCode, the code they were last
+	// found in. bracketsMarked is true while brackets in codeEdit have a
+	// background color.
+	var (
+		codeBrackets   *brackets.Brackets
+		bracketsCode   string
+		bracketsMarked bool
+	)
+
+	// showBrackets highlights the bracket next to the caret and its partner.
+	// Brackets without a partner are always marked, a missing or extra
+	// bracket is the most common reason why code does not compile.
+	showBrackets := func() {
+		code := codeText.String()
+		var marks, unbalanced []int
+		if isGoFile(openFilePath) {
+			if codeBrackets == nil || code != bracketsCode {
+				codeBrackets = brackets.Scan(code)
+				bracketsCode = code
+			}
+			unbalanced = codeBrackets.Unbalanced
+			if sel := RichEdit_GetSel(codeEdit); sel.Min == sel.Max {
+				caret := byteOffset(code, int(sel.Min))
+				if bracket, partner, ok := codeBrackets.AtCaret(caret); ok {
+					marks = []int{bracket, partner}
+				}
+			}
+		}
+		if !bracketsMarked && len(marks) == 0 && len(unbalanced) == 0 {
+			return
+		}
+
+		styleCode(func() {
+			// Text that is typed next to a marked bracket has its color, too,
+			// so all the code is cleared.
+			RichEdit_SetBackColor(codeEdit, 0, -1, 0)
+			mark := func(offset int, color w32.COLORREF) {
+				from := utf16Len(code[:offset])
+				RichEdit_SetBackColor(codeEdit, from, from+1, color)
+			}
+			for _, offset := range unbalanced {
+				mark(offset, w32.RGB(255, 170, 170))
+			}
+			for _, offset := range marks {
+				mark(offset, w32.RGB(190, 220, 255))
+			}
+		})
+		bracketsMarked = len(marks) > 0 || len(unbalanced) > 0
+	}
+
+	// editorText returns the text in codeEdit with "\n" line breaks.
+	editorText := func() string {
+		text, _ := w32.GetWindowText(codeEdit)
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		return strings.ReplaceAll(text, "\r", "\n")
+	}
+
+	var (
+		outlineItems    []w32.HTREEITEM
+		outlineSymbols  []symbols.Symbol
+		outlineShape    string
+		updatingOutline bool
+	)
+
+	// updateOutline shows the declarations of codeText in outlineTree. The
+	// tree is only rebuilt if the declarations changed, not just their
+	// positions, so it keeps its scroll position while the user types.
+	updateOutline := func() {
+		// Other files have no declarations, their outline is empty.
+		var nodes []symbols.Node
+		if isGoFile(openFilePath) {
+			nodes = symbols.Outline(symbols.Parse(codeText.String()))
+		}
+
+		var list []symbols.Symbol
+		var shape strings.Builder
+		for _, n := range nodes {
+			list = append(list, n.Symbol)
+			shape.WriteString(n.Kind.String() + " " + n.Name + "\n")
+			for _, m := range n.Methods {
+				list = append(list, m)
+				shape.WriteString("\t" + m.Name + "\n")
+			}
+		}
+		outlineSymbols = list
+		if shape.String() == outlineShape {
+			return
+		}
+		outlineShape = shape.String()
+
+		insert := func(parent w32.HTREEITEM, text string) w32.HTREEITEM {
+			item, _ := w32.TreeView_InsertItem(outlineTree, &w32.TVINSERTSTRUCT{
+				Parent:      parent,
+				InsertAfter: w32.TVI_LAST,
+				ItemEx: w32.TVITEMEX{
+					Mask: w32.TVIF_TEXT,
+					Text: w32.String(text),
+				},
+			})
+			outlineItems = append(outlineItems, item)
+			return item
+		}
+
+		updatingOutline = true
+		w32.SendMessage(outlineTree, w32.WM_SETREDRAW, 0, 0)
+		w32.TreeView_DeleteAllItems(outlineTree)
+		outlineItems = outlineItems[:0]
+		for _, n := range nodes {
+			item := insert(w32.TVI_ROOT, n.Kind.String()+" "+n.Name)
+			for _, m := range n.Methods {
+				insert(item, "func "+strings.TrimPrefix(m.Name, m.Receiver+"."))
+			}
+			w32.TreeView_Expand(outlineTree, item, w32.TVE_EXPAND)
+		}
+		w32.SendMessage(outlineTree, w32.WM_SETREDRAW, 1, 0)
+		w32.InvalidateRect(outlineTree, nil, true)
+		updatingOutline = false
+	}
+
+	// showCode replaces the text in codeEdit with codeText. This removes all
+	// colors so the code is highlighted from scratch.
+	showCode := func() {
+		updatingCode = true
+		syntax = highlight.Document{}
+		w32.SetWindowText(
+			codeEdit,
+			w32.String(strings.ReplaceAll(codeText.String(), "\n", "\r\n")),
 		)
+		updatingCode = false
+		// The new text might have taken the background of a marked bracket.
+		bracketsMarked = true
+		highlightCode()
+		showBrackets()
+		updateOutline()
 	}
 
 	layoutControls := func() {
@@ -345,11 +856,14 @@ func run() error {
 		numberW := 50
 		if dc, err := w32.GetDC(lineNumbers); err == nil {
 			w32.SelectObject(dc, w32.HGDIOBJ(codeFont))
-			lineCount := w32.Edit_GetLineCount(codeEdit)
-			n := strconv.Itoa(int(lineCount))
+			n := strconv.Itoa(codeText.LineCount())
 			if size, err := w32.GetTextExtentPoint32(dc, w32.String(n)); err == nil {
 				numberW = int(size.Cx) * 3 / 2
 			}
+			// Leave room for the problem markers.
+			if size, err := w32.GetTextExtentPoint32(dc, w32.String(errorMarker)); err == nil {
+				numberW += int(size.Cx)
+			}
 			w32.ReleaseDC(lineNumbers, dc)
 		}
 
@@ -365,25 +879,66 @@ func run() error {
 		row1y := row0y + labelH
 		startButtonX := col0x + (col0w-buttonW-margin)/2
 		projectsY := row0y + labelH
-		projectsH := height - 2*margin - buttonH - projectsY
-		startButtonY := projectsY + projectsH + margin
+		columnH := height - 2*margin - buttonH - projectsY
+		projectsH := (columnH - 2*labelH) / 2
+		outlineCaptionY := projectsY + projectsH
+		outlineY := outlineCaptionY + labelH
+		outlineH := (columnH - 2*labelH) / 4
+		problemsCaptionY := outlineY + outlineH
+		problemsY := problemsCaptionY + labelH
+		problemsH := columnH - projectsH - outlineH - 2*labelH
+		startButtonY := projectsY + columnH + margin
 		inputY := height - margin - editH
 		outputH := 200
 		outputY := inputY - margin - outputH
-		codeY := row1y
+		tabY := row1y
+		tabH := labelH + 8
+		codeY := tabY + tabH
 		codeH := outputY - margin - codeY
+		findRowH := editH + 4
+		if findBarVisible {
+			codeH -= 2*findRowH + 2*margin
+		}
 		codeEditX := col1x + numberW + 1
 		codeEditW := col1w - numberW - 1
 		scrollBarH := w32.GetSystemMetrics(w32.SM_CYHSCROLL)
 
 		setPos(projectsCaption, col0x, row0y, col0w, labelH)
 		setPos(projectTree, col0x, projectsY, col0w, projectsH)
+		setPos(outlineCaption, col0x, outlineCaptionY, col0w, labelH)
+		setPos(outlineTree, col0x, outlineY, col0w, outlineH)
+		setPos(problemsCaption, col0x, problemsCaptionY, col0w, labelH)
+		setPos(problemsList, col0x, problemsY, col0w, problemsH)
 		setPos(startButton, startButtonX, startButtonY, buttonW, buttonH)
 		setPos(codeCaption, codeEditX, row0y, col1w, labelH)
+		setPos(tabControl, col1x, tabY, col1w, tabH)
 		setPos(lineNumbers, col1x, codeY+3, numberW, codeH-int(scrollBarH)-6)
 		setPos(codeEdit, codeEditX, codeY, codeEditW, codeH)
 		setPos(consoleOutput, col1x, outputY, col1w, outputH)
 		setPos(consoleInput, col1x, inputY, col1w, editH)
+		if findBarVisible {
+			y1 := codeY + codeH + margin
+			y2 := y1 + findRowH + margin/2
+			editW := col1w / 3
+			x1 := col1x + editW + margin
+			x2 := x1 + buttonW + margin
+			checkX := x2 + buttonW + margin
+			caseW, wordW, regexW := labelH*8, labelH*5, labelH*7
+			statusX := x2 + 2*buttonW + margin
+			closeW := buttonW * 3 / 2
+			closeX := col1x + col1w - closeW
+			setPos(findEdit, col1x, y1, editW, findRowH)
+			setPos(replaceEdit, col1x, y2, editW, findRowH)
+			setPos(findPreviousButton, x1, y1, buttonW, findRowH)
+			setPos(replaceButton, x1, y2, buttonW, findRowH)
+			setPos(findNextButton, x2, y1, buttonW, findRowH)
+			setPos(replaceAllButton, x2, y2, 2*buttonW, findRowH)
+			setPos(findCaseCheck, checkX, y1, caseW, findRowH)
+			setPos(findWordCheck, checkX+caseW+margin, y1, wordW, findRowH)
+			setPos(findRegexCheck, checkX+caseW+wordW+2*margin, y1, regexW, findRowH)
+			setPos(findStatus, statusX, y2+2, closeX-statusX-margin, findRowH)
+			setPos(closeFindButton, closeX, y2, closeW, findRowH)
+		}
 		updateLineNumbers()
 
 		w32.InvalidateRect(window, nil, true)
@@ -391,409 +946,2559 @@ func run() error {
 
 	outputBuf := newSyncBuffer()
 
-	startProgram := func() {
-		if openFilePath == "" {
-			return
-		}
+	var (
+		openFile    func(path string) error
+		updateTitle func()
+	)
 
-		// TODO This function might create new files, like go.mod, so update
-		// the file tree afterwards.
+	// codeChanged updates the colors, line numbers and dirty state after the
+	// code in codeEdit changed.
+	codeChanged := func() {
+		highlightCode()
+		showBrackets()
+		// Parsing long files on every key stroke would slow down typing, so
+		// the outline is updated once the user pauses.
+		w32.SetTimer(window, outlineTimerID, 500, 0)
+		w32.SetTimer(window, checkTimerID, 300, 0)
+		if !openFileDirty {
+			openFileDirty = true
+			updateTitle()
+		}
+		lineCount := codeText.LineCount()
+		if lineCount != lastLineCount {
+			if len(strconv.Itoa(lineCount)) != len(strconv.Itoa(lastLineCount)) {
+				// The line numbers have grown or shrunk in size, so we
+				// need to adjust the line number column width.
+				layoutControls()
+			} else {
+				updateLineNumbers()
+			}
+			lastLineCount = lineCount
+		}
+	}
 
-		programRunning = true
-		w32.SendMessage(window, programStartMessage, 0, 0)
+	// applyChanges makes the changes in codeEdit that were made to codeText,
+	// which had the text before. The last changed text is selected.
+	applyChanges := func(before string, changes []textbuf.Change) {
+		if len(changes) == 0 {
+			return
+		}
+		updatingCode = true
+		text := before
+		var sel CHARRANGE
+		for _, c := range changes {
+			// Rich edit positions count UTF-16 code units and one character
+			// for each line break.
+			start := utf16Len(text[:c.Start])
+			end := start + utf16Len(text[c.Start:c.End])
+			RichEdit_SetSel(codeEdit, CHARRANGE{Min: int32(start), Max: int32(end)})
+			w32.SendMessage(
+				codeEdit,
+				w32.EM_REPLACESEL,
+				0,
+				uintptr(unsafe.Pointer(w32.String(strings.ReplaceAll(c.Text, "\n", "\r\n")))),
+			)
+			text = text[:c.Start] + c.Text + text[c.End:]
+			sel = CHARRANGE{Min: int32(start), Max: int32(start + utf16Len(c.Text))}
+		}
+		updatingCode = false
+		if editorText() != codeText.String() {
+			// This should not happen, but if codeEdit got out of sync, show
+			// the real code.
+			showCode()
+		}
+		RichEdit_SetSel(codeEdit, sel)
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		codeChanged()
+	}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		stopProgram = cancel
+	// showLine puts the caret at the start of the given line, counted from 1,
+	// and scrolls it into view.
+	showLine := func(line int) {
+		pos := int32(utf16Len(codeText.Slice(0, codeText.LineStart(line-1))))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: pos, Max: pos})
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		w32.SetFocus(codeEdit)
+	}
 
-		go func(ctx context.Context) {
-			defer func() {
-				programMu.Lock()
-				programRunning = false
-				cancel = func() {}
-				w32.SendMessage(window, programStopMessage, 0, 0)
-				programMu.Unlock()
-			}()
+	// replaceCode replaces the code in codeEdit in a way that can be undone.
+	replaceCode := func(code string) {
+		before := codeText.String()
+		applyChanges(before, []textbuf.Change{codeText.SetText(code)})
+	}
 
-			code, err := w32.GetWindowText(codeEdit)
-			if err != nil {
-				fmt.Fprintf(outputBuf, "Unable to read code: %s\r\n", err)
-				return
-			}
+	// formatCode formats the code in codeEdit as one undo step, the caret
+	// stays at the same code. If the code cannot be formatted, the reason is
+	// shown in the output and false is returned.
+	formatCode := func() bool {
+		if !isGoFile(openFilePath) {
+			return true
+		}
 
-			projectsPath, err := projectsDir()
-			if err != nil {
-				fmt.Fprintf(outputBuf,
-					"Unable to read projects path: %s\r\n", err)
-				return
+		code := codeText.String()
+		var formatted string
+		var err error
+		if fixImports {
+			formatted, err = codefmt.Imports(code, filepath.Dir(openFilePath))
+		} else {
+			formatted, err = codefmt.Source(code)
+		}
+		if err != nil {
+			message := "Der Code kann nicht formatiert werden:\r\n"
+			var e *codefmt.Error
+			if errors.As(err, &e) {
+				for _, d := range e.Diagnostics {
+					message += filepath.Base(openFilePath) + ":" + d.String() + "\r\n"
+				}
+				showLine(e.Diagnostics[0].Line)
+			} else {
+				message += err.Error() + "\r\n"
 			}
+			w32.SetWindowText(consoleOutput, w32.String(message))
+			return false
+		}
+		if formatted == code {
+			return true
+		}
 
-			openFileFolder, _ := filepath.Split(openFilePath)
-			projectName := filepath.Base(openFileFolder)
-			projectPath := filepath.Join(projectsPath, projectName)
+		caret := byteOffset(code, int(RichEdit_GetSel(codeEdit).Min))
+		caret = codefmt.MapOffset(code, formatted, caret)
+		scroll := RichEdit_GetScrollPos(codeEdit)
+		replaceCode(formatted)
+		pos := int32(utf16Len(formatted[:caret]))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: pos, Max: pos})
+		RichEdit_SetScrollPos(codeEdit, scroll)
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		updateLineNumbers()
+		return true
+	}
 
-			if isDone(ctx) {
-				return
-			}
+	undo := func() {
+		before := codeText.String()
+		applyChanges(before, codeText.Undo())
+	}
 
-			code = strings.ReplaceAll(code, "\r\n", "\n")
-			if err := os.WriteFile(openFilePath, []byte(code), 0666); err != nil {
-				fmt.Fprintf(outputBuf,
-					"Unable to write file '%s': %s\r\n", openFilePath, err)
-				return
-			}
+	redo := func() {
+		before := codeText.String()
+		applyChanges(before, codeText.Redo())
+	}
 
-			exeFilePath := filepath.Join(projectPath, projectName+".exe")
+	setFindStatus := func(status string) {
+		w32.SetWindowText(findStatus, w32.String(status))
+	}
 
-			modFilePath := filepath.Join(projectPath, "go.mod")
-			if !pathExists(modFilePath) {
-				init := exec.CommandContext(ctx, "go", "mod", "init", projectName)
-				init.Dir = projectPath
-				output, err := init.CombinedOutput()
-				if isDone(ctx) {
-					return
-				}
-				if err != nil {
-					fmt.Fprintf(outputBuf,
-						"go mod init failed: %s\r\n%s\r\n", err, output)
-					return
-				}
-			}
+	findQuery := func() search.Query {
+		pattern, _ := w32.GetWindowText(findEdit)
+		return search.Query{
+			Pattern:       pattern,
+			CaseSensitive: Button_IsChecked(findCaseCheck),
+			WholeWord:     Button_IsChecked(findWordCheck),
+			Regexp:        Button_IsChecked(findRegexCheck),
+		}
+	}
 
-			// TODO Always run go mod tidy? Or only on error?
-			tidy := exec.CommandContext(ctx, "go", "mod", "tidy")
-			tidy.Dir = projectPath
-			output, err := tidy.CombinedOutput()
-			if isDone(ctx) {
-				return
-			}
-			if err != nil {
-				fmt.Fprintf(outputBuf,
-					"go mod tidy failed: %s\r\n%s\r\n", err, output)
-				return
-			}
+	// findTargetText returns the text that the find bar searches. Positions
+	// in the text map to the target's positions with utf16Len.
+	findTargetText := func() string {
+		if findTarget == codeEdit {
+			return codeText.String()
+		}
+		text, _ := w32.GetWindowText(findTarget)
+		return text
+	}
 
-			build := exec.CommandContext(ctx, "go", "build", "-o", exeFilePath, ".")
-			build.Dir = projectPath
-			output, err = build.CombinedOutput()
-			if isDone(ctx) {
+	// findMatches searches the find bar's target. If there is no match, the
+	// status tells the user why and false is returned.
+	findMatches := func() (string, []search.Match, bool) {
+		text := findTargetText()
+		q := findQuery()
+		if q.Pattern == "" {
+			setFindStatus("")
+			return text, nil, false
+		}
+		matches, err := q.FindAll(text)
+		if err != nil {
+			setFindStatus("Ungültiger Ausdruck")
+			return text, nil, false
+		}
+		if len(matches) == 0 {
+			setFindStatus("Keine Treffer")
+			return text, nil, false
+		}
+		return text, matches, true
+	}
+
+	// findSelection returns the selection in the find bar's target as byte
+	// offsets into text.
+	findSelection := func(text string) (start, end int) {
+		selStart, selEnd := Edit_GetSel(findTarget)
+		return byteOffset(text, int(selStart)), byteOffset(text, int(selEnd))
+	}
+
+	// findMatch selects the match that pick chooses, given the current
+	// selection.
+	findMatch := func(pick func(matches []search.Match, selStart, selEnd int) int) {
+		text, matches, ok := findMatches()
+		if !ok {
+			return
+		}
+		selStart, selEnd := findSelection(text)
+		i := pick(matches, selStart, selEnd)
+		m := matches[i]
+		start := utf16Len(text[:m.Start])
+		end := start + utf16Len(text[m.Start:m.End])
+		Edit_SetSel(findTarget, uint32(start), uint32(end))
+		w32.SendMessage(findTarget, w32.EM_SCROLLCARET, 0, 0)
+		setFindStatus(fmt.Sprintf("%d von %d", i+1, len(matches)))
+	}
+
+	findNext := func() {
+		findMatch(func(matches []search.Match, _, selEnd int) int {
+			return search.Next(matches, selEnd)
+		})
+	}
+
+	findPrevious := func() {
+		findMatch(func(matches []search.Match, selStart, _ int) int {
+			return search.Previous(matches, selStart)
+		})
+	}
+
+	// findAgain is used while the user changes the search. The selected match
+	// stays selected as long as it matches.
+	findAgain := func() {
+		findMatch(func(matches []search.Match, selStart, _ int) int {
+			return search.Next(matches, selStart)
+		})
+	}
+
+	// replaceMatch replaces the selected match and selects the next one.
+	replaceMatch := func() {
+		if findTarget != codeEdit {
+			return
+		}
+		text, matches, ok := findMatches()
+		if !ok {
+			return
+		}
+		start, end := findSelection(text)
+		for _, m := range matches {
+			if m.Start == start && m.End == end {
+				replacement, _ := w32.GetWindowText(replaceEdit)
+				c := textbuf.Change{
+					Start: m.Start,
+					End:   m.End,
+					Text:  findQuery().Expand(text, m, replacement),
+				}
+				codeText.StopCoalescing()
+				codeText.Replace(c.Start, c.End, c.Text)
+				codeText.StopCoalescing()
+				applyChanges(text, []textbuf.Change{c})
+				break
+			}
+		}
+		findNext()
+	}
+
+	// replaceAllMatches replaces all matches in one step that can be undone.
+	replaceAllMatches := func() {
+		if findTarget != codeEdit {
+			return
+		}
+		text, matches, ok := findMatches()
+		if !ok {
+			return
+		}
+		q := findQuery()
+		replacement, _ := w32.GetWindowText(replaceEdit)
+		// Replace back to front so the positions of the remaining matches
+		// stay valid.
+		var changes []textbuf.Change
+		codeText.BeginGroup()
+		for i := len(matches) - 1; i >= 0; i-- {
+			m := matches[i]
+			c := textbuf.Change{
+				Start: m.Start,
+				End:   m.End,
+				Text:  q.Expand(text, m, replacement),
+			}
+			codeText.Replace(c.Start, c.End, c.Text)
+			changes = append(changes, c)
+		}
+		codeText.EndGroup()
+		applyChanges(text, changes)
+		setFindStatus(fmt.Sprintf("%d ersetzt", len(matches)))
+	}
+
+	// openFindBar shows the find bar for the focused code editor or output
+	// pane. The selected text is searched for.
+	openFindBar := func(replace bool) {
+		focus := w32.GetFocus()
+		if focus == codeEdit || focus == consoleOutput {
+			findTarget = focus
+			text := findTargetText()
+			start, end := findSelection(text)
+			if selected := text[start:end]; selected != "" &&
+				!strings.ContainsAny(selected, "\r\n") {
+				w32.SetWindowText(findEdit, w32.String(selected))
+			}
+		}
+
+		// The output pane is read-only.
+		canReplace := findTarget == codeEdit
+		w32.EnableWindow(replaceEdit, canReplace)
+		w32.EnableWindow(replaceButton, canReplace)
+		w32.EnableWindow(replaceAllButton, canReplace)
+
+		if !findBarVisible {
+			findBarVisible = true
+			for _, c := range findControls {
+				w32.ShowWindow(c, w32.SW_SHOW)
+			}
+			layoutControls()
+		}
+
+		edit := findEdit
+		if replace && canReplace {
+			edit = replaceEdit
+		}
+		w32.SetFocus(edit)
+		w32.SendMessage(edit, w32.EM_SETSEL, 0, ^uintptr(0))
+		findAgain()
+	}
+
+	closeFindBar := func() {
+		if !findBarVisible {
+			return
+		}
+		findBarVisible = false
+		for _, c := range findControls {
+			w32.ShowWindow(c, w32.SW_HIDE)
+		}
+		layoutControls()
+		w32.SetFocus(findTarget)
+	}
+
+	// handleFindBarKey handles Enter and Escape in the find bar's edit
+	// controls. It returns true if the key was handled.
+	handleFindBarKey := func(target w32.HWND, key uintptr) bool {
+		if target != findEdit && target != replaceEdit {
+			return false
+		}
+		switch key {
+		case w32.VK_RETURN:
+			if target == replaceEdit {
+				replaceMatch()
+			} else if w32.GetAsyncKeyState(w32.VK_SHIFT)&0x8000 != 0 {
+				findPrevious()
+			} else {
+				findNext()
+			}
+			return true
+		case w32.VK_ESCAPE:
+			closeFindBar()
+			return true
+		}
+		return false
+	}
+
+	// editorTab is a file that is open in the code editor. The path and dirty
+	// state of the active tab live in openFilePath and openFileDirty, the
+	// editorTab fields for them are only up-to-date for inactive tabs. The
+	// text and file format are always up-to-date, the active tab's text is
+	// codeText.
+	type editorTab struct {
+		path   string
+		format textfile.Format
+		text   *textbuf.Buffer
+		dirty  bool
+		// disk is the content that gool last read from or wrote to the
+		// file. Changes on disk that match it are gool's own.
+		disk      []byte
+		selStart  uint32
+		selEnd    uint32
+		firstLine int32
+	}
+
+	var (
+		tabs      []*editorTab
+		activeTab = -1
+	)
+
+	tabDirty := func(i int) bool {
+		if i == activeTab {
+			return openFileDirty
+		}
+		return tabs[i].dirty
+	}
+
+	updateTabLabel := func(i int) {
+		label := filepath.Base(tabs[i].path)
+		if tabDirty(i) {
+			label += "*"
+		}
+		TabCtrl_SetItemText(tabControl, i, label)
+	}
+
+	// stashActiveTab remembers the editor state of the active tab so another
+	// tab can be shown in the code editor.
+	stashActiveTab := func() {
+		if activeTab < 0 {
+			return
+		}
+		t := tabs[activeTab]
+		t.dirty = openFileDirty
+		t.selStart, t.selEnd = Edit_GetSel(codeEdit)
+		t.firstLine = w32.Edit_GetFirstVisibleLine(codeEdit)
+	}
+
+	// The language server gopls offers completions, hover information,
+	// definitions and diagnostics for the project of the open file. It is
+	// optional, without it these features do nothing.
+	var (
+		languageServer     *lsp.Client
+		languageServerRoot string
+		// languageServerMu protects startedLanguageServers, diagnostics
+		// and languageReplies, which are set from other goroutines.
+		languageServerMu       sync.Mutex
+		startedLanguageServers = map[string]*lsp.Client{}
+		diagnostics            = map[string][]lsp.Diagnostic{}
+		// languageReplies handle the answers of gopls on the UI thread,
+		// they run when languageReplyMessage is posted.
+		languageReplies []func()
+	)
+
+	// startLanguageServer makes sure that gopls runs for the project of the
+	// open file. Starting takes a while, so it happens in the background and
+	// languageServerMessage is posted when gopls is ready.
+	startLanguageServer := func() {
+		root, err := projectsDir()
+		if err != nil || !isGoFile(openFilePath) || !paths.IsInside(openFilePath, root) {
+			// Files outside the projects, e.g. from the standard library,
+			// are handled by the current server.
+			return
+		}
+		dir := projectFolder(root, openFilePath)
+		if dir == languageServerRoot {
+			return
+		}
+		if languageServer != nil {
+			go shutdownLanguageServer(languageServer)
+		}
+		languageServer = nil
+		languageServerRoot = dir
+
+		go func() {
+			c, err := lsp.Start(dir)
+			if err != nil {
+				return
+			}
+			c.OnDiagnostics(func(path string, list []lsp.Diagnostic) {
+				languageServerMu.Lock()
+				diagnostics[paths.Key(path)] = list
+				languageServerMu.Unlock()
+				PostMessage(window, diagnosticsMessage, 0, 0)
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := c.Initialize(ctx, dir); err != nil {
+				shutdownLanguageServer(c)
+				return
+			}
+			languageServerMu.Lock()
+			startedLanguageServers[dir] = c
+			languageServerMu.Unlock()
+			PostMessage(window, languageServerMessage, 0, 0)
+		}()
+	}
+
+	// syncLanguageServer sends the code of the open file to gopls.
+	syncLanguageServer := func() {
+		if languageServer != nil && isGoFile(openFilePath) {
+			languageServer.Sync(openFilePath, codeText.String())
+		}
+	}
+
+	// askLanguageServer sends a request to gopls in the background so the UI
+	// does not freeze while gopls is busy. request runs in another goroutine
+	// and returns the function that handles the reply on the UI thread. The
+	// reply is dropped if the open file was edited or switched meanwhile.
+	askLanguageServer := func(
+		timeout time.Duration,
+		request func(ctx context.Context, c *lsp.Client, path string) func(),
+	) {
+		if languageServer == nil {
+			return
+		}
+		syncLanguageServer()
+		c, path := languageServer, openFilePath
+		version := c.Version(path)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			handle := request(ctx, c, path)
+			cancel()
+			languageServerMu.Lock()
+			languageReplies = append(languageReplies, func() {
+				syncLanguageServer()
+				if c == languageServer && path == openFilePath && c.Version(path) == version {
+					handle()
+				}
+			})
+			languageServerMu.Unlock()
+			PostMessage(window, languageReplyMessage, 0, 0)
+		}()
+	}
+
+	// showDiagnostics underlines the problems that gopls found in the open
+	// file, errors in red and everything else in yellow.
+	showDiagnostics := func() {
+		languageServerMu.Lock()
+		list := diagnostics[paths.Key(openFilePath)]
+		languageServerMu.Unlock()
+
+		code := codeText.String()
+		styleCode(func() {
+			RichEdit_SetUnderline(codeEdit, 0, -1, CFU_UNDERLINENONE, 0)
+			for _, d := range list {
+				start := lsp.OffsetOf(code, d.Range.Start)
+				end := max(start, lsp.OffsetOf(code, d.Range.End))
+				from := utf16Len(code[:start])
+				to := from + utf16Len(code[start:end])
+				if to == from {
+					// Mark at least one character, e.g. for a missing
+					// closing brace at the end of the code.
+					if from > 0 && start == len(code) {
+						from--
+					} else {
+						to++
+					}
+				}
+				color := byte(UnderlineDarkYellow)
+				if d.Severity == lsp.SeverityError {
+					color = UnderlineRed
+				}
+				RichEdit_SetUnderline(codeEdit, from, to, CFU_UNDERLINEWAVE, color)
+			}
+		})
+	}
+
+	// caretOffset returns the byte offset of the caret in codeText.
+	caretOffset := func() int {
+		return byteOffset(codeText.String(), int(RichEdit_GetSel(codeEdit).Min))
+	}
+
+	codeLineHeight := func() int {
+		height := 16
+		if dc, err := w32.GetDC(codeEdit); err == nil {
+			w32.SelectObject(dc, w32.HGDIOBJ(codeFont))
+			if size, err := w32.GetTextExtentPoint32(dc, w32.String("X")); err == nil {
+				height = int(size.Cy)
+			}
+			w32.ReleaseDC(codeEdit, dc)
+		}
+		return height
+	}
+
+	// belowCode returns the screen position right below the character at the
+	// byte offset in codeText.
+	belowCode := func(offset int) (int, int) {
+		p := RichEdit_PosFromChar(codeEdit, utf16Len(codeText.Slice(0, offset)))
+		p.Y += int32(codeLineHeight())
+		p, _ = w32.ClientToScreen(codeEdit, p)
+		return int(p.X), int(p.Y)
+	}
+
+	var (
+		completionItems []lsp.CompletionItem
+		completionShown []lsp.CompletionItem
+		// completionStart is the byte offset of the word that is completed.
+		completionStart int
+	)
+
+	hideCompletion := func() {
+		w32.ShowWindow(completionList, w32.SW_HIDE)
+		completionItems = nil
+		completionShown = nil
+	}
+
+	// filterCompletion shows the completions that start with the word in
+	// front of the caret. The list is closed if there are none.
+	filterCompletion := func() {
+		code := codeText.String()
+		caret := caretOffset()
+		if caret < completionStart {
+			hideCompletion()
+			return
+		}
+		prefix := strings.ToLower(code[completionStart:caret])
+		for i := 0; i < len(prefix); i++ {
+			if !isIdentifierByte(prefix[i]) {
+				hideCompletion()
+				return
+			}
+		}
+
+		completionShown = nil
+		for _, item := range completionItems {
+			name := item.FilterText
+			if name == "" {
+				name = item.Label
+			}
+			if strings.HasPrefix(strings.ToLower(name), prefix) {
+				completionShown = append(completionShown, item)
+			}
+		}
+		if len(completionShown) == 0 {
+			hideCompletion()
+			return
+		}
+
+		ListBox_ResetContent(completionList)
+		for _, item := range completionShown {
+			text := item.Label
+			if item.Detail != "" {
+				text += "   " + item.Detail
+			}
+			ListBox_AddString(completionList, text)
+		}
+		ListBox_SetCurSel(completionList, 0)
+
+		x, y := belowCode(completionStart)
+		height := min(len(completionShown), 10)*ListBox_GetItemHeight(completionList) + 4
+		w32.SetWindowPos(
+			completionList, 0,
+			int32(x), int32(y), int32(30*codeLineHeight()), int32(height),
+			w32.SWP_NOACTIVATE|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
+		)
+	}
+
+	// showCompletion asks gopls how to complete the word in front of the
+	// caret and shows the choices.
+	showCompletion := func() {
+		if languageServer == nil {
+			if _, err := exec.LookPath("gopls"); err != nil {
+				w32.SetWindowText(consoleOutput, w32.String(
+					"Für die Vervollständigung wird gopls benötigt. "+
+						"Installieren mit:\r\n"+
+						"go install golang.org/x/tools/gopls@latest\r\n",
+				))
+			}
+			return
+		}
+		code := codeText.String()
+		caret := caretOffset()
+		start := caret
+		for start > 0 && isIdentifierByte(code[start-1]) {
+			start--
+		}
+
+		askLanguageServer(3*time.Second, func(ctx context.Context, c *lsp.Client, path string) func() {
+			items, err := c.Completion(ctx, path, lsp.PositionAt(code, caret))
+			return func() {
+				if err != nil || len(items) == 0 {
+					hideCompletion()
+					return
+				}
+				completionItems = items
+				completionStart = start
+				filterCompletion()
+			}
+		})
+	}
+
+	// acceptCompletion replaces the word in front of the caret with the
+	// selected completion.
+	acceptCompletion := func() {
+		i := ListBox_GetCurSel(completionList)
+		if i < 0 || i >= len(completionShown) {
+			hideCompletion()
+			return
+		}
+		item := completionShown[i]
+		hideCompletion()
+
+		code := codeText.String()
+		start, end := completionStart, caretOffset()
+		if item.TextEdit != nil {
+			start = min(end, lsp.OffsetOf(code, item.TextEdit.Range.Start))
+		}
+		text := item.Text()
+		codeText.Replace(start, end, text)
+		applyChanges(code, []textbuf.Change{{Start: start, End: end, Text: text}})
+		caret := int32(utf16Len(code[:start] + text))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: caret, Max: caret})
+	}
+
+	// handleCompletionKey lets the user choose a completion with the keyboard
+	// while typing in codeEdit. It returns true if the key was handled.
+	handleCompletionKey := func(target w32.HWND, key uintptr) bool {
+		if target != codeEdit || !w32.IsWindowVisible(completionList) {
+			return false
+		}
+		i := ListBox_GetCurSel(completionList)
+		last := len(completionShown) - 1
+		switch key {
+		case w32.VK_UP:
+			ListBox_SetCurSel(completionList, max(i-1, 0))
+		case w32.VK_DOWN:
+			ListBox_SetCurSel(completionList, min(i+1, last))
+		case w32.VK_PRIOR:
+			ListBox_SetCurSel(completionList, max(i-9, 0))
+		case w32.VK_NEXT:
+			ListBox_SetCurSel(completionList, min(i+9, last))
+		case w32.VK_RETURN, w32.VK_TAB:
+			acceptCompletion()
+		case w32.VK_ESCAPE:
+			hideCompletion()
+		case w32.VK_LEFT, w32.VK_RIGHT, w32.VK_HOME, w32.VK_END:
+			hideCompletion()
+			return false
+		default:
+			return false
+		}
+		return true
+	}
+
+	// handleCodeTyping indents new lines, closes brackets and quotes and
+	// expands snippets while the user types in codeEdit. It returns true if
+	// the message was handled.
+	handleCodeTyping := func(msg *w32.MSG) bool {
+		if msg.Hwnd != codeEdit || !isGoFile(openFilePath) {
+			return false
+		}
+		code := codeText.String()
+		sel := RichEdit_GetSel(codeEdit)
+		start, end := byteOffset(code, int(sel.Min)), byteOffset(code, int(sel.Max))
+
+		var (
+			e  indent.Edit
+			ok bool
+		)
+		switch {
+		case msg.Message == w32.WM_KEYDOWN && msg.WParam == w32.VK_RETURN:
+			e, ok = indent.Newline(code, start, end), true
+		case msg.Message == w32.WM_KEYDOWN && msg.WParam == w32.VK_TAB && start == end &&
+			w32.GetAsyncKeyState(w32.VK_CONTROL)&0x8000 == 0 &&
+			w32.GetAsyncKeyState(w32.VK_SHIFT)&0x8000 == 0:
+			e, ok = templates.ExpandSnippet(code, start, templates.Snippets)
+		case msg.Message == w32.WM_KEYDOWN && msg.WParam == w32.VK_BACK &&
+			w32.GetAsyncKeyState(w32.VK_CONTROL)&0x8000 == 0:
+			e, ok = indent.Backspace(code, start, end)
+		case msg.Message == w32.WM_CHAR:
+			e, ok = indent.Type(code, start, end, rune(msg.WParam))
+		}
+		if !ok {
+			return false
+		}
+
+		if e.Text != "" || e.Start != e.End {
+			codeText.Replace(e.Start, e.End, e.Text)
+			applyChanges(code, []textbuf.Change{{Start: e.Start, End: e.End, Text: e.Text}})
+		}
+		caret := int32(utf16Len(codeText.Slice(0, e.Caret)))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: caret, Max: caret})
+		if w32.IsWindowVisible(completionList) {
+			filterCompletion()
+		}
+		return true
+	}
+
+	// jumpToBracket moves the caret to the partner of the bracket next to it,
+	// on the same side of the bracket. Away from brackets, it moves to the
+	// enclosing opening bracket.
+	jumpToBracket := func() {
+		code := codeText.String()
+		b := brackets.Scan(code)
+		caret := caretOffset()
+		target := -1
+		if bracket, partner, ok := b.AtCaret(caret); ok {
+			target = partner
+			if bracket < caret {
+				target++
+			}
+		} else if p, ok := b.Enclosing(caret); ok {
+			target = p.Open
+		}
+		if target < 0 {
+			return
+		}
+		pos := int32(utf16Len(code[:target]))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: pos, Max: pos})
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		w32.SetFocus(codeEdit)
+	}
+
+	// hoverMouse is the screen position where the mouse came to rest over
+	// codeEdit.
+	var hoverMouse w32.POINT
+
+	hideHover := func() {
+		w32.ShowWindow(hoverPopup, w32.SW_HIDE)
+	}
+
+	// showHoverText shows the hover information from gopls at the screen
+	// position x, y or hides it if there is none.
+	showHoverText := func(text string, err error, x, y int) {
+		text = strings.TrimSpace(strings.ReplaceAll(text, "\t", "    "))
+		if err != nil || text == "" {
+			hideHover()
+			return
+		}
+
+		const maxLines = 25
+		lines := strings.Split(text, "\n")
+		if len(lines) > maxLines {
+			lines = append(lines[:maxLines], "...")
+		}
+		width, height := 0, 0
+		if dc, err := w32.GetDC(hoverPopup); err == nil {
+			w32.SelectObject(dc, w32.HGDIOBJ(codeFont))
+			for _, line := range lines {
+				size, _ := w32.GetTextExtentPoint32(dc, w32.String(line+" "))
+				width = max(width, int(size.Cx))
+				height += int(size.Cy)
+			}
+			w32.ReleaseDC(hoverPopup, dc)
+		}
+		w32.SetWindowText(hoverPopup, w32.String(strings.Join(lines, "\r\n")))
+		w32.SetWindowPos(
+			hoverPopup, 0,
+			int32(x), int32(y), int32(width+8), int32(height+4),
+			w32.SWP_NOACTIVATE|w32.SWP_NOZORDER|w32.SWP_SHOWWINDOW,
+		)
+	}
+
+	// showHover shows what gopls knows about the code at the byte offset in
+	// codeText, e.g. the type and documentation of an identifier. The
+	// information appears at the screen position x, y.
+	showHover := func(offset, x, y int) {
+		pos := lsp.PositionAt(codeText.String(), offset)
+		askLanguageServer(2*time.Second, func(ctx context.Context, c *lsp.Client, path string) func() {
+			text, err := c.Hover(ctx, path, pos)
+			return func() { showHoverText(text, err, x, y) }
+		})
+	}
+
+	// hoverAtMouse shows the hover information for the code under the mouse
+	// if it has not moved since it came to rest.
+	hoverAtMouse := func() {
+		p, err := w32.GetCursorPos()
+		if err != nil || abs(int(p.X-hoverMouse.X))+abs(int(p.Y-hoverMouse.Y)) > 4 {
+			return
+		}
+		client, _ := w32.ScreenToClient(codeEdit, p)
+		r, _ := w32.GetClientRect(codeEdit)
+		if client.X < r.Left || client.X >= r.Right || client.Y < r.Top || client.Y >= r.Bottom {
+			return
+		}
+		code := codeText.String()
+		offset := byteOffset(code, RichEdit_CharFromPos(codeEdit, client))
+		if offset < len(code) && isIdentifierByte(code[offset]) {
+			showHover(offset, int(p.X), int(p.Y)+codeLineHeight())
+		}
+	}
+
+	// showLocation selects the code at l. It may be in another file, which is
+	// then opened.
+	showLocation := func(l lsp.Location) {
+		if !paths.Same(l.Path, openFilePath) {
+			if err := openFile(l.Path); err != nil {
+				w32.MessageBox(
+					window,
+					w32.String(err.Error()),
+					w32.String("Fehler"),
+					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+				)
+				return
+			}
+		}
+		code := codeText.String()
+		start := lsp.OffsetOf(code, l.Range.Start)
+		end := max(start, lsp.OffsetOf(code, l.Range.End))
+		RichEdit_SetSel(codeEdit, CHARRANGE{
+			Min: int32(utf16Len(code[:start])),
+			Max: int32(utf16Len(code[:end])),
+		})
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		w32.SetFocus(codeEdit)
+	}
+
+	// goToDefinition selects the declaration of the identifier at the byte
+	// offset in codeText. It may be in another file, which is then opened.
+	goToDefinition := func(offset int) {
+		pos := lsp.PositionAt(codeText.String(), offset)
+		askLanguageServer(5*time.Second, func(ctx context.Context, c *lsp.Client, path string) func() {
+			locations, err := c.Definition(ctx, path, pos)
+			return func() {
+				if err == nil && len(locations) > 0 {
+					showLocation(locations[0])
+				}
+			}
+		})
+	}
+
+	// The background checker type-checks the package of the open file
+	// while the user types. Only one check runs at a time, changes during a
+	// check start another one afterwards.
+	var (
+		checker      = check.New()
+		checkRunning bool
+		checkPending bool
+		// checkResult is set by the check's goroutine.
+		checkResultMu sync.Mutex
+		checkResult   []check.Problem
+	)
+
+	showProblems := func() {
+		ListBox_ResetContent(problemsList)
+		var texts []string
+		for _, p := range problems {
+			kind := "Fehler"
+			if p.Severity == check.Warning {
+				kind = "Warnung"
+			}
+			texts = append(texts, kind+": "+p.String())
+			ListBox_AddString(problemsList, texts[len(texts)-1])
+		}
+		// The list box does not know how wide its items are, so it needs
+		// help to scroll horizontally.
+		width := 0
+		if dc, err := w32.GetDC(problemsList); err == nil {
+			w32.SelectObject(dc, w32.HGDIOBJ(labelFont))
+			for _, text := range texts {
+				if size, err := w32.GetTextExtentPoint32(dc, w32.String(text)); err == nil {
+					width = max(width, int(size.Cx)+8)
+				}
+			}
+			w32.ReleaseDC(problemsList, dc)
+		}
+		w32.SendMessage(problemsList, LB_SETHORIZONTALEXTENT, uintptr(width), 0)
+
+		caption := "Probleme"
+		if len(problems) > 0 {
+			caption += " (" + strconv.Itoa(len(problems)) + ")"
+		}
+		w32.SetWindowText(problemsCaption, w32.String(caption))
+		updateLineNumbers()
+	}
+
+	// runCheck checks the package of the open file in the background,
+	// checkMessage is posted when it is done.
+	runCheck := func() {
+		if !isGoFile(openFilePath) {
+			problems = nil
+			showProblems()
+			return
+		}
+		if checkRunning {
+			checkPending = true
+			return
+		}
+		checkRunning = true
+
+		// Unsaved changes in all tabs are checked.
+		overlay := map[string]string{}
+		for i, t := range tabs {
+			path := t.path
+			if i == activeTab {
+				path = openFilePath
+			}
+			overlay[path] = t.text.String()
+		}
+		dir := filepath.Dir(openFilePath)
+		go func() {
+			list, err := checker.Check(dir, overlay)
+			if err != nil {
+				list = nil
+			}
+			checkResultMu.Lock()
+			checkResult = list
+			checkResultMu.Unlock()
+			PostMessage(window, checkMessage, 0, 0)
+		}()
+	}
+
+	// showProblem moves the caret to the problem, opening its file if
+	// necessary.
+	showProblem := func(p check.Problem) {
+		if !paths.Same(p.Path, openFilePath) {
+			if err := openFile(p.Path); err != nil {
+				w32.MessageBox(
+					window,
+					w32.String(err.Error()),
+					w32.String("Fehler"),
+					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+				)
+				return
+			}
+		}
+		start := codeText.LineStart(p.Line - 1)
+		end := start + strings.IndexByte(codeText.Slice(start, codeText.Len())+"\n", '\n')
+		offset := min(start+p.Column-1, end)
+		pos := int32(utf16Len(codeText.Slice(0, offset)))
+		RichEdit_SetSel(codeEdit, CHARRANGE{Min: pos, Max: pos})
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+		w32.SetFocus(codeEdit)
+	}
+
+	// showTab shows tab i in the code editor. The active tab must have been
+	// stashed before.
+	showTab := func(i int) {
+		t := tabs[i]
+		activeTab = i
+		openFilePath = t.path
+		w32.ShowWindow(lineNumbers, w32.SW_SHOW)
+		w32.EnableWindow(codeEdit, true)
+		w32.EnableWindow(startButton, true)
+		codeText = t.text
+		showCode()
+		startLanguageServer()
+		syncLanguageServer()
+		showDiagnostics()
+		runCheck()
+		openFileDirty = t.dirty
+		Edit_SetSel(codeEdit, t.selStart, t.selEnd)
+		scroll := t.firstLine - w32.Edit_GetFirstVisibleLine(codeEdit)
+		w32.SendMessage(codeEdit, w32.EM_LINESCROLL, 0, uintptr(scroll))
+		TabCtrl_SetCurSel(tabControl, i)
+		updateTitle()
+		layoutControls()
+	}
+
+	switchTab := func(i int) {
+		if i == activeTab || i < 0 || i >= len(tabs) {
+			return
+		}
+		syncLanguageServer()
+		stashActiveTab()
+		showTab(i)
+	}
+
+	// saveTab writes the code of tab i to its file, in the same encoding and
+	// with the same line endings that the file had when it was opened. If the
+	// encoding cannot represent all characters, an interactive save asks the
+	// user whether to switch to UTF-8, otherwise nothing is written and the
+	// error is textfile.ErrUnencodable.
+	saveTab := func(i int, interactive bool) error {
+		t := tabs[i]
+		code := t.text.String()
+		data, err := textfile.Encode(code, t.format)
+		if errors.Is(err, textfile.ErrUnencodable) && interactive {
+			answer, _ := w32.MessageBox(
+				window,
+				w32.String("Die Datei \""+filepath.Base(t.path)+"\" enthält "+
+					"Zeichen, die in der Kodierung "+t.format.Encoding.String()+
+					" nicht gespeichert werden können.\r\n\r\nSoll sie als "+
+					"UTF-8 gespeichert werden?"),
+				w32.String("Kodierung ändern?"),
+				w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+			)
+			if answer != w32.IDYES {
+				return err
+			}
+			t.format.Encoding = textfile.UTF8
+			t.format.BOM = false
+			data, err = textfile.Encode(code, t.format)
+		}
+		if err != nil {
+			return err
+		}
+		if err := fileops.WriteAtomic(t.path, data); err != nil {
+			return err
+		}
+		t.disk = data
+		if i == activeTab {
+			openFileDirty = false
+			updateTitle()
+		} else {
+			tabs[i].dirty = false
+			updateTabLabel(i)
+		}
+		return nil
+	}
+
+	// readCode reads a file for display in codeEdit. It returns the code and
+	// the file's content. If the file cannot be saved back exactly as it was,
+	// the error is textfile.ErrNotRoundTrip.
+	readCode := func(path string) (string, []byte, textfile.Format, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, textfile.Format{}, err
+		}
+		code, format, err := textfile.Decode(data)
+		if strings.ContainsRune(code, 0) {
+			// The edit control cuts off the text at the first 0 character.
+			err = textfile.ErrNotRoundTrip
+		}
+		return code, data, format, err
+	}
+
+	// warnNotRoundTrip tells the user that saving the file will change it.
+	warnNotRoundTrip := func(path string) {
+		w32.MessageBox(
+			window,
+			w32.String("Die Datei \""+filepath.Base(path)+"\" kann nicht "+
+				"unverändert gespeichert werden. Sie enthält gemischte "+
+				"Zeilenenden oder Zeichen, die in ihrer Kodierung ungültig "+
+				"sind. Diese werden beim Speichern vereinheitlicht bzw. "+
+				"ersetzt."),
+			w32.String("Warnung"),
+			w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONWARNING,
+		)
+	}
+
+	// reloadTab replaces the code of tab i with its file's content.
+	reloadTab := func(i int) error {
+		code, data, format, err := readCode(tabs[i].path)
+		if errors.Is(err, textfile.ErrNotRoundTrip) {
+			warnNotRoundTrip(tabs[i].path)
+		} else if err != nil {
+			return err
+		}
+		tabs[i].format = format
+		tabs[i].disk = data
+		if i == activeTab {
+			replaceCode(code)
+			openFileDirty = false
+			updateTitle()
+		} else {
+			tabs[i].text.SetText(code)
+			tabs[i].dirty = false
+			updateTabLabel(i)
+		}
+		return nil
+	}
+
+	reportSaveError := func(err error) {
+		w32.MessageBox(
+			window,
+			w32.String(err.Error()),
+			w32.String("Speichern fehlgeschlagen"),
+			w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+		)
+	}
+
+	// saveFileOrReport saves the open file and tells the user if that fails.
+	saveFileOrReport := func() bool {
+		if activeTab < 0 {
+			return true
+		}
+		if err := saveTab(activeTab, true); err != nil {
+			reportSaveError(err)
+			return false
+		}
+		return true
+	}
+
+	// saveTabs saves all tabs with unsaved changes whose files are inside
+	// folder dir. It tells the user if that fails and returns false.
+	saveTabs := func(dir string) bool {
+		for i, t := range tabs {
+			if tabDirty(i) && paths.IsInside(t.path, dir) {
+				if err := saveTab(i, true); err != nil {
+					reportSaveError(err)
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// confirmCloseTab asks the user whether to save unsaved changes before tab
+	// i is closed. It returns false if the user wants to keep the tab open.
+	confirmCloseTab := func(i int) bool {
+		if !tabDirty(i) {
+			return true
+		}
+		answer, err := w32.MessageBox(
+			window,
+			w32.String("Sollen die Änderungen an \""+filepath.Base(tabs[i].path)+
+				"\" gespeichert werden?"),
+			w32.String("Ungespeicherte Änderungen"),
+			w32.MB_YESNOCANCEL|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+		)
+		if err != nil {
+			return false
+		}
+		switch answer {
+		case w32.IDYES:
+			if err := saveTab(i, true); err != nil {
+				reportSaveError(err)
+				return false
+			}
+			return true
+		case w32.IDNO:
+			return true
+		default:
+			return false
+		}
+	}
+
+	closeTab := func(i int) {
+		if i < 0 || i >= len(tabs) || !confirmCloseTab(i) {
+			return
+		}
+
+		if languageServer != nil {
+			languageServer.CloseDocument(tabs[i].path)
+		}
+		TabCtrl_DeleteItem(tabControl, i)
+		tabs = append(tabs[:i], tabs[i+1:]...)
+
+		if i < activeTab {
+			activeTab--
+			TabCtrl_SetCurSel(tabControl, activeTab)
+			return
+		}
+		if i > activeTab {
+			return
+		}
+
+		activeTab = -1
+		if len(tabs) > 0 {
+			showTab(min(i, len(tabs)-1))
+			return
+		}
+
+		openFilePath = ""
+		codeText = textbuf.New("")
+		showCode()
+		openFileDirty = false
+		w32.EnableWindow(codeEdit, false)
+		w32.EnableWindow(startButton, false)
+		w32.ShowWindow(lineNumbers, w32.SW_HIDE)
+		updateTitle()
+	}
+
+	// moveTab moves the active tab delta places to the right, or to the left
+	// for negative delta.
+	moveTab := func(delta int) {
+		i, j := activeTab, activeTab+delta
+		if i < 0 || j < 0 || j >= len(tabs) {
+			return
+		}
+		tabs[i], tabs[j] = tabs[j], tabs[i]
+		activeTab = j
+		updateTabLabel(i)
+		updateTabLabel(j)
+		TabCtrl_SetCurSel(tabControl, j)
+	}
+
+	showTabMenu := func() {
+		cursor, err := w32.GetCursorPos()
+		if err != nil {
+			return
+		}
+		p, err := w32.ScreenToClient(tabControl, cursor)
+		if err != nil {
+			return
+		}
+		i := TabCtrl_HitTest(tabControl, p)
+		if i < 0 {
+			return
+		}
+		switchTab(i)
+
+		menu := CreatePopupMenu()
+		defer DestroyMenu(menu)
+		AppendMenu(menu, MF_STRING, closeTabShortcutID,
+			"Schließen\tStrg+W")
+		AppendMenu(menu, MF_STRING, moveTabLeftShortcutID,
+			"Nach links verschieben\tStrg+Umschalt+Bild auf")
+		AppendMenu(menu, MF_STRING, moveTabRightShortcutID,
+			"Nach rechts verschieben\tStrg+Umschalt+Bild ab")
+
+		switch TrackPopupMenu(menu, cursor.X, cursor.Y, window) {
+		case closeTabShortcutID:
+			closeTab(activeTab)
+		case moveTabLeftShortcutID:
+			moveTab(-1)
+		case moveTabRightShortcutID:
+			moveTab(1)
+		}
+	}
+
+	// A click with the middle mouse button closes a tab.
+	w32.SetWindowSubclass(
+		tabControl,
+		w32.NewWindowSubclassProc(func(
+			window w32.HWND,
+			message uint32,
+			w, l, subclassID, refData uintptr,
+		) uintptr {
+			if message == w32.WM_MBUTTONUP {
+				p := w32.POINT{
+					X: int32(int16(l & 0xFFFF)),
+					Y: int32(int16((l & 0xFFFF0000) >> 16)),
+				}
+				closeTab(TabCtrl_HitTest(tabControl, p))
+				return 0
+			}
+			return w32.DefSubclassProc(window, message, w, l)
+		}),
+		0,
+		0,
+	)
+
+	// offerModulePathRewrite asks the user to fix the module path of a project
+	// that has an invalid module path or, if oldName is not empty, that was
+	// renamed from oldName.
+	offerModulePathRewrite := func(projectPath, oldName string) {
+		current, wanted, outdated := modpath.Outdated(projectPath, oldName, filepath.Base(projectPath))
+		if !outdated {
+			return
+		}
+
+		if answer, err := w32.MessageBox(
+			window,
+			w32.String("Der Modulpfad \""+current+"\" in go.mod passt nicht "+
+				"zum Projektnamen.\r\n\r\nSoll er zu \""+wanted+"\" geändert "+
+				"werden? Imports innerhalb des Projekts werden ebenfalls "+
+				"angepasst."),
+			w32.String("Modulpfad anpassen?"),
+			w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+		); err != nil || answer != w32.IDYES {
+			return
+		}
+
+		// The open files might import packages of the project, so save them
+		// before rewriting and load the rewritten versions afterwards.
+		if !saveTabs(projectPath) {
+			return
+		}
+		err := modpath.Rewrite(projectPath, wanted)
+		for i, t := range tabs {
+			if paths.IsInside(t.path, projectPath) {
+				if reloadErr := reloadTab(i); err == nil {
+					err = reloadErr
+				}
+			}
+		}
+		if err != nil {
+			w32.MessageBox(
+				window,
+				w32.String(err.Error()),
+				w32.String("Modulpfad anpassen fehlgeschlagen"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+			)
+		}
+	}
+
+	// startJob runs job in the background. While it runs, the start button
+	// becomes a stop button that cancels the job's context and the output
+	// area shows what the job writes to outputBuf.
+	startJob := func(job func(ctx context.Context)) {
+		programRunning = true
+		w32.SendMessage(window, programStartMessage, 0, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopProgram = cancel
+
+		go func(ctx context.Context) {
+			defer func() {
+				programMu.Lock()
+				programRunning = false
+				cancel = func() {}
+				w32.SendMessage(window, programStopMessage, 0, 0)
+				programMu.Unlock()
+			}()
+
+			job(ctx)
+		}(ctx)
+	}
+
+	// prepareBuild makes sure that the project in projectPath has an
+	// up-to-date go.mod file. It returns the environment for the go command and
+	// false if the build cannot continue, in which case the reason was written
+	// to the output.
+	prepareBuild := func(ctx context.Context, projectPath string) ([]string, bool) {
+		projectsPath, err := projectsDir()
+		if err != nil {
+			fmt.Fprintf(outputBuf,
+				"Unable to read projects path: %s\r\n", err)
+			return nil, false
+		}
+
+		if isDone(ctx) {
+			return nil, false
+		}
+
+		modFilePath := filepath.Join(projectPath, "go.mod")
+		if !pathExists(modFilePath) {
+			err := goModInit(ctx, projectPath)
+			if isDone(ctx) {
+				return nil, false
+			}
+			if err != nil {
+				fmt.Fprintf(outputBuf, "%s\r\n", err)
+				return nil, false
+			}
+		}
+
+		goEnv, inWorkspace := goEnvironment(projectPath, projectsPath)
+		args := []string{"mod", "tidy"}
+		if inWorkspace {
+			// go mod tidy ignores the workspace and would look for the other
+			// modules in it on the internet. go work sync keeps the
+			// workspace enabled and updates the requirements of all its
+			// modules instead.
+			args = []string{"work", "sync"}
+		}
+
+		// TODO Always run go mod tidy? Or only on error?
+		prepare := exec.CommandContext(ctx, "go", args...)
+		prepare.Dir = projectPath
+		prepare.Env = goEnv
+		output, err := prepare.CombinedOutput()
+		if isDone(ctx) {
+			return nil, false
+		}
+		if err != nil {
+			fmt.Fprintf(outputBuf,
+				"go %s failed: %s\r\n%s\r\n", strings.Join(args, " "), err, output)
+			return nil, false
+		}
+
+		return goEnv, true
+	}
+
+	startProgram := func() {
+		if openFilePath == "" {
+			return
+		}
+
+		projectsPath, err := projectsDir()
+		if err != nil {
+			return
+		}
+		projectPath := projectFolder(projectsPath, openFilePath)
+		projectName := filepath.Base(projectPath)
+
+		offerModulePathRewrite(projectPath, "")
+
+		if formatBeforeRun {
+			// If the code does not parse, the build reports the errors.
+			formatCode()
+		}
+
+		if !saveTabs(projectPath) {
+			return
+		}
+
+		startJob(func(ctx context.Context) {
+			goEnv, ok := prepareBuild(ctx, projectPath)
+			if !ok {
+				return
+			}
+
+			config, err := project.LoadConfig(projectPath)
+			if err != nil {
+				fmt.Fprintf(outputBuf,
+					"Unable to read project settings: %s\r\n", err)
+				return
+			}
+
+			exeFilePath := filepath.Join(projectPath, projectName+".exe")
+			buildArgs := append([]string{"build"}, config.Build.Flags()...)
+			buildArgs = append(buildArgs, "-o", exeFilePath, ".")
+			build := exec.CommandContext(ctx, "go", buildArgs...)
+			build.Dir = projectPath
+			build.Env = goEnv
+			output, err := build.CombinedOutput()
+			if isDone(ctx) {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(outputBuf,
+					"go build failed: %s\r\n%s\r\n", err, output)
+				return
+			}
+
+			execute := exec.CommandContext(ctx, exeFilePath)
+			execute.Dir = projectPath
+			execute.Stdout = outputBuf
+			execute.Stderr = outputBuf
+			programStdin, err = execute.StdinPipe()
+			if err != nil {
+				fmt.Fprintf(outputBuf,
+					"unable to get program's stdin: %s\r\n", err)
+			}
+			err = execute.Run()
+			if err != nil {
+				fmt.Fprintf(outputBuf, "program failed: %s\r\n", err)
+			}
+		})
+	}
+
+	// exportProgram builds the project of the open file for all export targets
+	// into the project's export folder.
+	exportProgram := func() {
+		if openFilePath == "" {
+			return
+		}
+
+		projectsPath, err := projectsDir()
+		if err != nil {
+			return
+		}
+		projectPath := projectFolder(projectsPath, openFilePath)
+		projectName := filepath.Base(projectPath)
+
+		if !saveTabs(projectPath) {
+			return
+		}
+
+		startJob(func(ctx context.Context) {
+			goEnv, ok := prepareBuild(ctx, projectPath)
+			if !ok {
+				return
+			}
+
+			config, err := project.LoadConfig(projectPath)
+			if err != nil {
+				fmt.Fprintf(outputBuf,
+					"Unable to read project settings: %s\r\n", err)
+				return
+			}
+
+			exportPath := filepath.Join(projectPath, project.ExportFolder)
+			if err := os.MkdirAll(exportPath, 0666); err != nil {
+				fmt.Fprintf(outputBuf,
+					"Unable to create folder '%s': %s\r\n", exportPath, err)
 				return
 			}
-			if err != nil {
-				fmt.Fprintf(outputBuf,
-					"go build failed: %s\r\n%s\r\n", err, output)
-				return
+			fmt.Fprintf(outputBuf, "Exporting to %s\r\n", exportPath)
+
+			for _, target := range project.ExportTargets {
+				outPath := filepath.Join(exportPath, target.FileName(projectName))
+				buildArgs := append([]string{"build"}, config.Build.ExportFlags(target)...)
+				buildArgs = append(buildArgs, "-o", outPath, ".")
+				build := exec.CommandContext(ctx, "go", buildArgs...)
+				build.Dir = projectPath
+				build.Env = append(append([]string{}, goEnv...), target.Env()...)
+				output, err := build.CombinedOutput()
+				if isDone(ctx) {
+					return
+				}
+				if err != nil {
+					fmt.Fprintf(outputBuf,
+						"%s: go build failed: %s\r\n%s\r\n", target, err, output)
+					continue
+				}
+				size := "?"
+				if info, err := os.Stat(outPath); err == nil {
+					size = formatSize(info.Size())
+				}
+				fmt.Fprintf(outputBuf, "%s: ok, %s, %s\r\n",
+					target, size, filepath.Base(outPath))
+			}
+		})
+	}
+
+	readCodeFromRepo := func() (string, error) {
+		const gitURL = `https://github.com/gonutz/gool_exchange.git`
+
+		tempDir, err := ioutil.TempDir("", "gool_exchange_*")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tempDir)
+
+		output, err := exec.Command("git", "clone", gitURL, tempDir).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf(err.Error() + ": " + string(output))
+		}
+
+		content, err := os.ReadFile(filepath.Join(tempDir, "main.go"))
+		if err != nil {
+			return "", err
+		}
+
+		return strings.ReplaceAll(string(content), "\r\n", "\n"), nil
+	}
+
+	synchCodeWithRepo := func() {
+		if answer, err := w32.MessageBox(
+			window,
+			w32.String("Soll der Code mit der Online-Version synchronisiert werden?"),
+			w32.String("Sicher?"),
+			w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+		); err != nil || answer != w32.IDYES {
+			return
+		}
+
+		code, err := readCodeFromRepo()
+		if err != nil {
+			w32.MessageBox(
+				window,
+				w32.String(err.Error()),
+				w32.String("Synchronisation fehlgeschlagen"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+			)
+		} else {
+			replaceCode(code)
+			w32.MessageBox(
+				window,
+				w32.String("Der Code wurde synchronisiert."),
+				w32.String("Synchronisation erfolgreich"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONINFORMATION,
+			)
+		}
+	}
+
+	runF4script := func() {
+		exe, err := os.Executable()
+		if err != nil {
+			w32.MessageBox(
+				window,
+				w32.String("os.Executable ist fehlgeschlagen: "+err.Error()),
+				w32.String("Fehler"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+			)
+			return
+		}
+		exePath := filepath.Dir(exe)
+		scriptPath := filepath.Join(exePath, "f4.bat")
+
+		if fileExists(scriptPath) {
+			output, err := exec.Command("cmd", "/C", scriptPath).CombinedOutput()
+			if err != nil {
+				msg := err.Error() + ": " + string(output)
+				w32.MessageBox(
+					window,
+					w32.String(msg),
+					w32.String("Fehler"),
+					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+				)
+			} else {
+				w32.MessageBox(
+					window,
+					w32.String("Das Skript wurde erfolgreich ausgeführt."),
+					w32.String("Erfolg"),
+					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONINFORMATION,
+				)
+			}
+		} else {
+			w32.MessageBox(
+				window,
+				w32.String("Skript '"+scriptPath+"' nicht gefunden."),
+				w32.String("Fehler"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+			)
+		}
+	}
+
+	onStartButtonClick := func() {
+		programMu.Lock()
+		defer programMu.Unlock()
+
+		if programRunning {
+			stopProgram()
+		} else {
+			startProgram()
+		}
+	}
+
+	w32.SetWindowSubclass(
+		consoleInput,
+		w32.NewWindowSubclassProc(func(
+			window w32.HWND,
+			message uint32,
+			w, l, subclassID, refData uintptr,
+		) uintptr {
+			if message == w32.WM_KEYDOWN && w == w32.VK_RETURN {
+				input, err := w32.GetWindowText(consoleInput)
+				if err == nil {
+					programStdin.Write([]byte(input + "\r\n"))
+				}
+				w32.SetWindowText(consoleInput, nil)
 			}
+			return w32.DefSubclassProc(window, message, w, l)
+		}),
+		0,
+		0,
+	)
 
-			execute := exec.CommandContext(ctx, exeFilePath)
-			execute.Dir = projectPath
-			execute.Stdout = outputBuf
-			execute.Stderr = outputBuf
-			programStdin, err = execute.StdinPipe()
-			if err != nil {
-				fmt.Fprintf(outputBuf,
-					"unable to get program's stdin: %s\r\n", err)
+	readConsoleOutput := func() {
+		latest := outputBuf.Flush()
+		if len(latest) > 0 {
+			text, err := w32.GetWindowText(consoleOutput)
+			if err == nil {
+				text += strings.ReplaceAll(string(latest), "\n", "\r\n")
+				w32.SetWindowText(consoleOutput, w32.String(text))
+				w32.SendMessage(consoleOutput, w32.EM_LINESCROLL, 0, 9999999)
 			}
-			err = execute.Run()
-			if err != nil {
-				fmt.Fprintf(outputBuf, "program failed: %s\r\n", err)
+		}
+	}
+
+	updateFonts := func() error {
+		if fontSize < minFontSize {
+			fontSize = minFontSize
+		}
+		if fontSize > maxFontSize {
+			fontSize = maxFontSize
+		}
+
+		fontHeight := int32(round(-fontSize))
+		tahomaDesc := w32.LOGFONT{
+			Height:         fontHeight,
+			Weight:         w32.FW_NORMAL,
+			CharSet:        w32.DEFAULT_CHARSET,
+			OutPrecision:   w32.OUT_CHARACTER_PRECIS,
+			ClipPrecision:  w32.CLIP_CHARACTER_PRECIS,
+			Quality:        w32.DEFAULT_QUALITY,
+			PitchAndFamily: w32.DEFAULT_PITCH | w32.FF_DONTCARE,
+		}
+		w32.SetString(tahomaDesc.FaceName[:], "Tahoma")
+		labelFont, err = w32.CreateFontIndirect(&tahomaDesc)
+		if err != nil {
+			return err
+		}
+
+		codeFontDesc := w32.LOGFONT{
+			Height:         fontHeight,
+			Weight:         w32.FW_NORMAL,
+			CharSet:        w32.DEFAULT_CHARSET,
+			OutPrecision:   w32.OUT_CHARACTER_PRECIS,
+			ClipPrecision:  w32.CLIP_CHARACTER_PRECIS,
+			Quality:        w32.DEFAULT_QUALITY,
+			PitchAndFamily: w32.DEFAULT_PITCH | w32.FF_DONTCARE,
+		}
+		w32.SetString(codeFontDesc.FaceName[:], "Courier New")
+		codeFont, err = w32.CreateFontIndirect(&codeFontDesc)
+		if err != nil {
+			return err
+		}
+
+		w32.SendMessage(projectsCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(projectTree, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(outlineCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(outlineTree, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(problemsCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(problemsList, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(startButton, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(codeCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(tabControl, w32.WM_SETFONT, uintptr(labelFont), 1)
+		w32.SendMessage(codeEdit, w32.WM_SETFONT, uintptr(codeFont), 1)
+		w32.SendMessage(lineNumbers, w32.WM_SETFONT, uintptr(codeFont), 1)
+		w32.SendMessage(consoleOutput, w32.WM_SETFONT, uintptr(codeFont), 1)
+		w32.SendMessage(consoleInput, w32.WM_SETFONT, uintptr(codeFont), 1)
+		w32.SendMessage(completionList, w32.WM_SETFONT, uintptr(codeFont), 1)
+		w32.SendMessage(hoverPopup, w32.WM_SETFONT, uintptr(codeFont), 1)
+		for _, c := range findControls {
+			font := labelFont
+			if c == findEdit || c == replaceEdit {
+				font = codeFont
 			}
-		}(ctx)
+			w32.SendMessage(c, w32.WM_SETFONT, uintptr(font), 1)
+		}
+
+		tabWidth := 4 * 4
+		w32.SendMessage(codeEdit, w32.EM_SETTABSTOPS, 1, uintptr(unsafe.Pointer(&tabWidth)))
+
+		// The new font replaces the colors.
+		syntax = highlight.Document{}
+		highlightCode()
+		showDiagnostics()
+
+		layoutControls()
+
+		return nil
 	}
 
-	readCodeFromRepo := func() (string, error) {
-		const gitURL = `https://github.com/gonutz/gool_exchange.git`
+	incFontSize := func() error {
+		fontSize += 2
+		return updateFonts()
+	}
 
-		tempDir, err := ioutil.TempDir("", "gool_exchange_*")
+	decFontSize := func() error {
+		fontSize -= 2
+		return updateFonts()
+	}
+
+	if err := updateFonts(); err != nil {
+		return err
+	}
+
+	// updateTitle shows the open file and its project's build profile in the
+	// window title. A * in the title and tab marks unsaved changes.
+	updateTitle = func() {
+		title := "Gool"
+		if openFilePath != "" {
+			title += " - " + openFilePath
+			if openFileDirty {
+				title += "*"
+			}
+			updateTabLabel(activeTab)
+			if root, err := projectsDir(); err == nil {
+				config, err := project.LoadConfig(projectFolder(root, openFilePath))
+				if profile := config.Build.String(); err == nil && profile != "" {
+					title += " [" + profile + "]"
+				}
+			}
+		}
+		w32.SetWindowText(window, w32.String(title))
+	}
+
+	// openFile shows the file in the code editor. It is opened in a new tab
+	// unless it is open already.
+	openFile = func(path string) error {
+		var open []string
+		for _, t := range tabs {
+			open = append(open, t.path)
+		}
+		if i := openfiles.Index(open, path); i != -1 {
+			switchTab(i)
+			return nil
+		}
+
+		code, data, format, err := readCode(path)
+		if errors.Is(err, textfile.ErrNotRoundTrip) {
+			warnNotRoundTrip(path)
+		} else if err != nil {
+			return err
+		}
+
+		stashActiveTab()
+		tabs = append(tabs, &editorTab{
+			path:   path,
+			format: format,
+			text:   textbuf.New(code),
+			disk:   data,
+		})
+		TabCtrl_InsertItem(tabControl, len(tabs)-1, filepath.Base(path))
+		showTab(len(tabs) - 1)
+
+		return nil
+	}
+
+	fileTreeItemToPath := map[w32.HTREEITEM]string{}
+	folderTreeItemToPath := map[w32.HTREEITEM]string{}
+	pathToTreeItem := map[string]w32.HTREEITEM{}
+	// shownProjects is what the project tree shows right now.
+	var shownProjects []treediff.Node
+
+	var insertTreeItem func(parent, after w32.HTREEITEM, n treediff.Node) error
+	insertTreeItem = func(parent, after w32.HTREEITEM, n treediff.Node) error {
+		item, err := w32.TreeView_InsertItem(projectTree, &w32.TVINSERTSTRUCT{
+			Parent:      parent,
+			InsertAfter: after,
+			ItemEx: w32.TVITEMEX{
+				Mask: w32.TVIF_TEXT,
+				Text: w32.String(filepath.Base(n.Key)),
+			},
+		})
 		if err != nil {
-			return "", err
+			return err
 		}
-		defer os.RemoveAll(tempDir)
 
-		output, err := exec.Command("git", "clone", gitURL, tempDir).CombinedOutput()
+		pathToTreeItem[n.Key] = item
+		if n.Folder {
+			folderTreeItemToPath[item] = n.Key
+		} else {
+			fileTreeItemToPath[item] = n.Key
+		}
+
+		after = w32.TVI_FIRST
+		for _, child := range n.Children {
+			if err := insertTreeItem(item, after, child); err != nil {
+				return err
+			}
+			after = pathToTreeItem[child.Key]
+		}
+		return nil
+	}
+
+	var forgetTreeItem func(n treediff.Node)
+	forgetTreeItem = func(n treediff.Node) {
+		item := pathToTreeItem[n.Key]
+		delete(pathToTreeItem, n.Key)
+		delete(fileTreeItemToPath, item)
+		delete(folderTreeItemToPath, item)
+		for _, child := range n.Children {
+			forgetTreeItem(child)
+		}
+	}
+
+	// updateProjects shows the current files and folders in the project
+	// tree. Only the items that changed are inserted or removed, so the tree
+	// keeps its expanded folders and the selection.
+	updateProjects := func() error {
+		projects, err := projectsDir()
 		if err != nil {
-			return "", fmt.Errorf(err.Error() + ": " + string(output))
+			return err
 		}
 
-		content, err := os.ReadFile(filepath.Join(tempDir, "main.go"))
+		tree, err := readProjectTree(projects, showHidden)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		code := string(content)
-		code = strings.ReplaceAll(code, "\n", "\r\n")
-		return code, nil
+		nodes := tree.nodes()
+		for _, c := range treediff.Diff(shownProjects, nodes) {
+			if c.Kind == treediff.Remove {
+				w32.TreeView_DeleteItem(projectTree, pathToTreeItem[c.Node.Key])
+				forgetTreeItem(c.Node)
+				continue
+			}
+			parent, after := w32.TVI_ROOT, w32.TVI_FIRST
+			if c.Parent != "" {
+				parent = pathToTreeItem[c.Parent]
+			}
+			if c.After != "" {
+				after = pathToTreeItem[c.After]
+			}
+			if err := insertTreeItem(parent, after, c.Node); err != nil {
+				// Start from scratch next time.
+				w32.TreeView_DeleteAllItems(projectTree)
+				fileTreeItemToPath = map[w32.HTREEITEM]string{}
+				folderTreeItemToPath = map[w32.HTREEITEM]string{}
+				pathToTreeItem = map[string]w32.HTREEITEM{}
+				shownProjects = nil
+				return err
+			}
+		}
+		shownProjects = nodes
+		return nil
 	}
 
-	synchCodeWithRepo := func() {
-		if answer, err := w32.MessageBox(
-			window,
-			w32.String("Soll der Code mit der Online-Version synchronisiert werden?"),
-			w32.String("Sicher?"),
-			w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
-		); err != nil || answer != w32.IDYES {
+	if err := updateProjects(); err != nil {
+		return err
+	}
+
+	// toggleWorkspace adds the project in dir to the go.work file that applies
+	// to it or removes it from there. New workspaces are created in the folder
+	// containing the project, which is either the projects folder itself or a
+	// group folder.
+	toggleWorkspace := func(dir string) {
+		root, err := projectsDir()
+		if err != nil {
 			return
 		}
 
-		code, err := readCodeFromRepo()
+		work := workspace.Find(dir, root)
+		if work != "" && workspace.Contains(work, dir) {
+			err = workspace.Remove(work, dir)
+		} else {
+			if work == "" {
+				work = filepath.Join(filepath.Dir(dir), workspace.FileName)
+			}
+			if !fileExists(filepath.Join(dir, "go.mod")) {
+				err = goModInit(context.Background(), dir)
+			}
+			if err == nil {
+				err = workspace.Add(work, dir)
+			}
+		}
 		if err != nil {
 			w32.MessageBox(
 				window,
 				w32.String(err.Error()),
-				w32.String("Synchronisation fehlgeschlagen"),
+				w32.String("Workspace ändern fehlgeschlagen"),
 				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
 			)
-		} else {
-			w32.SetWindowText(codeEdit, w32.String(code))
+		}
+
+		updateProjects()
+	}
+
+	// showProjectDependencies opens the dependency window for the project in
+	// dir, creating its go.mod file if necessary.
+	showProjectDependencies := func(dir string) {
+		root, err := projectsDir()
+		if err == nil && !fileExists(filepath.Join(dir, "go.mod")) {
+			err = goModInit(context.Background(), dir)
+			updateProjects()
+		}
+		if err == nil {
+			env, _ := goEnvironment(dir, root)
+			err = showDependencies(window, labelFont, dir, env)
+		}
+		if err != nil {
 			w32.MessageBox(
 				window,
-				w32.String("Der Code wurde synchronisiert."),
-				w32.String("Synchronisation erfolgreich"),
-				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONINFORMATION,
+				w32.String(err.Error()),
+				w32.String("Fehler"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+			)
+		}
+	}
+
+	// goToLine asks the user for a line number and moves the caret there.
+	goToLine := func() {
+		code := codeText.String()
+		caret := byteOffset(code, int(RichEdit_GetSel(codeEdit).Min))
+		lineCount := codeText.LineCount()
+		text, ok := inputBox(
+			window, labelFont, "Gehe zu Zeile",
+			fmt.Sprintf("Zeilennummer (1 bis %d):", lineCount),
+			strconv.Itoa(codeText.LineOf(caret)+1),
+		)
+		if !ok {
+			return
+		}
+		line, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			return
+		}
+		showLine(max(1, min(line, lineCount)))
+	}
+
+	// selectSymbol selects the name of the symbol in codeEdit and scrolls it
+	// into view.
+	selectSymbol := func(s symbols.Symbol) {
+		code := codeText.String()
+		if s.Offset > len(code) {
+			return
+		}
+		name := strings.TrimPrefix(s.Name, s.Receiver+".")
+		start := utf16Len(code[:s.Offset])
+		RichEdit_SetSel(codeEdit, CHARRANGE{
+			Min: int32(start),
+			Max: int32(start + utf16Len(name)),
+		})
+		w32.SendMessage(codeEdit, w32.EM_SCROLLCARET, 0, 0)
+	}
+
+	// goToSymbol lets the user choose a declaration of the open file and
+	// selects its name.
+	goToSymbol := func() {
+		s, ok := pickSymbol(window, labelFont, symbols.Parse(codeText.String()))
+		if !ok {
+			return
+		}
+		selectSymbol(s)
+		w32.SetFocus(codeEdit)
+	}
+
+	// showSearch opens the window to search in the project of the open file or
+	// in all projects. Results are opened in the editor.
+	showSearch := func() {
+		root, err := projectsDir()
+		if err == nil {
+			dir := ""
+			if openFilePath != "" {
+				dir = projectFolder(root, openFilePath)
+			}
+			err = showProjectSearch(window, labelFont, dir, root, func(path string, line int) {
+				if err := openFile(path); err != nil {
+					w32.MessageBox(
+						window,
+						w32.String(err.Error()),
+						w32.String("Fehler"),
+						w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+					)
+					return
+				}
+				if line > 0 {
+					showLine(line)
+				}
+			})
+		}
+		if err != nil {
+			w32.MessageBox(
+				window,
+				w32.String(err.Error()),
+				w32.String("Fehler"),
+				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
 			)
 		}
 	}
 
-	runF4script := func() {
-		exe, err := os.Executable()
+	// showBuildProfileMenu lets the user choose the go build flags for the
+	// project of the open file. The menu appears at the given screen position.
+	showBuildProfileMenu := func(x, y int32) {
+		root, err := projectsDir()
+		if err != nil || openFilePath == "" {
+			return
+		}
+		dir := projectFolder(root, openFilePath)
+		config, err := project.LoadConfig(dir)
 		if err != nil {
 			w32.MessageBox(
 				window,
-				w32.String("os.Executable ist fehlgeschlagen: "+err.Error()),
-				w32.String("Fehler"),
+				w32.String(err.Error()),
+				w32.String("Projekt-Einstellungen fehlerhaft"),
 				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
 			)
 			return
 		}
-		exePath := filepath.Dir(exe)
-		scriptPath := filepath.Join(exePath, "f4.bat")
 
-		if fileExists(scriptPath) {
-			output, err := exec.Command("cmd", "/C", scriptPath).CombinedOutput()
-			if err != nil {
-				msg := err.Error() + ": " + string(output)
-				w32.MessageBox(
-					window,
-					w32.String(msg),
-					w32.String("Fehler"),
-					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
-				)
-			} else {
-				w32.MessageBox(
-					window,
-					w32.String("Das Skript wurde erfolgreich ausgeführt."),
-					w32.String("Erfolg"),
-					w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONINFORMATION,
-				)
+		menu := CreatePopupMenu()
+		defer DestroyMenu(menu)
+		AppendMenu(menu, checkedIf(config.Build.Race), raceMenuID,
+			"Race-Detektor (-race)")
+		AppendMenu(menu, checkedIf(config.Build.NoOptimizations), noOptimizationsMenuID,
+			"Optimierungen aus, zum Debuggen (-N -l)")
+		AppendMenu(menu, checkedIf(config.Build.GUI), guiMenuID,
+			"GUI-Programm ohne Konsolenfenster (-H=windowsgui)")
+		AppendMenu(menu, checkedIf(len(config.Build.Tags) > 0), buildTagsMenuID,
+			"Build-Tags...")
+
+		switch TrackPopupMenu(menu, x, y, window) {
+		case raceMenuID:
+			config.Build.Race = !config.Build.Race
+		case noOptimizationsMenuID:
+			config.Build.NoOptimizations = !config.Build.NoOptimizations
+		case guiMenuID:
+			config.Build.GUI = !config.Build.GUI
+		case buildTagsMenuID:
+			tags, ok := inputBox(
+				window, labelFont, "Build-Tags",
+				"Build-Tags, getrennt durch Kommas:",
+				strings.Join(config.Build.Tags, ","),
+			)
+			if !ok {
+				return
 			}
-		} else {
+			config.Build.Tags = project.ParseTags(tags)
+		default:
+			return
+		}
+
+		if err := project.SaveConfig(dir, config); err != nil {
 			w32.MessageBox(
 				window,
-				w32.String("Skript '"+scriptPath+"' nicht gefunden."),
-				w32.String("Fehler"),
+				w32.String(err.Error()),
+				w32.String("Speichern fehlgeschlagen"),
 				w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
 			)
 		}
+		updateTitle()
 	}
 
-	onStartButtonClick := func() {
-		programMu.Lock()
-		defer programMu.Unlock()
-
-		if programRunning {
-			stopProgram()
-		} else {
-			startProgram()
-		}
+	showError := func(err error) {
+		w32.MessageBox(
+			window,
+			w32.String(err.Error()),
+			w32.String("Fehler"),
+			w32.MB_OK|w32.MB_TOPMOST|w32.MB_ICONERROR,
+		)
 	}
 
-	w32.SetWindowSubclass(
-		consoleInput,
-		w32.NewWindowSubclassProc(func(
-			window w32.HWND,
-			message uint32,
-			w, l, subclassID, refData uintptr,
-		) uintptr {
-			if message == w32.WM_KEYDOWN && w == w32.VK_RETURN {
-				input, err := w32.GetWindowText(consoleInput)
-				if err == nil {
-					programStdin.Write([]byte(input + "\r\n"))
-				}
-				w32.SetWindowText(consoleInput, nil)
-			}
-			return w32.DefSubclassProc(window, message, w, l)
-		}),
-		0,
-		0,
-	)
-
-	readConsoleOutput := func() {
-		latest := outputBuf.Flush()
-		if len(latest) > 0 {
-			text, err := w32.GetWindowText(consoleOutput)
-			if err == nil {
-				text += strings.ReplaceAll(string(latest), "\n", "\r\n")
-				w32.SetWindowText(consoleOutput, w32.String(text))
-				w32.SendMessage(consoleOutput, w32.EM_LINESCROLL, 0, 9999999)
-			}
+	// showFileError reports an error of the fileops package.
+	showFileError := func(err error) {
+		switch {
+		case errors.Is(err, fileops.ErrInvalidName):
+			err = errors.New("Der Name ist ungültig. Er darf nicht leer sein, " +
+				"nicht mit einem Punkt oder Leerzeichen enden und keines dieser " +
+				`Zeichen enthalten: \ / : * ? " < > |`)
+		case errors.Is(err, fileops.ErrExists):
+			err = errors.New("Es gibt bereits eine Datei oder einen Ordner mit diesem Namen.")
 		}
+		showError(err)
 	}
 
-	updateFonts := func() error {
-		if fontSize < minFontSize {
-			fontSize = minFontSize
+	// newProject creates a project in folder parent from a template that the
+	// user chooses.
+	newProject := func(parent string) {
+		dir, err := templatesDir()
+		if err != nil {
+			return
 		}
-		if fontSize > maxFontSize {
-			fontSize = maxFontSize
+		list, err := templates.List(dir)
+		if err != nil {
+			showError(err)
+			return
+		}
+		var names []string
+		for _, t := range list {
+			names = append(names, t.Name)
+		}
+		i, ok := pickItem(window, labelFont, "Neues Projekt", "Vorlage:", names)
+		if !ok {
+			return
 		}
 
-		fontHeight := int32(round(-fontSize))
-		tahomaDesc := w32.LOGFONT{
-			Height:         fontHeight,
-			Weight:         w32.FW_NORMAL,
-			CharSet:        w32.DEFAULT_CHARSET,
-			OutPrecision:   w32.OUT_CHARACTER_PRECIS,
-			ClipPrecision:  w32.CLIP_CHARACTER_PRECIS,
-			Quality:        w32.DEFAULT_QUALITY,
-			PitchAndFamily: w32.DEFAULT_PITCH | w32.FF_DONTCARE,
+		name, ok := inputBox(window, labelFont, "Neues Projekt", "Name des Projekts:", "")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return
 		}
-		w32.SetString(tahomaDesc.FaceName[:], "Tahoma")
-		labelFont, err = w32.CreateFontIndirect(&tahomaDesc)
-		if err != nil {
-			return err
+		if err := fileops.CheckName(name); err != nil {
+			showFileError(err)
+			return
+		}
+		if pathExists(filepath.Join(parent, name)) {
+			showError(errors.New("Es gibt bereits ein Projekt mit dem Namen " + name + "."))
+			return
 		}
 
-		codeFontDesc := w32.LOGFONT{
-			Height:         fontHeight,
-			Weight:         w32.FW_NORMAL,
-			CharSet:        w32.DEFAULT_CHARSET,
-			OutPrecision:   w32.OUT_CHARACTER_PRECIS,
-			ClipPrecision:  w32.CLIP_CHARACTER_PRECIS,
-			Quality:        w32.DEFAULT_QUALITY,
-			PitchAndFamily: w32.DEFAULT_PITCH | w32.FF_DONTCARE,
+		files, err := list[i].Create(filepath.Join(parent, name))
+		updateProjects()
+		if err == nil {
+			if main := templates.MainFile(files); main != "" {
+				err = openFile(main)
+			}
 		}
-		w32.SetString(codeFontDesc.FaceName[:], "Courier New")
-		codeFont, err = w32.CreateFontIndirect(&codeFontDesc)
 		if err != nil {
-			return err
+			showError(err)
 		}
+	}
 
-		w32.SendMessage(projectsCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
-		w32.SendMessage(projectTree, w32.WM_SETFONT, uintptr(labelFont), 1)
-		w32.SendMessage(startButton, w32.WM_SETFONT, uintptr(labelFont), 1)
-		w32.SendMessage(codeCaption, w32.WM_SETFONT, uintptr(labelFont), 1)
-		w32.SendMessage(codeEdit, w32.WM_SETFONT, uintptr(codeFont), 1)
-		w32.SendMessage(lineNumbers, w32.WM_SETFONT, uintptr(codeFont), 1)
-		w32.SendMessage(consoleOutput, w32.WM_SETFONT, uintptr(codeFont), 1)
-		w32.SendMessage(consoleInput, w32.WM_SETFONT, uintptr(codeFont), 1)
+	// moveTabs updates the tabs of files that were moved from path, which is
+	// a file or folder, to newPath.
+	moveTabs := func(path, newPath string) {
+		for i, t := range tabs {
+			var moved string
+			if paths.Same(t.path, path) {
+				moved = newPath
+			} else if paths.IsInside(t.path, path) {
+				moved = filepath.Join(newPath, t.path[len(path)+1:])
+			} else {
+				continue
+			}
+			if languageServer != nil {
+				languageServer.CloseDocument(t.path)
+			}
+			t.path = moved
+			if i == activeTab {
+				openFilePath = moved
+				startLanguageServer()
+				syncLanguageServer()
+			}
+			updateTabLabel(i)
+		}
+		updateTitle()
+	}
 
-		tabWidth := 4 * 4
-		w32.SendMessage(codeEdit, w32.EM_SETTABSTOPS, 1, uintptr(unsafe.Pointer(&tabWidth)))
+	// closeDeletedTabs closes the tabs of the files in path, which was
+	// deleted, without saving them.
+	closeDeletedTabs := func(path string) {
+		for i := len(tabs) - 1; i >= 0; i-- {
+			p := tabs[i].path
+			if !paths.Same(p, path) && !paths.IsInside(p, path) {
+				continue
+			}
+			if i == activeTab {
+				openFileDirty = false
+			}
+			tabs[i].dirty = false
+			closeTab(i)
+		}
+	}
 
-		layoutControls()
+	newFile := func(dir string) {
+		name, ok := inputBox(window, labelFont, "Neue Datei",
+			"Name der Datei (ohne Endung wird .go angehängt):", "")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return
+		}
+		path, err := fileops.NewFile(dir, name)
+		if err != nil {
+			showFileError(err)
+			return
+		}
+		updateProjects()
+		if err := openFile(path); err != nil {
+			showError(err)
+		}
+	}
 
-		return nil
+	// moveInWorkspace updates the go.work file that applies to the renamed
+	// or deleted path, in case it uses projects in it. newPath is "" if path
+	// was deleted.
+	moveInWorkspace := func(path, newPath string) {
+		root, err := projectsDir()
+		if err != nil {
+			return
+		}
+		work := workspace.Find(filepath.Dir(path), root)
+		if work == "" {
+			return
+		}
+		if err := workspace.Move(work, path, newPath); err != nil {
+			showError(err)
+		}
 	}
 
-	incFontSize := func() error {
-		fontSize += 2
-		return updateFonts()
+	renamePath := func(path string) {
+		name, ok := inputBox(window, labelFont, "Umbenennen", "Neuer Name:", filepath.Base(path))
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || name == filepath.Base(path) {
+			return
+		}
+		newPath, err := fileops.Rename(path, name)
+		if err != nil {
+			showFileError(err)
+			return
+		}
+		moveTabs(path, newPath)
+		moveInWorkspace(path, newPath)
+		updateProjects()
+		if fileExists(filepath.Join(newPath, "go.mod")) {
+			offerModulePathRewrite(newPath, filepath.Base(path))
+		}
 	}
 
-	decFontSize := func() error {
-		fontSize -= 2
-		return updateFonts()
+	duplicatePath := func(path string) {
+		copyPath, err := fileops.Duplicate(path)
+		if err != nil {
+			showFileError(err)
+		} else if fileExists(filepath.Join(copyPath, "go.mod")) {
+			// Two modules with the same path cannot be used together, so
+			// the copy is named after its folder. If the original is part
+			// of a workspace, the copy is as well.
+			err := modpath.Rewrite(copyPath, modpath.FromName(filepath.Base(copyPath)))
+			if root, rootErr := projectsDir(); err == nil && rootErr == nil {
+				work := workspace.Find(path, root)
+				if work != "" && workspace.Contains(work, path) {
+					err = workspace.Add(work, copyPath)
+				}
+			}
+			if err != nil {
+				showError(err)
+			}
+		}
+		updateProjects()
 	}
 
-	if err := updateFonts(); err != nil {
-		return err
+	deletePath := func(path string) {
+		trash, err := trashDir()
+		if err != nil {
+			return
+		}
+		answer, err := w32.MessageBox(
+			window,
+			w32.String("Soll \""+filepath.Base(path)+"\" gelöscht werden?\n\n"+
+				"Gelöschte Dateien kommen in den Ordner "+trash+
+				", dort können sie wiederhergestellt werden."),
+			w32.String("Löschen"),
+			w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+		)
+		if err != nil || answer != w32.IDYES {
+			return
+		}
+		if _, err := fileops.Delete(path, trash); err != nil {
+			showFileError(err)
+			return
+		}
+		closeDeletedTabs(path)
+		moveInWorkspace(path, "")
+		updateProjects()
 	}
 
-	openFile := func(path string) error {
-		data, err := os.ReadFile(path)
+	// showProjectMenu shows the context menu of the project tree item under
+	// the mouse or, if there is none, for the projects folder.
+	showProjectMenu := func() {
+		cursor, err := w32.GetCursorPos()
 		if err != nil {
-			return err
+			return
+		}
+		p, err := w32.ScreenToClient(projectTree, cursor)
+		if err != nil {
+			return
+		}
+		root, err := projectsDir()
+		if err != nil {
+			return
 		}
 
-		openFilePath = path
+		menu := CreatePopupMenu()
+		defer DestroyMenu(menu)
 
-		code := string(data)
-		code = strings.ReplaceAll(code, "\r", "")
-		code = strings.ReplaceAll(code, "\n", "\r\n")
-		w32.ShowWindow(lineNumbers, w32.SW_SHOW)
-		w32.EnableWindow(codeEdit, true)
-		w32.EnableWindow(startButton, true)
-		w32.SetWindowText(codeEdit, w32.String(code))
-		w32.SetWindowText(window, w32.String("Gool - "+path))
-		layoutControls()
+		item, _ := w32.TreeView_HitTest(projectTree, p)
+		if item == 0 {
+			AppendMenu(menu, MF_STRING, newProjectShortcutID, "Neues Projekt...\tStrg+N")
+			if TrackPopupMenu(menu, cursor.X, cursor.Y, window) == newProjectShortcutID {
+				newProject(root)
+			}
+			return
+		}
+		w32.TreeView_SelectItem(projectTree, item)
 
-		return nil
-	}
+		path, isFile := fileTreeItemToPath[item]
+		dir, isFolder := folderTreeItemToPath[item]
+		if isFolder {
+			path = dir
+		} else if isFile {
+			dir = filepath.Dir(path)
+		} else {
+			return
+		}
 
-	var fillTree func(parent, prev w32.HTREEITEM, f *folder, itemToPath map[w32.HTREEITEM]string) error
-	fillTree = func(parent, prev w32.HTREEITEM, f *folder, itemToPath map[w32.HTREEITEM]string) error {
-		for _, folder := range f.folders {
-			var err error
-			prev, err = w32.TreeView_InsertItem(projectTree, &w32.TVINSERTSTRUCT{
-				Parent:      parent,
-				InsertAfter: prev,
-				ItemEx: w32.TVITEMEX{
-					Mask: w32.TVIF_TEXT,
-					Text: w32.String(filepath.Base(folder.path)),
-				},
-			})
-			if err != nil {
-				return err
+		if isFolder {
+			AppendMenu(menu, MF_STRING, newProjectShortcutID, "Neues Projekt...")
+		}
+		AppendMenu(menu, MF_STRING, newFileMenuID, "Neue Datei...")
+		AppendMenu(menu, MF_SEPARATOR, 0, "")
+		AppendMenu(menu, MF_STRING, renameMenuID, "Umbenennen...")
+		AppendMenu(menu, MF_STRING, duplicateMenuID, "Duplizieren")
+		AppendMenu(menu, MF_STRING, deleteMenuID, "Löschen")
+		AppendMenu(menu, MF_SEPARATOR, 0, "")
+		AppendMenu(menu, MF_STRING, revealMenuID, "Im Explorer zeigen")
+		if isFolder {
+			work := workspace.Find(dir, root)
+			var workspaceFlags uint32 = MF_STRING
+			if work != "" && workspace.Contains(work, dir) {
+				workspaceFlags |= MF_CHECKED
 			}
+			AppendMenu(menu, MF_SEPARATOR, 0, "")
+			AppendMenu(menu, workspaceFlags, workspaceMenuID, "Im Workspace (go.work)")
+			AppendMenu(menu, MF_STRING, dependenciesMenuID, "Abhängigkeiten...\tF6")
+		}
 
-			if err := fillTree(prev, w32.TVI_ROOT, folder, itemToPath); err != nil {
-				return err
+		switch TrackPopupMenu(menu, cursor.X, cursor.Y, window) {
+		case newProjectShortcutID:
+			// New projects go next to the project that contains dir, other
+			// folders are groups of projects.
+			parent := dir
+			project := projectFolder(root, filepath.Join(dir, "main.go"))
+			if fileExists(filepath.Join(project, "go.mod")) {
+				parent = filepath.Dir(project)
 			}
+			newProject(parent)
+		case newFileMenuID:
+			newFile(dir)
+		case renameMenuID:
+			renamePath(path)
+		case duplicateMenuID:
+			duplicatePath(path)
+		case deleteMenuID:
+			deletePath(path)
+		case revealMenuID:
+			exec.Command("explorer", "/select,"+path).Start()
+		case workspaceMenuID:
+			toggleWorkspace(dir)
+		case dependenciesMenuID:
+			showProjectDependencies(dir)
 		}
+	}
 
-		for _, file := range f.files {
-			var err error
-			prev, err = w32.TreeView_InsertItem(projectTree, &w32.TVINSERTSTRUCT{
-				Parent:      parent,
-				InsertAfter: prev,
-				ItemEx: w32.TVITEMEX{
-					Mask: w32.TVIF_TEXT,
-					Text: w32.String(filepath.Base(file)),
-				},
-			})
-			if err != nil {
-				return err
+	// editIgnorePatterns opens the .goolignore file of the projects folder,
+	// which applies to all projects. It is created with an explanation first.
+	editIgnorePatterns := func() {
+		root, err := projectsDir()
+		if err != nil {
+			return
+		}
+		path := filepath.Join(root, ignore.FileName)
+		if !pathExists(path) {
+			defaults := strings.Split(strings.TrimSpace(ignore.Defaults), "\n")
+			help := "# Muster für Dateien und Ordner, die im Projektbaum ausgeblendet\n" +
+				"# werden, in der Schreibweise von .gitignore. Eine " + ignore.FileName + "\n" +
+				"# in einem Projekt gilt nur für dieses Projekt.\n" +
+				"#\n" +
+				"# Diese Muster gelten immer:\n" +
+				"#   " + strings.Join(defaults, "\n#   ") + "\n" +
+				"#\n" +
+				"# Mit ! am Anfang wird eine Datei wieder angezeigt, z.B. !go.sum\n"
+			if err := os.WriteFile(path, []byte(help), 0666); err != nil {
+				showError(err)
+				return
 			}
-			itemToPath[prev] = file
 		}
-
-		return nil
+		if err := openFile(path); err != nil {
+			showError(err)
+		}
 	}
 
-	fileTreeItemToPath := map[w32.HTREEITEM]string{}
+	// reloadChangedFiles reloads the tabs of files that were changed by other
+	// programs. If a tab has unsaved changes as well, the user decides which
+	// version to keep. Changes that gool made itself are ignored, even if the
+	// user typed since saving.
+	reloadChangedFiles := func(changedPaths []string) {
+		changed := map[string]bool{}
+		for _, path := range changedPaths {
+			changed[paths.Key(path)] = true
+		}
+		for i := 0; i < len(tabs); i++ {
+			t := tabs[i]
+			if !changed[paths.Key(t.path)] {
+				continue
+			}
+			data, err := os.ReadFile(t.path)
+			if err != nil {
+				// Deleted files stay open, they can be saved again.
+				continue
+			}
+			action := openfiles.OnChange(t.disk, data, tabDirty(i))
+			if action == openfiles.Keep {
+				continue
+			}
+			if action == openfiles.Ask {
+				answer, err := w32.MessageBox(
+					window,
+					w32.String("Die Datei \""+filepath.Base(t.path)+"\" wurde "+
+						"außerhalb von gool geändert.\r\n\r\nSoll sie neu geladen "+
+						"werden? Die ungespeicherten Änderungen im Editor gehen "+
+						"dabei verloren."),
+					w32.String("Datei geändert"),
+					w32.MB_YESNO|w32.MB_TOPMOST|w32.MB_ICONQUESTION,
+				)
+				if err != nil || answer != w32.IDYES {
+					// Ask only once about this version of the file.
+					t.disk = data
+					continue
+				}
+			}
+			if err := reloadTab(i); err != nil {
+				showError(err)
+			}
+		}
+	}
 
-	updateProjects := func() error {
-		projects, err := projectsDir()
-		if err != nil {
-			return err
+	// The projects folder is watched so that the project tree and the open
+	// files follow the changes of other programs and builds.
+	var (
+		// changedFilesMu protects changedFiles, which is set by the watcher.
+		changedFilesMu sync.Mutex
+		changedFiles   []string
+		// handlingChanges is true while the user is asked about a changed
+		// file, new changes are handled afterwards.
+		handlingChanges bool
+	)
+	if root, err := projectsDir(); err == nil {
+		if watcher, err := watch.New(root); err == nil {
+			defer watcher.Close()
+			go func() {
+				for changes := range watcher.Changes {
+					changedFilesMu.Lock()
+					changedFiles = append(changedFiles, changes...)
+					changedFilesMu.Unlock()
+					PostMessage(window, filesChangedMessage, 0, 0)
+				}
+			}()
 		}
+	}
 
-		tree, err := readProjectTree(projects)
-		if err != nil {
-			return err
+	handleChangedFiles := func() {
+		if handlingChanges {
+			return
 		}
+		handlingChanges = true
+		defer func() { handlingChanges = false }()
 
-		w32.TreeView_DeleteAllItems(projectTree)
-		fileTreeItemToPath = map[w32.HTREEITEM]string{}
-		return fillTree(0, w32.TVI_ROOT, tree, fileTreeItemToPath)
-	}
+		changedFilesMu.Lock()
+		changed := changedFiles
+		changedFiles = nil
+		changedFilesMu.Unlock()
+		if len(changed) == 0 {
+			return
+		}
+		updateProjects()
+		reloadChangedFiles(changed)
 
-	if err := updateProjects(); err != nil {
-		return err
+		changedFilesMu.Lock()
+		if len(changedFiles) > 0 {
+			PostMessage(window, filesChangedMessage, 0, 0)
+		}
+		changedFilesMu.Unlock()
 	}
 
 	type settings struct {
-		FontSize float64
-		OpenFile string
+		FontSize  float64
+		OpenFile  string
+		OpenFiles []string
+		AutoSave  bool
+		// FormatBeforeRun formats the open file when the program is started.
+		FormatBeforeRun bool
+		// FixImports formats with goimports instead of gofmt.
+		FixImports bool
+		// ShowHidden shows the files in the project tree that start with a
+		// dot or are hidden by .goolignore files.
+		ShowHidden bool
 	}
 
 	settingsPath := func() string {
@@ -804,6 +3509,14 @@ func run() error {
 		s := settings{
 			FontSize: fontSize,
 			OpenFile: openFilePath,
+			AutoSave: autoSave,
+
+			FormatBeforeRun: formatBeforeRun,
+			FixImports:      fixImports,
+			ShowHidden:      showHidden,
+		}
+		for _, t := range tabs {
+			s.OpenFiles = append(s.OpenFiles, t.path)
 		}
 		data, err := json.Marshal(s)
 		if err != nil {
@@ -820,19 +3533,122 @@ func run() error {
 		var s settings
 		if json.Unmarshal(data, &s) == nil {
 			fontSize = s.FontSize
+			autoSave = s.AutoSave
+			formatBeforeRun = s.FormatBeforeRun
+			fixImports = s.FixImports
+			if s.ShowHidden {
+				showHidden = true
+				updateProjects()
+			}
 			updateFonts()
-			if pathExists(s.OpenFile) {
+			for _, path := range s.OpenFiles {
+				if fileExists(path) {
+					openFile(path)
+				}
+			}
+			if fileExists(s.OpenFile) {
 				openFile(s.OpenFile)
 			}
 		}
 	}
 
+	mainMenu := CreateMenu()
+
+	fileMenu := CreatePopupMenu()
+	AppendMenu(fileMenu, MF_STRING, saveShortcutID, "&Speichern\tStrg+S")
+	AppendMenu(fileMenu, MF_STRING, closeTabShortcutID, "Tab s&chließen\tStrg+W")
+	AppendMenu(fileMenu, checkedIf(autoSave), autoSaveMenuID, "&Automatisch speichern")
+	AppendMenu(fileMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(fileMenu, MF_STRING, exportShortcutID, "&Exportieren\tStrg+E")
+	AppendMenu(fileMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(fileMenu, MF_STRING, quitMenuID, "&Beenden")
+	AppendMenu(mainMenu, MF_POPUP, uintptr(fileMenu), "&Datei")
+
+	editMenu := CreatePopupMenu()
+	AppendMenu(editMenu, MF_STRING, undoShortcutID, "&Rückgängig\tStrg+Z")
+	AppendMenu(editMenu, MF_STRING, redoShortcutID, "&Wiederholen\tStrg+Y")
+	AppendMenu(editMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(editMenu, MF_STRING, formatShortcutID, "&Formatieren\tStrg+Umschalt+F")
+	AppendMenu(editMenu, checkedIf(formatBeforeRun), formatBeforeRunMenuID, "Vor dem Start f&ormatieren")
+	AppendMenu(editMenu, checkedIf(fixImports), fixImportsMenuID, "&Imports automatisch anpassen (goimports)")
+	AppendMenu(editMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(editMenu, MF_STRING, findShortcutID, "&Suchen...\tStrg+F")
+	AppendMenu(editMenu, MF_STRING, replaceShortcutID, "&Ersetzen...\tStrg+H")
+	AppendMenu(editMenu, MF_STRING, findNextShortcutID, "&Weitersuchen\tF3")
+	AppendMenu(editMenu, MF_STRING, findPreviousShortcutID, "R&ückwärts suchen\tUmschalt+F3")
+	AppendMenu(editMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(editMenu, MF_STRING, goToLineShortcutID, "&Gehe zu Zeile...\tStrg+G")
+	AppendMenu(editMenu, MF_STRING, goToSymbolShortcutID, "Gehe zu S&ymbol...\tStrg+Umschalt+O")
+	AppendMenu(editMenu, MF_STRING, definitionShortcutID, "Gehe zu &Definition\tStrg+B")
+	AppendMenu(editMenu, MF_STRING, bracketShortcutID, "Zur passenden &Klammer\tStrg+]")
+	AppendMenu(editMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(editMenu, MF_STRING, completionShortcutID, "&Vervollständigen\tStrg+Leertaste")
+	AppendMenu(editMenu, MF_STRING, hoverShortcutID, "I&nfo anzeigen\tStrg+I")
+	AppendMenu(mainMenu, MF_POPUP, uintptr(editMenu), "&Bearbeiten")
+
+	projectMenu := CreatePopupMenu()
+	AppendMenu(projectMenu, MF_STRING, newProjectShortcutID, "&Neues Projekt...\tStrg+N")
+	AppendMenu(projectMenu, MF_STRING, templatesMenuID, "&Vorlagen-Ordner öffnen")
+	AppendMenu(projectMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(projectMenu, MF_STRING, startButtonShortcutID, "&Start/Stopp\tF9")
+	AppendMenu(projectMenu, MF_STRING, buildProfileShortcutID, "&Build-Optionen...\tStrg+F9")
+	AppendMenu(projectMenu, MF_STRING, dependenciesShortcutID, "&Abhängigkeiten...\tF6")
+	AppendMenu(projectMenu, MF_STRING, projectSearchShortcutID, "In Projekten &suchen...\tF7")
+	AppendMenu(projectMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(projectMenu, MF_STRING, refreshShortcutID, "Projekte a&ktualisieren\tF5")
+	AppendMenu(projectMenu, MF_STRING, fileExplorerShortcutID, "Im &Explorer öffnen\tF11")
+	AppendMenu(projectMenu, MF_STRING, commandLineShortcutID, "&Kommandozeile öffnen\tF12")
+	AppendMenu(projectMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(projectMenu, MF_STRING, synchCodeWithRepoID, "Code mit &Online-Version synchronisieren\tF2")
+	AppendMenu(projectMenu, MF_STRING, f4KeyID, "F4-Skript ausführen\tF4")
+	AppendMenu(mainMenu, MF_POPUP, uintptr(projectMenu), "&Projekt")
+
+	viewMenu := CreatePopupMenu()
+	AppendMenu(viewMenu, MF_STRING, largerFontShortcutID, "Schrift &größer\tStrg++")
+	AppendMenu(viewMenu, MF_STRING, smallerFontShortcutID, "Schrift &kleiner\tStrg+-")
+	AppendMenu(viewMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(viewMenu, MF_STRING, nextTabShortcutID, "&Nächster Tab\tStrg+Tab")
+	AppendMenu(viewMenu, MF_STRING, previousTabShortcutID, "&Vorheriger Tab\tStrg+Umschalt+Tab")
+	AppendMenu(viewMenu, MF_STRING, moveTabLeftShortcutID, "Tab nach &links\tStrg+Umschalt+Bild auf")
+	AppendMenu(viewMenu, MF_STRING, moveTabRightShortcutID, "Tab nach &rechts\tStrg+Umschalt+Bild ab")
+	AppendMenu(viewMenu, MF_SEPARATOR, 0, "")
+	AppendMenu(viewMenu, checkedIf(showHidden), showHiddenMenuID, "&Ausgeblendete Dateien anzeigen")
+	AppendMenu(viewMenu, MF_STRING, ignorePatternsMenuID, "Ausblend&muster bearbeiten...")
+	AppendMenu(mainMenu, MF_POPUP, uintptr(viewMenu), "&Ansicht")
+
+	SetMenu(window, mainMenu)
+	layoutControls()
+
+	w32.SetTimer(window, autoSaveTimerID, autoSaveInterval, 0)
+
 	handleMessage = func(window w32.HWND, message uint32, w, l uintptr) uintptr {
 		switch message {
 		case w32.WM_TIMER:
 			switch w {
 			case programTimerID:
 				readConsoleOutput()
+			case autoSaveTimerID:
+				if autoSave {
+					// Auto-save is silent, errors are reported on the next
+					// manual save or run. Code that the file's encoding
+					// cannot store stays unsaved until the user is asked
+					// about it then.
+					for i := range tabs {
+						if tabDirty(i) {
+							saveTab(i, false)
+						}
+					}
+				}
+			case outlineTimerID:
+				w32.KillTimer(window, outlineTimerID)
+				updateOutline()
+				syncLanguageServer()
+			case checkTimerID:
+				w32.KillTimer(window, checkTimerID)
+				runCheck()
+			case hoverTimerID:
+				w32.KillTimer(window, hoverTimerID)
+				hoverAtMouse()
 			case scrollCheckTimerID:
 				topCodeLine := w32.Edit_GetFirstVisibleLine(codeEdit)
 				if topCodeLine != lastTopCodeLine {
@@ -847,35 +3663,186 @@ func run() error {
 		case w32.WM_COMMAND:
 			lowW := w & 0xFFFF
 			highW := (w & 0xFFFF0000) >> 16
+			// Shortcuts send a 1 in the high word, menu items send a 0.
+			isCommand := func(id uintptr) bool {
+				return l == 0 && (highW == 0 || highW == 1) && lowW == id
+			}
 			if lowW == startButtonID && l == uintptr(startButton) {
 				onStartButtonClick()
 			}
-			if highW == 1 && l == 0 && lowW == synchCodeWithRepoID {
+			if isCommand(synchCodeWithRepoID) {
 				synchCodeWithRepo()
 			}
-			if highW == 1 && l == 0 && lowW == f4KeyID {
+			if isCommand(f4KeyID) {
 				runF4script()
 			}
-			if highW == 1 && l == 0 && lowW == startButtonShortcutID {
+			if isCommand(startButtonShortcutID) {
 				onStartButtonClick()
 			}
-			if highW == 1 && l == 0 && lowW == refreshShortcutID {
+			if isCommand(refreshShortcutID) {
+				updateProjects()
+			}
+			if isCommand(closeTabShortcutID) {
+				closeTab(activeTab)
+			}
+			if isCommand(nextTabShortcutID) && len(tabs) > 0 {
+				switchTab((activeTab + 1) % len(tabs))
+			}
+			if isCommand(previousTabShortcutID) && len(tabs) > 0 {
+				switchTab((activeTab + len(tabs) - 1) % len(tabs))
+			}
+			if isCommand(moveTabLeftShortcutID) {
+				moveTab(-1)
+			}
+			if isCommand(moveTabRightShortcutID) {
+				moveTab(1)
+			}
+			if isCommand(saveShortcutID) {
+				saveFileOrReport()
+			}
+			if isCommand(undoShortcutID) {
+				if focus := w32.GetFocus(); focus != codeEdit {
+					// The shortcut is meant for another edit control.
+					w32.SendMessage(focus, w32.WM_UNDO, 0, 0)
+				} else {
+					undo()
+				}
+			}
+			if isCommand(redoShortcutID) && w32.GetFocus() == codeEdit {
+				redo()
+			}
+			if isCommand(findShortcutID) {
+				openFindBar(false)
+			}
+			if isCommand(replaceShortcutID) {
+				openFindBar(true)
+			}
+			if isCommand(findNextShortcutID) || lowW == findNextButtonID && l == uintptr(findNextButton) {
+				if findBarVisible {
+					findNext()
+				} else {
+					openFindBar(false)
+				}
+			}
+			if isCommand(findPreviousShortcutID) || lowW == findPreviousButtonID && l == uintptr(findPreviousButton) {
+				if findBarVisible {
+					findPrevious()
+				} else {
+					openFindBar(false)
+				}
+			}
+			if lowW == replaceButtonID && l == uintptr(replaceButton) {
+				replaceMatch()
+			}
+			if lowW == replaceAllButtonID && l == uintptr(replaceAllButton) {
+				replaceAllMatches()
+			}
+			if lowW == closeFindButtonID && l == uintptr(closeFindButton) {
+				closeFindBar()
+			}
+			if highW == w32.EN_CHANGE && l == uintptr(findEdit) ||
+				highW == BN_CLICKED && (l == uintptr(findCaseCheck) ||
+					l == uintptr(findWordCheck) || l == uintptr(findRegexCheck)) {
+				findAgain()
+			}
+			if isCommand(formatShortcutID) {
+				formatCode()
+			}
+			if isCommand(formatBeforeRunMenuID) {
+				formatBeforeRun = !formatBeforeRun
+				CheckMenuItem(mainMenu, formatBeforeRunMenuID, formatBeforeRun)
+			}
+			if isCommand(fixImportsMenuID) {
+				fixImports = !fixImports
+				CheckMenuItem(mainMenu, fixImportsMenuID, fixImports)
+			}
+			if isCommand(autoSaveMenuID) {
+				autoSave = !autoSave
+				CheckMenuItem(mainMenu, autoSaveMenuID, autoSave)
+			}
+			if isCommand(showHiddenMenuID) {
+				showHidden = !showHidden
+				CheckMenuItem(mainMenu, showHiddenMenuID, showHidden)
 				updateProjects()
 			}
-			if highW == 1 && l == 0 && lowW == largerFontShortcutID {
+			if isCommand(ignorePatternsMenuID) {
+				editIgnorePatterns()
+			}
+			if isCommand(quitMenuID) {
+				w32.SendMessage(window, w32.WM_CLOSE, 0, 0)
+			}
+			if isCommand(exportShortcutID) {
+				programMu.Lock()
+				if !programRunning {
+					exportProgram()
+				}
+				programMu.Unlock()
+			}
+			if isCommand(buildProfileShortcutID) {
+				r, _ := w32.GetWindowRect(startButton)
+				showBuildProfileMenu(r.Left, r.Bottom)
+			}
+			if isCommand(dependenciesShortcutID) {
+				if root, err := projectsDir(); err == nil && openFilePath != "" {
+					showProjectDependencies(projectFolder(root, openFilePath))
+				}
+			}
+			if isCommand(goToLineShortcutID) {
+				goToLine()
+			}
+			if isCommand(goToSymbolShortcutID) {
+				goToSymbol()
+			}
+			if isCommand(definitionShortcutID) {
+				goToDefinition(caretOffset())
+			}
+			if isCommand(bracketShortcutID) {
+				jumpToBracket()
+			}
+			if isCommand(completionShortcutID) {
+				showCompletion()
+			}
+			if isCommand(hoverShortcutID) {
+				offset := caretOffset()
+				x, y := belowCode(offset)
+				showHover(offset, x, y)
+			}
+			if l == uintptr(completionList) && highW == LBN_DBLCLK {
+				acceptCompletion()
+			}
+			if lowW == problemsID && l == uintptr(problemsList) && highW == LBN_DBLCLK {
+				if i := ListBox_GetCurSel(problemsList); 0 <= i && i < len(problems) {
+					showProblem(problems[i])
+				}
+			}
+			if isCommand(projectSearchShortcutID) {
+				showSearch()
+			}
+			if isCommand(largerFontShortcutID) {
 				incFontSize()
 			}
-			if highW == 1 && l == 0 && lowW == smallerFontShortcutID {
+			if isCommand(smallerFontShortcutID) {
 				decFontSize()
 			}
-			if highW == 1 && l == 0 && lowW == fileExplorerShortcutID {
+			if isCommand(newProjectShortcutID) {
+				if root, err := projectsDir(); err == nil {
+					newProject(root)
+				}
+			}
+			if isCommand(templatesMenuID) {
+				if dir, err := templatesDir(); err == nil {
+					os.MkdirAll(dir, 0666)
+					exec.Command("cmd", "/C", "start", dir).Start()
+				}
+			}
+			if isCommand(fileExplorerShortcutID) {
 				dir := filepath.Dir(openFilePath)
 				if openFilePath == "" {
 					dir, _ = projectsDir()
 				}
 				exec.Command("cmd", "/C", "start", dir).Start()
 			}
-			if highW == 1 && l == 0 && lowW == commandLineShortcutID {
+			if isCommand(commandLineShortcutID) {
 				cmd := exec.Command("cmd", "/C", "start", "/MAX", "cmd")
 				cmd.Dir = filepath.Dir(openFilePath)
 				if openFilePath == "" {
@@ -886,17 +3853,14 @@ func run() error {
 			if highW == w32.EN_VSCROLL && l == uintptr(codeEdit) {
 				updateLineNumbers()
 			}
-			if highW == w32.EN_CHANGE && l == uintptr(codeEdit) {
-				lineCount := int(w32.Edit_GetLineCount(codeEdit))
-				if lineCount != lastLineCount {
-					if len(strconv.Itoa(lineCount)) != len(strconv.Itoa(lastLineCount)) {
-						// The line numbers have grown or shrunk in size, so we
-						// need to adjust the line number column width.
-						layoutControls()
-					} else {
-						updateLineNumbers()
-					}
-					lastLineCount = lineCount
+			if highW == w32.EN_CHANGE && l == uintptr(codeEdit) &&
+				!highlighting && !updatingCode {
+				c := codeText.Update(editorText())
+				codeChanged()
+				if w32.IsWindowVisible(completionList) {
+					filterCompletion()
+				} else if c.Text == "." && c.Start == c.End {
+					showCompletion()
 				}
 			}
 			return 0
@@ -940,23 +3904,75 @@ func run() error {
 				updateFonts()
 			}
 			return 0
+		case w32.WM_CONTEXTMENU:
+			if w == uintptr(startButton) {
+				x, y := int32(int16(l&0xFFFF)), int32(int16((l&0xFFFF0000)>>16))
+				if x == -1 && y == -1 {
+					// The menu was opened with the keyboard.
+					r, _ := w32.GetWindowRect(startButton)
+					x, y = r.Left, r.Bottom
+				}
+				showBuildProfileMenu(x, y)
+				return 0
+			}
+			return w32.DefWindowProc(window, message, w, l)
 		case w32.WM_SIZE:
 			layoutControls()
 			return 0
 		case w32.WM_ACTIVATE:
 			if w&0xFFFF != w32.WA_INACTIVE {
-				// TODO Update the file tree in a diff way, do not delete and
-				// re-create everything, because it will collapse all nodes and
-				// unselect the last selection.
-				// updateProjects()
+				// Files might have changed in other programs.
+				updateProjects()
 			}
 			return 0
 		case w32.WM_NOTIFY:
-			header := *(*w32.NMHDR)(unsafe.Pointer(l))
-			if header.Code == w32.NM_DBLCLK {
+			header := NotifyHeader(l)
+			if header.Code == TCN_SELCHANGE && header.HwndFrom == tabControl {
+				switchTab(TabCtrl_GetCurSel(tabControl))
+				return 0
+			}
+			if header.Code == w32.NM_RCLICK && header.HwndFrom == tabControl {
+				showTabMenu()
+				return 1
+			}
+			if header.Code == w32.NM_RCLICK && header.HwndFrom == projectTree {
+				showProjectMenu()
+				return 1
+			}
+			if header.Code == EN_SELCHANGE && header.HwndFrom == codeEdit &&
+				!highlighting && !updatingCode {
+				// The selection also changes while typing, before codeText
+				// is updated, so the brackets are shown afterwards.
+				PostMessage(window, bracketsMessage, 0, 0)
+				return 0
+			}
+			if header.HwndFrom == outlineTree && !updatingOutline &&
+				(header.Code == w32.NM_CLICK || header.Code == w32.TVN_SELCHANGED) {
+				// Clicks are handled on their own so clicking the selected
+				// item jumps to it again.
+				var target w32.HTREEITEM
+				if header.Code == w32.NM_CLICK {
+					p, _ := w32.GetCursorPos()
+					p, _ = w32.ScreenToClient(outlineTree, p)
+					if item, flags := w32.TreeView_HitTest(outlineTree, p); flags&w32.TVHT_ONITEM != 0 {
+						target = item
+					}
+				} else if change := NotifyTreeView(l); change.Action == w32.TVC_BYKEYBOARD {
+					target = change.ItemNew.Item
+				}
+				for i, item := range outlineItems {
+					if target != 0 && item == target && i < len(outlineSymbols) {
+						selectSymbol(outlineSymbols[i])
+					}
+				}
+				return 0
+			}
+			if header.Code == w32.NM_DBLCLK && header.HwndFrom == projectTree {
 				item := w32.TreeView_GetSelection(projectTree)
 				path := fileTreeItemToPath[item]
-				if strings.HasSuffix(strings.ToLower(path), ".go") {
+				// Text files open in gool, everything else, like programs
+				// and images, in the program that Windows associates with it.
+				if isGoFile(path) || isTextFile(path) {
 					if err := openFile(path); err != nil {
 						w32.MessageBox(
 							0,
@@ -971,9 +3987,61 @@ func run() error {
 			}
 			return 0
 		case w32.WM_CLOSE:
+			for i := range tabs {
+				if !confirmCloseTab(i) {
+					return 0
+				}
+			}
 			onClose()
 			return w32.DefWindowProc(window, message, w, l)
+		case languageServerMessage:
+			languageServerMu.Lock()
+			started := startedLanguageServers
+			startedLanguageServers = map[string]*lsp.Client{}
+			languageServerMu.Unlock()
+			for dir, c := range started {
+				if dir == languageServerRoot && languageServer == nil {
+					languageServer = c
+					syncLanguageServer()
+				} else {
+					// The user has opened another project in the meantime.
+					go shutdownLanguageServer(c)
+				}
+			}
+			return 0
+		case diagnosticsMessage:
+			showDiagnostics()
+			return 0
+		case languageReplyMessage:
+			languageServerMu.Lock()
+			replies := languageReplies
+			languageReplies = nil
+			languageServerMu.Unlock()
+			for _, handle := range replies {
+				handle()
+			}
+			return 0
+		case bracketsMessage:
+			showBrackets()
+			return 0
+		case filesChangedMessage:
+			handleChangedFiles()
+			return 0
+		case checkMessage:
+			checkResultMu.Lock()
+			problems = checkResult
+			checkResultMu.Unlock()
+			checkRunning = false
+			showProblems()
+			if checkPending {
+				checkPending = false
+				runCheck()
+			}
+			return 0
 		case w32.WM_DESTROY:
+			if languageServer != nil {
+				shutdownLanguageServer(languageServer)
+			}
 			w32.PostQuitMessage(0)
 			return 0
 		default:
@@ -997,11 +4065,136 @@ func run() error {
 			Key:  w32.VK_F4,
 			Cmd:  f4KeyID,
 		},
+		{
+			Virt: w32.FVIRTKEY,
+			Key:  w32.VK_F6,
+			Cmd:  dependenciesShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY,
+			Key:  w32.VK_F7,
+			Cmd:  projectSearchShortcutID,
+		},
 		{
 			Virt: w32.FVIRTKEY,
 			Key:  w32.VK_F9,
 			Cmd:  startButtonShortcutID,
 		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  w32.VK_F9,
+			Cmd:  buildProfileShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'S',
+			Cmd:  saveShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'W',
+			Cmd:  closeTabShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'Z',
+			Cmd:  undoShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'Y',
+			Cmd:  redoShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  'Z',
+			Cmd:  redoShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'F',
+			Cmd:  findShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  'F',
+			Cmd:  formatShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'H',
+			Cmd:  replaceShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'G',
+			Cmd:  goToLineShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'B',
+			Cmd:  definitionShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'N',
+			Cmd:  newProjectShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  w32.VK_OEM_6,
+			Cmd:  bracketShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  w32.VK_SPACE,
+			Cmd:  completionShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'I',
+			Cmd:  hoverShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  'O',
+			Cmd:  goToSymbolShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY,
+			Key:  w32.VK_F3,
+			Cmd:  findNextShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FSHIFT,
+			Key:  w32.VK_F3,
+			Cmd:  findPreviousShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  w32.VK_TAB,
+			Cmd:  nextTabShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  w32.VK_TAB,
+			Cmd:  previousTabShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  w32.VK_PRIOR,
+			Cmd:  moveTabLeftShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL | w32.FSHIFT,
+			Key:  w32.VK_NEXT,
+			Cmd:  moveTabRightShortcutID,
+		},
+		{
+			Virt: w32.FVIRTKEY | w32.FCONTROL,
+			Key:  'E',
+			Cmd:  exportShortcutID,
+		},
 		{
 			Virt: w32.FVIRTKEY,
 			Key:  w32.VK_F11,
@@ -1047,6 +4240,44 @@ func run() error {
 		if !ok {
 			break
 		}
+		switch msg.Message {
+		case w32.WM_KEYDOWN, w32.WM_MOUSEWHEEL:
+			hideHover()
+		case w32.WM_LBUTTONDOWN, w32.WM_RBUTTONDOWN, w32.WM_NCLBUTTONDOWN:
+			hideHover()
+			if msg.Hwnd != completionList {
+				hideCompletion()
+			}
+		case w32.WM_MOUSEMOVE:
+			if msg.Hwnd == codeEdit {
+				// Hover information appears when the mouse rests.
+				p, _ := w32.GetCursorPos()
+				if abs(int(p.X-hoverMouse.X))+abs(int(p.Y-hoverMouse.Y)) > 4 {
+					hoverMouse = p
+					hideHover()
+					w32.SetTimer(window, hoverTimerID, 700, 0)
+				}
+			}
+		}
+		if msg.Message == w32.WM_LBUTTONDOWN && msg.Hwnd == codeEdit &&
+			msg.WParam&w32.MK_CONTROL != 0 {
+			// Ctrl+Click goes to the definition of the clicked identifier.
+			p := w32.POINT{X: int32(int16(msg.LParam)), Y: int32(int16(msg.LParam >> 16))}
+			goToDefinition(byteOffset(codeText.String(), RichEdit_CharFromPos(codeEdit, p)))
+			continue
+		}
+		if msg.Message == w32.WM_KEYDOWN && handleCompletionKey(msg.Hwnd, msg.WParam) {
+			continue
+		}
+		if handleCodeTyping(&msg) {
+			continue
+		}
+		if msg.Message == w32.WM_KEYDOWN && handleFindBarKey(msg.Hwnd, msg.WParam) {
+			continue
+		}
+		if msg.Message == w32.WM_KEYDOWN && handleProjectSearchKey(msg.Hwnd, msg.WParam) {
+			continue
+		}
 		if w32.TranslateAccelerator(window, shortcuts, &msg) != nil {
 			w32.TranslateMessage(&msg)
 			w32.DispatchMessage(&msg)
@@ -1155,6 +4386,90 @@ func hideConsoleWindow() {
 	}
 }
 
+// checkedIf returns the flags for a menu item that has a check mark if checked
+// is true.
+func checkedIf(checked bool) uint32 {
+	if checked {
+		return MF_STRING | MF_CHECKED
+	}
+	return MF_STRING
+}
+
+func isGoFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".go")
+}
+
+// isTextFile reports whether the file at path is a text file that gool can
+// edit, judging by its first bytes.
+func isTextFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	start := make([]byte, 8192)
+	n, err := io.ReadFull(f, start)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	return textfile.IsText(start[:n])
+}
+
+// isIdentifierByte reports whether b can be part of a Go identifier. All
+// bytes of non-ASCII characters count as letters.
+func isIdentifierByte(b byte) bool {
+	return b == '_' || b >= 0x80 ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func shutdownLanguageServer(c *lsp.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	c.Shutdown(ctx)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// utf16Len returns the number of UTF-16 code units needed for s.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++ // Surrogate pair.
+		}
+	}
+	return n
+}
+
+// byteOffset converts a position in UTF-16 code units, as edit controls use
+// them, to a byte offset into s.
+func byteOffset(s string, utf16Pos int) int {
+	n := 0
+	for i, r := range s {
+		if n >= utf16Pos {
+			return i
+		}
+		n++
+		if r >= 0x10000 {
+			n++ // Surrogate pair.
+		}
+	}
+	return len(s)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -1162,6 +4477,18 @@ func max(a, b int) int {
 	return b
 }
 
+// formatSize formats a file size in bytes for humans.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
 func round(x float64) int {
 	if x < 0 {
 		return int(x - 0.5)
@@ -1169,16 +4496,65 @@ func round(x float64) int {
 	return int(x + 0.5)
 }
 
-func numberRange(from, to int) string {
+// errorMarker and warningMarker are shown in front of the numbers of lines
+// with problems.
+const (
+	errorMarker   = "● "
+	warningMarker = "▲ "
+)
+
+// numberRange returns the line numbers from and to, one per line. markers
+// holds the marker to show in front of a line number.
+func numberRange(from, to int, markers map[int]string) string {
 	var s string
 	for i := from; i <= to; i++ {
-		s += strconv.Itoa(i) + "\r\n"
+		s += markers[i] + strconv.Itoa(i) + "\r\n"
 	}
 	return s
 }
 
-func readProjectTree(root string) (*folder, error) {
-	folder, err := readFolder(root)
+// projectFolder returns the folder of the project that file belongs to. This is
+// the closest folder with a go.mod file or, if there is none, the file's own
+// folder. Projects can be grouped in sub folders of root.
+func projectFolder(root, file string) string {
+	dir := filepath.Dir(file)
+	for d := dir; paths.IsInside(d, root); d = filepath.Dir(d) {
+		if fileExists(filepath.Join(d, "go.mod")) {
+			return d
+		}
+	}
+	return dir
+}
+
+// goEnvironment returns the environment for go commands in the project. A
+// go.work file in the projects folder or in a group folder applies to all
+// projects below it. Projects that are not part of the workspace must not use
+// it, otherwise the go command fails.
+func goEnvironment(projectPath, projectsPath string) (env []string, inWorkspace bool) {
+	env = os.Environ()
+	if work := workspace.Find(projectPath, projectsPath); work != "" {
+		if workspace.Contains(work, projectPath) {
+			return append(env, "GOWORK="+work), true
+		}
+		return append(env, "GOWORK=off"), false
+	}
+	return env, false
+}
+
+func goModInit(ctx context.Context, projectPath string) error {
+	init := exec.CommandContext(
+		ctx, "go", "mod", "init", modpath.FromName(filepath.Base(projectPath)),
+	)
+	init.Dir = projectPath
+	output, err := init.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go mod init failed: %s\r\n%s", err, output)
+	}
+	return nil
+}
+
+func readProjectTree(root string, showHidden bool) (*folder, error) {
+	folder, err := readProjectFolder(root, root, showHidden)
 	if err != nil {
 		return nil, err
 	}
@@ -1193,22 +4569,63 @@ type folder struct {
 	files   []string
 }
 
-func readFolder(path string) (*folder, error) {
+// readProjectFolder reads folder dir in the projects folder root. Unless
+// showHidden is set, it skips files starting with a dot and those that the
+// .goolignore files in root, dir and the folders between them hide.
+func readProjectFolder(root, dir string, showHidden bool) (*folder, error) {
+	if showHidden {
+		return readFolder(dir, "", nil)
+	}
+	ignored := ignore.New()
+	ignored.Add("", ignore.Defaults)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return readFolder(dir, "", ignored)
+	}
+	rel = filepath.ToSlash(rel)
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		parent := strings.Join(parts[:i], "/")
+		addIgnoreFile(ignored, filepath.Join(root, filepath.FromSlash(parent)), parent)
+	}
+	return readFolder(dir, rel, ignored)
+}
+
+// addIgnoreFile adds the patterns of the .goolignore file in dir, which is rel
+// relative to the projects folder, if there is one.
+func addIgnoreFile(ignored *ignore.Matcher, dir, rel string) {
+	if data, err := os.ReadFile(filepath.Join(dir, ignore.FileName)); err == nil {
+		ignored.Add(rel, string(data))
+	}
+}
+
+// readFolder reads the files and folders in path, which is rel relative to the
+// projects folder. Files that ignored matches are skipped, as well as all
+// names starting with a dot. If ignored is nil, nothing is skipped.
+func readFolder(path, rel string, ignored *ignore.Matcher) (*folder, error) {
 	files, err := os.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
+	if ignored != nil {
+		addIgnoreFile(ignored, path, rel)
+	}
 
 	folder := &folder{path: path}
 
 	for _, file := range files {
-		if strings.HasPrefix(file.Name(), ".") {
+		subRel := file.Name()
+		if rel != "" {
+			subRel = rel + "/" + subRel
+		}
+		if ignored != nil &&
+			(strings.HasPrefix(file.Name(), ".") || ignored.Match(subRel, file.IsDir())) {
 			continue
 		}
 
 		subPath := filepath.Join(path, file.Name())
 		if file.IsDir() {
-			sub, err := readFolder(subPath)
+			sub, err := readFolder(subPath, subRel, ignored)
 			if err != nil {
 				return nil, err
 			}
@@ -1221,14 +4638,30 @@ func readFolder(path string) (*folder, error) {
 	return folder, nil
 }
 
-const helloWorldCode = `package main
-
-import "fmt"
+// nodes returns the contents of f for the project tree, folders first.
+func (f *folder) nodes() []treediff.Node {
+	var nodes []treediff.Node
+	for _, sub := range f.folders {
+		nodes = append(nodes, treediff.Node{
+			Key:      sub.path,
+			Folder:   true,
+			Children: sub.nodes(),
+		})
+	}
+	for _, file := range f.files {
+		nodes = append(nodes, treediff.Node{Key: file})
+	}
+	return nodes
+}
 
-func main() {
-	fmt.Println("Hello World!")
+// allFiles returns the paths of all files in f and its sub-folders.
+func (f *folder) allFiles() []string {
+	files := append([]string{}, f.files...)
+	for _, sub := range f.folders {
+		files = append(files, sub.allFiles()...)
+	}
+	return files
 }
-`
 
 func fileExists(path string) bool {
 	info, err := os.Stat(path)
@@ -0,0 +1,236 @@
+// Package modpath turns project folder names into valid Go module paths and
+// rewrites a project's module path after it was renamed.
+package modpath
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// Prefix is the first path element of all module paths that gool generates.
+// module.CheckPath requires the first element to contain a dot, so a plain
+// folder name is not a valid module path on its own.
+const Prefix = "gool.local"
+
+// fallbackName is used for folder names that contain no usable characters.
+const fallbackName = "projekt"
+
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'Ä': "ae", 'Ö': "oe", 'Ü': "ue", 'ẞ': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ý': "y", 'ÿ': "y",
+	'À': "a", 'Á': "a", 'Â': "a", 'Ã': "a", 'Å': "a", 'Æ': "ae",
+	'Ç': "c", 'È': "e", 'É': "e", 'Ê': "e", 'Ë': "e",
+	'Ì': "i", 'Í': "i", 'Î': "i", 'Ï': "i", 'Ñ': "n",
+	'Ò': "o", 'Ó': "o", 'Ô': "o", 'Õ': "o", 'Ø': "o", 'Œ': "oe",
+	'Ù': "u", 'Ú': "u", 'Û': "u", 'Ý': "y",
+}
+
+// Element converts a folder name into a single valid module path element.
+// Umlauts and accented letters are transliterated, everything else that is not
+// a lower case ASCII letter, digit, '-' or '.' becomes an underscore.
+func Element(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		case 'A' <= r && r <= 'Z':
+			b.WriteRune(r - 'A' + 'a')
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	elem := b.String()
+	for strings.Contains(elem, "__") {
+		elem = strings.ReplaceAll(elem, "__", "_")
+	}
+	// Path elements must not begin or end with a dot.
+	elem = strings.Trim(elem, "_.")
+	if elem == "" {
+		return fallbackName
+	}
+	if module.CheckPath(Prefix+"/"+elem) != nil {
+		// This happens for reserved Windows file names like "con" or "aux".
+		elem += "_"
+	}
+	return elem
+}
+
+// FromName returns the module path that gool uses for a project in a folder of
+// the given name. The result always passes module.CheckPath.
+func FromName(name string) string {
+	return Prefix + "/" + Element(name)
+}
+
+// Read returns the module path declared in the go.mod file in dir.
+func Read(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	path := modfile.ModulePath(data)
+	if path == "" {
+		return "", errors.New("go.mod has no module line")
+	}
+	return path, nil
+}
+
+// Outdated reports whether the project in dir, whose folder is called name,
+// has a module path that gool should offer to rewrite. This is the case if the
+// go command does not accept the path, e.g. because it was created from a
+// folder name with spaces. If the folder was renamed from oldName, the path
+// is also outdated if gool generated it for the old name. Module paths that
+// the user chose, like "game" or "github.com/user/repo", are left alone. The
+// current and wanted paths are returned as well.
+func Outdated(dir, oldName, name string) (current, wanted string, outdated bool) {
+	current, err := Read(dir)
+	if err != nil {
+		return "", "", false
+	}
+	wanted = FromName(name)
+	if current == wanted {
+		return current, wanted, false
+	}
+	if module.CheckImportPath(current) != nil {
+		return current, wanted, true
+	}
+	// Older versions of gool created modules named like the folder.
+	renamed := oldName != "" && (current == FromName(oldName) || current == oldName)
+	return current, wanted, renamed
+}
+
+// Rewrite replaces the module line of the go.mod file in dir with newPath and
+// updates all imports of packages inside the module in the .go files of the
+// project. Files are only written if they change.
+func Rewrite(dir, newPath string) error {
+	if err := module.CheckPath(newPath); err != nil {
+		return err
+	}
+
+	modPath := filepath.Join(dir, "go.mod")
+	mod, err := os.ReadFile(modPath)
+	if err != nil {
+		return err
+	}
+	oldPath := modfile.ModulePath(mod)
+	if oldPath == "" {
+		return errors.New("go.mod has no module line")
+	}
+
+	// We replace the module line textually instead of going through
+	// modfile.Parse because broken module paths like "Mein Spiel" do not
+	// parse.
+	lines := strings.SplitAfter(string(mod), "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "module" {
+			ending := line[len(strings.TrimRight(line, "\r\n")):]
+			lines[i] = "module " + modfile.AutoQuote(newPath) + ending
+			break
+		}
+	}
+	newMod := strings.Join(lines, "")
+	if newMod != string(mod) {
+		if err := os.WriteFile(modPath, []byte(newMod), 0666); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && fileExists(filepath.Join(path, "go.mod")) {
+				return filepath.SkipDir // Nested modules have their own paths.
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".go") {
+			return nil
+		}
+		return rewriteImports(path, oldPath, newPath)
+	})
+}
+
+func rewriteImports(path, oldPath, newPath string) error {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, code, parser.ImportsOnly)
+	if err != nil {
+		// We do not touch files that do not parse, the build will report them
+		// to the user anyway.
+		return nil
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	for _, imp := range f.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		if importPath == oldPath || strings.HasPrefix(importPath, oldPath+"/") {
+			replacements = append(replacements, replacement{
+				start: fset.Position(imp.Path.Pos()).Offset,
+				end:   fset.Position(imp.Path.End()).Offset,
+				text:  strconv.Quote(newPath + strings.TrimPrefix(importPath, oldPath)),
+			})
+		}
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	// Replace from back to front so the offsets stay valid.
+	sort.Slice(replacements, func(i, j int) bool {
+		return replacements[i].start > replacements[j].start
+	})
+	for _, r := range replacements {
+		var buf bytes.Buffer
+		buf.Write(code[:r.start])
+		buf.WriteString(r.text)
+		buf.Write(code[r.end:])
+		code = buf.Bytes()
+	}
+	return os.WriteFile(path, code, 0666)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
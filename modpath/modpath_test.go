@@ -0,0 +1,151 @@
+package modpath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestElement(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"game", "game"},
+		{"Mein Spiel", "mein_spiel"},
+		{"Übung 1", "uebung_1"},
+		{"Straße", "strasse"},
+		{"Café-Bar", "cafe-bar"},
+		{"  viele   Leerzeichen  ", "viele_leerzeichen"},
+		{".versteckt.", "versteckt"},
+		{"a/b\\c", "a_b_c"},
+		{"", "projekt"},
+		{"   ", "projekt"},
+		{"!?#", "projekt"},
+		{"日本", "projekt"},
+		{"con", "con_"},
+	}
+	for _, test := range tests {
+		got := Element(test.name)
+		if got != test.want {
+			t.Errorf("%q: want %q but have %q", test.name, test.want, got)
+		}
+		path := FromName(test.name)
+		if path != Prefix+"/"+test.want {
+			t.Errorf("%q: unexpected module path %q", test.name, path)
+		}
+		if err := module.CheckPath(path); err != nil {
+			t.Errorf("%q: invalid module path: %v", test.name, err)
+		}
+	}
+}
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRewrite(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "go.mod"), "module \"Mein Spiel\"\r\n\r\ngo 1.19\r\n")
+	write(t, filepath.Join(dir, "main.go"), `package main
+
+import (
+	"fmt"
+
+	"Mein Spiel/grafik"
+	other "Mein Spiel/grafik/farben"
+	"Mein Spielplatz/x"
+)
+
+func main() { fmt.Println(grafik.X, other.Y, x.Z) }
+`)
+	write(t, filepath.Join(dir, "grafik", "grafik.go"), "package grafik\n\nconst X = 1\n")
+	write(t, filepath.Join(dir, "broken.go"), "package main\n\nimport \"Mein Spiel/grafik\"\n\nfunc {\n")
+	write(t, filepath.Join(dir, "nested", "go.mod"), "module \"Mein Spiel\"\n")
+	write(t, filepath.Join(dir, "nested", "n.go"), "package n\n\nimport _ \"Mein Spiel/grafik\"\n")
+
+	if err := Rewrite(dir, "gool.local/mein_spiel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := read(t, filepath.Join(dir, "go.mod")),
+		"module gool.local/mein_spiel\r\n\r\ngo 1.19\r\n"; got != want {
+		t.Errorf("want go.mod\n%q\nbut have\n%q", want, got)
+	}
+	want := `package main
+
+import (
+	"fmt"
+
+	"gool.local/mein_spiel/grafik"
+	other "gool.local/mein_spiel/grafik/farben"
+	"Mein Spielplatz/x"
+)
+
+func main() { fmt.Println(grafik.X, other.Y, x.Z) }
+`
+	if got := read(t, filepath.Join(dir, "main.go")); got != want {
+		t.Errorf("want main.go\n%s\nbut have\n%s", want, got)
+	}
+	if got := read(t, filepath.Join(dir, "nested", "n.go")); got != "package n\n\nimport _ \"Mein Spiel/grafik\"\n" {
+		t.Errorf("nested modules must not change, have\n%s", got)
+	}
+	if path, err := Read(dir); err != nil || path != "gool.local/mein_spiel" {
+		t.Errorf("want the new module path but have %q, %v", path, err)
+	}
+
+	if err := Rewrite(dir, "kein gültiger pfad"); err == nil {
+		t.Error("error expected for an invalid module path")
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	tests := []struct {
+		module   string
+		oldName  string
+		name     string
+		outdated bool
+	}{
+		{"gool.local/spiel", "", "Spiel", false},
+		{"game", "", "game", false},
+		{"game", "", "Mein Spiel", false},
+		{"github.com/user/repo", "", "repo", false},
+		{"\"Mein Spiel\"", "", "Mein Spiel", true},
+		{"gool.local/alt", "alt", "neu", true},
+		{"alt", "alt", "neu", true},
+		{"game", "alt", "neu", false},
+		{"gool.local/alt", "", "neu", false},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		write(t, filepath.Join(dir, "go.mod"), "module "+test.module+"\n")
+		_, wanted, outdated := Outdated(dir, test.oldName, test.name)
+		if outdated != test.outdated {
+			t.Errorf("%s renamed from %q to %q: want outdated %v",
+				test.module, test.oldName, test.name, test.outdated)
+		}
+		if wanted != FromName(test.name) {
+			t.Errorf("%s: unexpected wanted path %s", test.module, wanted)
+		}
+	}
+	if _, _, outdated := Outdated(t.TempDir(), "a", "b"); outdated {
+		t.Error("projects without go.mod are never outdated")
+	}
+}
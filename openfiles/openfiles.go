@@ -0,0 +1,50 @@
+// Package openfiles decides about the files that are open in the editor's
+// tabs, independent of the user interface: which tab shows a file and what to
+// do when a file changes on disk.
+package openfiles
+
+import (
+	"bytes"
+
+	"github.com/gonutz/gool/paths"
+)
+
+// Index returns the index of the tab that shows the file at path or -1 if no
+// tab does. open lists the paths of all tabs. Paths are compared like Windows
+// does, so a file gets only one tab, no matter how its name is spelled.
+func Index(open []string, path string) int {
+	for i, p := range open {
+		if paths.Same(p, path) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Action is what to do with a tab after its file changed on disk.
+type Action int
+
+const (
+	// Keep leaves the tab as it is. The file has the content that gool last
+	// read or wrote, watchers report gool's own saves as well.
+	Keep Action = iota
+	// Reload replaces the tab's code with the file's, the tab has no
+	// unsaved changes.
+	Reload
+	// Ask lets the user decide, both the file and the tab were changed.
+	Ask
+)
+
+// OnChange decides what to do with a tab whose file changed on disk. known is
+// the content that gool last read from or wrote to the file, data is what the
+// file contains now. dirty tells whether the tab has unsaved changes.
+func OnChange(known, data []byte, dirty bool) Action {
+	switch {
+	case bytes.Equal(known, data):
+		return Keep
+	case dirty:
+		return Ask
+	default:
+		return Reload
+	}
+}
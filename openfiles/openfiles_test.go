@@ -0,0 +1,58 @@
+package openfiles
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	dir := filepath.Join("C:", "gool_projects", "game")
+	open := []string{
+		filepath.Join(dir, "main.go"),
+		filepath.Join(dir, "player.go"),
+	}
+	tests := []struct {
+		path  string
+		index int
+	}{
+		{filepath.Join(dir, "main.go"), 0},
+		{filepath.Join(dir, "player.go"), 1},
+		{filepath.Join(dir, "Main.go"), 0},
+		{filepath.Join("c:", "GOOL_PROJECTS", "game", "PLAYER.GO"), 1},
+		{filepath.Join(dir, "sub", "..", "main.go"), 0},
+		{filepath.Join(dir, "enemy.go"), -1},
+		{"", -1},
+	}
+	for _, test := range tests {
+		if got := Index(open, test.path); got != test.index {
+			t.Errorf("%s: want %d but have %d", test.path, test.index, got)
+		}
+	}
+	if got := Index(nil, open[0]); got != -1 {
+		t.Errorf("want -1 without tabs but have %d", got)
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	saved := []byte("package main\n")
+	other := []byte("package main\n\nfunc main() {}\n")
+	tests := []struct {
+		name  string
+		known []byte
+		data  []byte
+		dirty bool
+		want  Action
+	}{
+		{"own save", saved, saved, false, Keep},
+		{"own save while typing", saved, saved, true, Keep},
+		{"empty file", nil, []byte{}, true, Keep},
+		{"changed outside", saved, other, false, Reload},
+		{"changed outside and in the editor", saved, other, true, Ask},
+		{"emptied outside", saved, nil, true, Ask},
+	}
+	for _, test := range tests {
+		if got := OnChange(test.known, test.data, test.dirty); got != test.want {
+			t.Errorf("%s: want %v but have %v", test.name, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package paths compares file paths the way Windows does, ignoring case. All
+// of gool uses it, so a file is the same file everywhere, no matter how its
+// path is spelled.
+package paths
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Key identifies a file, two paths of the same file have the same key.
+func Key(path string) string {
+	return strings.ToLower(filepath.Clean(path))
+}
+
+// Same reports whether a and b are paths of the same file.
+func Same(a, b string) bool {
+	return Key(a) == Key(b)
+}
+
+// IsInside reports whether path is inside folder dir or one of its
+// sub-folders. A folder is not inside itself.
+func IsInside(path, dir string) bool {
+	sep := string(filepath.Separator)
+	return strings.HasPrefix(Key(path), strings.TrimSuffix(Key(dir), sep)+sep)
+}
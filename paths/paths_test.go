@@ -0,0 +1,47 @@
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSame(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"a/b.go", "a/b.go", true},
+		{"A/B.go", "a/b.GO", true},
+		{"a/./b/../b.go", "a/b.go", true},
+		{"a/b/", "a/b", true},
+		{"a/b.go", "a/c.go", false},
+		{"a/b", "a/b/c", false},
+	}
+	for _, test := range tests {
+		a, b := filepath.FromSlash(test.a), filepath.FromSlash(test.b)
+		if got := Same(a, b); got != test.same {
+			t.Errorf("%s, %s: want %v but have %v", a, b, test.same, got)
+		}
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		inside    bool
+	}{
+		{"a/b.go", "a", true},
+		{"A/sub/b.go", "a", true},
+		{"a/sub/b.go", "A/Sub/", true},
+		{"a", "a", false},
+		{"ab/c.go", "a", false},
+		{"a/../b/c.go", "a", false},
+		{"/b.go", "/", true},
+	}
+	for _, test := range tests {
+		path, dir := filepath.FromSlash(test.path), filepath.FromSlash(test.dir)
+		if got := IsInside(path, dir); got != test.inside {
+			t.Errorf("%s in %s: want %v but have %v", path, dir, test.inside, got)
+		}
+	}
+}
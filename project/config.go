@@ -0,0 +1,100 @@
+// Package project contains the per-project settings of gool.
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileName is the name of the settings file in a project folder. It
+// starts with a dot so it does not show up in the project tree.
+const ConfigFileName = ".gool.json"
+
+// Config holds the settings of a single project.
+type Config struct {
+	Build BuildProfile
+}
+
+// BuildProfile selects the flags that are passed to go build.
+type BuildProfile struct {
+	// Race enables the race detector.
+	Race bool
+	// NoOptimizations disables optimizations and inlining for debugging.
+	NoOptimizations bool
+	// GUI builds a Windows GUI program that does not open a console window.
+	GUI bool
+	// Tags are additional build tags.
+	Tags []string
+}
+
+// Flags returns the go build flags for the profile.
+func (p BuildProfile) Flags() []string {
+	var flags []string
+	if p.Race {
+		flags = append(flags, "-race")
+	}
+	if p.NoOptimizations {
+		flags = append(flags, "-gcflags=all=-N -l")
+	}
+	if p.GUI {
+		flags = append(flags, "-ldflags=-H=windowsgui")
+	}
+	if len(p.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(p.Tags, ","))
+	}
+	return flags
+}
+
+// String describes the profile in a few words, it is empty for the default
+// profile.
+func (p BuildProfile) String() string {
+	var parts []string
+	if p.Race {
+		parts = append(parts, "Race")
+	}
+	if p.NoOptimizations {
+		parts = append(parts, "Debug")
+	}
+	if p.GUI {
+		parts = append(parts, "GUI")
+	}
+	if len(p.Tags) > 0 {
+		parts = append(parts, "Tags: "+strings.Join(p.Tags, ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseTags splits a user-entered list of build tags, separated by commas or
+// spaces.
+func ParseTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// LoadConfig reads the settings of the project in dir. A project without
+// settings file has the default settings.
+func LoadConfig(dir string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(filepath.Join(dir, ConfigFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// SaveConfig writes the settings of the project in dir.
+func SaveConfig(dir string, c Config) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ConfigFileName), data, 0666)
+}
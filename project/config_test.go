@@ -0,0 +1,69 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		profile BuildProfile
+		flags   []string
+		text    string
+	}{
+		{BuildProfile{}, nil, ""},
+		{BuildProfile{Race: true}, []string{"-race"}, "Race"},
+		{BuildProfile{NoOptimizations: true}, []string{"-gcflags=all=-N -l"}, "Debug"},
+		{BuildProfile{GUI: true}, []string{"-ldflags=-H=windowsgui"}, "GUI"},
+		{BuildProfile{Tags: []string{"a", "b"}}, []string{"-tags=a,b"}, "Tags: a,b"},
+		{
+			BuildProfile{Race: true, NoOptimizations: true, GUI: true, Tags: []string{"x"}},
+			[]string{"-race", "-gcflags=all=-N -l", "-ldflags=-H=windowsgui", "-tags=x"},
+			"Race, Debug, GUI, Tags: x",
+		},
+	}
+	for _, test := range tests {
+		if got := test.profile.Flags(); !reflect.DeepEqual(got, test.flags) {
+			t.Errorf("%+v: want flags %q but have %q", test.profile, test.flags, got)
+		}
+		if got := test.profile.String(); got != test.text {
+			t.Errorf("%+v: want %q but have %q", test.profile, test.text, got)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		tags  []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"debug", []string{"debug"}},
+		{"a,b", []string{"a", "b"}},
+		{"a, b\tc  d", []string{"a", "b", "c", "d"}},
+		{",a,,b,", []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		got := ParseTags(test.input)
+		if len(got) != len(test.tags) || len(got) > 0 && !reflect.DeepEqual(got, test.tags) {
+			t.Errorf("%q: want %q but have %q", test.input, test.tags, got)
+		}
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	c, err := LoadConfig(dir)
+	if err != nil || !reflect.DeepEqual(c, Config{}) {
+		t.Fatalf("want the default config but have %+v, %v", c, err)
+	}
+	want := Config{Build: BuildProfile{GUI: true, Tags: []string{"x"}}}
+	if err := SaveConfig(dir, want); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadConfig(dir)
+	if err != nil || !reflect.DeepEqual(c, want) {
+		t.Errorf("want %+v but have %+v, %v", want, c, err)
+	}
+}
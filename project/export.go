@@ -0,0 +1,56 @@
+package project
+
+// ExportFolder is the folder in a project that exported programs are written
+// to.
+const ExportFolder = "export"
+
+// Target is a platform that programs can be exported for.
+type Target struct {
+	OS   string
+	Arch string
+}
+
+// ExportTargets are the platforms that programs are exported for.
+var ExportTargets = []Target{
+	{OS: "windows", Arch: "amd64"},
+	{OS: "linux", Arch: "amd64"},
+	{OS: "darwin", Arch: "arm64"},
+	{OS: "js", Arch: "wasm"},
+}
+
+func (t Target) String() string {
+	return t.OS + "/" + t.Arch
+}
+
+// FileName returns the name of the exported program for the target.
+func (t Target) FileName(projectName string) string {
+	name := projectName + "_" + t.OS + "_" + t.Arch
+	switch t.OS {
+	case "windows":
+		name += ".exe"
+	case "js":
+		name += ".wasm"
+	}
+	return name
+}
+
+// Env returns the environment variables that make the go command build for
+// the target. Cgo is disabled because it needs a C cross compiler.
+func (t Target) Env() []string {
+	return []string{
+		"GOOS=" + t.OS,
+		"GOARCH=" + t.Arch,
+		"CGO_ENABLED=0",
+	}
+}
+
+// ExportFlags returns the go build flags for exporting to the target. Exports
+// are release builds, so the race detector and the debug flags are left out.
+// The GUI flag only exists on Windows.
+func (p BuildProfile) ExportFlags(t Target) []string {
+	export := BuildProfile{
+		GUI:  p.GUI && t.OS == "windows",
+		Tags: p.Tags,
+	}
+	return export.Flags()
+}
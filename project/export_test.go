@@ -0,0 +1,49 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargetFileName(t *testing.T) {
+	want := []string{
+		"game_windows_amd64.exe",
+		"game_linux_amd64",
+		"game_darwin_arm64",
+		"game_js_wasm.wasm",
+	}
+	var got []string
+	for _, target := range ExportTargets {
+		got = append(got, target.FileName("game"))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q but have %q", want, got)
+	}
+}
+
+func TestExportFlags(t *testing.T) {
+	windows := Target{OS: "windows", Arch: "amd64"}
+	linux := Target{OS: "linux", Arch: "amd64"}
+	all := BuildProfile{Race: true, NoOptimizations: true, GUI: true, Tags: []string{"x"}}
+
+	tests := []struct {
+		profile BuildProfile
+		target  Target
+		flags   []string
+	}{
+		{BuildProfile{}, windows, nil},
+		{all, windows, []string{"-ldflags=-H=windowsgui", "-tags=x"}},
+		{all, linux, []string{"-tags=x"}},
+		{BuildProfile{Race: true}, linux, nil},
+	}
+	for _, test := range tests {
+		if got := test.profile.ExportFlags(test.target); !reflect.DeepEqual(got, test.flags) {
+			t.Errorf("%+v for %s: want %q but have %q", test.profile, test.target, test.flags, got)
+		}
+	}
+
+	env := linux.Env()
+	if want := []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("want environment %q but have %q", want, env)
+	}
+}
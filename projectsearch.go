@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gonutz/gool/search"
+	"github.com/gonutz/w32/v3"
+)
+
+const (
+	projectSearchButtonID = 300 + iota
+	projectSearchResultsID
+)
+
+// projectSearchDoneMessage is posted to the search window when a search is
+// done.
+const projectSearchDoneMessage = w32.WM_USER
+
+// treeViewClass is the window class of tree views.
+const treeViewClass = "SysTreeView32"
+
+// maxSearchResults limits the number of lines that the project search shows.
+const maxSearchResults = 2000
+
+// searchHit is a result line in the project search. Line 0 stands for the
+// whole file.
+type searchHit struct {
+	path string
+	line int
+}
+
+// projectSearchWindow is a tool window that searches all files of the current
+// project or of all projects.
+type projectSearchWindow struct {
+	window       w32.HWND
+	patternEdit  w32.HWND
+	caseCheck    w32.HWND
+	wordCheck    w32.HWND
+	regexCheck   w32.HWND
+	projectRadio w32.HWND
+	allRadio     w32.HWND
+	searchButton w32.HWND
+	status       w32.HWND
+	results      w32.HWND
+
+	projectPath string
+	root        string
+	open        func(path string, line int)
+	hits        map[w32.HTREEITEM]searchHit
+
+	// cancelSearch is non-nil while a search runs in the background. The
+	// search sends its result to searchDone.
+	cancelSearch context.CancelFunc
+	searchDone   chan searchResult
+}
+
+type searchResult struct {
+	dir       string
+	results   []search.FileResult
+	truncated bool
+	err       error
+}
+
+var (
+	projectSearchWindowClass w32.ATOM
+	openProjectSearchWindow  *projectSearchWindow
+)
+
+// showProjectSearch opens the search window or re-uses it if it is already
+// open. projectPath is the current project, it may be empty. root is the
+// folder with all projects. open is called when the user clicks a result.
+func showProjectSearch(owner w32.HWND, font w32.HFONT, projectPath, root string, open func(path string, line int)) error {
+	s := openProjectSearchWindow
+	if s == nil {
+		var err error
+		s, err = newProjectSearchWindow(owner, font)
+		if err != nil {
+			return err
+		}
+		openProjectSearchWindow = s
+	}
+
+	s.projectPath = projectPath
+	s.root = root
+	s.open = open
+	if projectPath == "" {
+		w32.EnableWindow(s.projectRadio, false)
+		Button_SetCheck(s.projectRadio, false)
+		Button_SetCheck(s.allRadio, true)
+	} else {
+		w32.EnableWindow(s.projectRadio, true)
+		w32.SetWindowText(
+			s.projectRadio,
+			w32.String("Projekt "+filepath.Base(projectPath)),
+		)
+	}
+	w32.ShowWindow(s.window, w32.SW_SHOWNORMAL)
+	w32.SetForegroundWindow(s.window)
+	w32.SetFocus(s.patternEdit)
+	w32.SendMessage(s.patternEdit, w32.EM_SETSEL, 0, ^uintptr(0))
+	return nil
+}
+
+func newProjectSearchWindow(owner w32.HWND, font w32.HFONT) (*projectSearchWindow, error) {
+	if projectSearchWindowClass == 0 {
+		cursor, err := w32.LoadCursor(0, w32.MakeIntResource(w32.IDC_ARROW))
+		if err != nil {
+			return nil, err
+		}
+		background, err := w32.GetSysColorBrush(w32.COLOR_BTNFACE)
+		if err != nil {
+			return nil, err
+		}
+		projectSearchWindowClass, err = w32.RegisterClassEx(&w32.WNDCLASSEX{
+			ClassName:  w32.String("gool_project_search_window_class"),
+			Cursor:     cursor,
+			Background: background,
+			WndProc: w32.NewWindowProcedure(
+				func(window w32.HWND, message uint32, w, l uintptr) uintptr {
+					if s := openProjectSearchWindow; s != nil && s.window == window {
+						return s.handleMessage(message, w, l)
+					}
+					return w32.DefWindowProc(window, message, w, l)
+				},
+			),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &projectSearchWindow{}
+
+	var err error
+	s.window, err = w32.CreateWindowEx(
+		0,
+		w32.StringAtom(projectSearchWindowClass),
+		w32.String("In Projekten suchen"),
+		w32.WS_OVERLAPPEDWINDOW,
+		w32.CW_USEDEFAULT, w32.CW_USEDEFAULT, 800, 600,
+		owner, 0, 0, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	create := func(class, text string, style uint32, id uintptr) w32.HWND {
+		var exStyle uint32
+		if class == "EDIT" || class == treeViewClass {
+			exStyle = w32.WS_EX_CLIENTEDGE
+		}
+		child, e := w32.CreateWindowEx(
+			exStyle,
+			w32.String(class),
+			w32.String(text),
+			w32.WS_VISIBLE|w32.WS_CHILD|w32.WS_TABSTOP|style,
+			0, 0, 10, 10,
+			s.window,
+			w32.HMENU(id), 0, nil,
+		)
+		if e != nil && err == nil {
+			err = e
+		}
+		w32.SendMessage(child, w32.WM_SETFONT, uintptr(font), 1)
+		return child
+	}
+
+	s.patternEdit = create("EDIT", "", w32.ES_AUTOHSCROLL, 0)
+	s.searchButton = create("BUTTON", "Suchen", 0, projectSearchButtonID)
+	s.caseCheck = create("BUTTON", "Groß-/Kleinschreibung", w32.BS_AUTOCHECKBOX, 0)
+	s.wordCheck = create("BUTTON", "Ganzes Wort", w32.BS_AUTOCHECKBOX, 0)
+	s.regexCheck = create("BUTTON", "Regulärer Ausdruck", w32.BS_AUTOCHECKBOX, 0)
+	s.projectRadio = create("BUTTON", "Projekt", w32.BS_AUTORADIOBUTTON|w32.WS_GROUP, 0)
+	s.allRadio = create("BUTTON", "Alle Projekte", w32.BS_AUTORADIOBUTTON, 0)
+	s.status = create("STATIC", "", 0, 0)
+	s.results = create(
+		treeViewClass, "",
+		w32.TVS_HASLINES|w32.TVS_HASBUTTONS|w32.TVS_LINESATROOT|w32.TVS_SHOWSELALWAYS,
+		projectSearchResultsID,
+	)
+	if err != nil {
+		w32.DestroyWindow(s.window)
+		return nil, err
+	}
+
+	Edit_SetCueBannerText(s.patternEdit, "Suchen nach, z.B. bufio.Scanner")
+	Button_SetCheck(s.projectRadio, true)
+
+	s.layout()
+	return s, nil
+}
+
+func (s *projectSearchWindow) handleMessage(message uint32, w, l uintptr) uintptr {
+	switch message {
+	case w32.WM_SIZE:
+		s.layout()
+		return 0
+	case w32.WM_COMMAND:
+		if w&0xFFFF == projectSearchButtonID {
+			if s.cancelSearch != nil {
+				s.cancel()
+			} else {
+				s.search()
+			}
+		}
+		return 0
+	case projectSearchDoneMessage:
+		s.showResults()
+		return 0
+	case w32.WM_NOTIFY:
+		// Results open when they are clicked, even if they are selected
+		// already, or with Enter, see handleProjectSearchKey. Moving through
+		// the results with the arrow keys does not open every file.
+		header := NotifyHeader(l)
+		if header.HwndFrom == s.results && header.Code == w32.NM_CLICK {
+			p, _ := w32.GetCursorPos()
+			p, _ = w32.ScreenToClient(s.results, p)
+			if item, flags := w32.TreeView_HitTest(s.results, p); flags&w32.TVHT_ONITEM != 0 {
+				s.openResult(item)
+			}
+		}
+		return 0
+	case w32.WM_DESTROY:
+		s.cancel()
+		openProjectSearchWindow = nil
+		return 0
+	default:
+		return w32.DefWindowProc(s.window, message, w, l)
+	}
+}
+
+func (s *projectSearchWindow) layout() {
+	r, err := w32.GetClientRect(s.window)
+	if err != nil {
+		return
+	}
+	width, height := int(r.Right-r.Left), int(r.Bottom-r.Top)
+
+	setPos := func(window w32.HWND, x, y, width, height int) {
+		w32.SetWindowPos(
+			window, 0,
+			int32(x), int32(y), int32(width), int32(height),
+			w32.SWP_NOOWNERZORDER|w32.SWP_NOZORDER,
+		)
+	}
+
+	const margin, rowH, buttonW, checkW = 10, 28, 120, 180
+	row1y := margin
+	row2y := row1y + rowH + margin/2
+	row3y := row2y + rowH + margin/2
+	resultsY := row3y + rowH + margin/2
+
+	setPos(s.patternEdit, margin, row1y, width-3*margin-buttonW, rowH)
+	setPos(s.searchButton, width-margin-buttonW, row1y, buttonW, rowH)
+	setPos(s.caseCheck, margin, row2y, checkW, rowH)
+	setPos(s.wordCheck, 2*margin+checkW, row2y, checkW, rowH)
+	setPos(s.regexCheck, 3*margin+2*checkW, row2y, checkW, rowH)
+	setPos(s.projectRadio, margin, row3y, 2*checkW, rowH)
+	setPos(s.allRadio, 2*margin+2*checkW, row3y, checkW, rowH)
+	setPos(s.status, 3*margin+3*checkW, row3y+4, width-4*margin-3*checkW, rowH)
+	setPos(s.results, margin, resultsY, width-2*margin, height-margin-resultsY)
+}
+
+// search starts searching the files that the project tree shows in the
+// background. A search that is still running is canceled.
+func (s *projectSearchWindow) search() {
+	pattern, _ := w32.GetWindowText(s.patternEdit)
+	if pattern == "" {
+		return
+	}
+	q := search.Query{
+		Pattern:       pattern,
+		CaseSensitive: Button_IsChecked(s.caseCheck),
+		WholeWord:     Button_IsChecked(s.wordCheck),
+		Regexp:        Button_IsChecked(s.regexCheck),
+	}
+
+	dir := s.root
+	if s.projectPath != "" && Button_IsChecked(s.projectRadio) {
+		dir = s.projectPath
+	}
+
+	s.cancel()
+	w32.TreeView_DeleteAllItems(s.results)
+	s.hits = map[w32.HTREEITEM]searchHit{}
+
+	if _, err := q.FindAll(""); err != nil {
+		w32.SetWindowText(s.status, w32.String("Ungültiger Ausdruck"))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan searchResult, 1)
+	s.cancelSearch = cancel
+	s.searchDone = done
+	w32.SetWindowText(s.searchButton, w32.String("Abbrechen"))
+	w32.SetWindowText(s.status, w32.String("Suche läuft..."))
+
+	window, root := s.window, s.root
+	go func() {
+		result := searchResult{dir: dir}
+		f, err := readProjectFolder(root, dir, false)
+		if err == nil {
+			result.results, result.truncated, err = q.SearchFiles(ctx, f.allFiles(), maxSearchResults)
+		}
+		result.err = err
+		done <- result
+		PostMessage(window, projectSearchDoneMessage, 0, 0)
+	}()
+}
+
+// showResults lists the results of the finished search, grouped by file.
+func (s *projectSearchWindow) showResults() {
+	var result searchResult
+	select {
+	case result = <-s.searchDone:
+	default:
+		// The message came from a canceled search.
+		return
+	}
+	s.cancelSearch()
+	s.cancelSearch = nil
+	s.searchDone = nil
+	w32.SetWindowText(s.searchButton, w32.String("Suchen"))
+
+	if result.err != nil {
+		w32.SetWindowText(s.status, w32.String(result.err.Error()))
+		return
+	}
+
+	lineCount := 0
+	for _, r := range result.results {
+		rel, err := filepath.Rel(result.dir, r.Path)
+		if err != nil {
+			rel = r.Path
+		}
+		fileItem := s.addResult(
+			w32.TVI_ROOT,
+			rel+" ("+strconv.Itoa(len(r.Lines))+")",
+			searchHit{path: r.Path},
+		)
+		for _, line := range r.Lines {
+			s.addResult(
+				fileItem,
+				strconv.Itoa(line.Line)+": "+line.Text,
+				searchHit{path: r.Path, line: line.Line},
+			)
+		}
+		lineCount += len(r.Lines)
+		w32.TreeView_Expand(s.results, fileItem, w32.TVE_EXPAND)
+	}
+
+	status := fmt.Sprintf("%d Treffer in %d Dateien", lineCount, len(result.results))
+	if result.truncated {
+		status += fmt.Sprintf(", nur die ersten %d werden angezeigt", maxSearchResults)
+	}
+	w32.SetWindowText(s.status, w32.String(status))
+}
+
+// cancel stops the running search, if there is one.
+func (s *projectSearchWindow) cancel() {
+	if s.cancelSearch == nil {
+		return
+	}
+	s.cancelSearch()
+	s.cancelSearch = nil
+	s.searchDone = nil
+	w32.SetWindowText(s.searchButton, w32.String("Suchen"))
+	w32.SetWindowText(s.status, w32.String("Abgebrochen"))
+}
+
+// openResult shows the file and line of the result item.
+func (s *projectSearchWindow) openResult(item w32.HTREEITEM) {
+	if hit, ok := s.hits[item]; ok && s.open != nil {
+		s.open(hit.path, hit.line)
+	}
+}
+
+func (s *projectSearchWindow) addResult(parent w32.HTREEITEM, text string, hit searchHit) w32.HTREEITEM {
+	item, err := w32.TreeView_InsertItem(s.results, &w32.TVINSERTSTRUCT{
+		Parent:      parent,
+		InsertAfter: w32.TVI_LAST,
+		ItemEx: w32.TVITEMEX{
+			Mask: w32.TVIF_TEXT,
+			Text: w32.String(text),
+		},
+	})
+	if err == nil {
+		s.hits[item] = hit
+	}
+	return item
+}
+
+// handleProjectSearchKey starts the search when Enter is pressed in the search
+// window's text field and opens the selected result when it is pressed in the
+// result list. It returns true if the key was handled.
+func handleProjectSearchKey(target w32.HWND, key uintptr) bool {
+	s := openProjectSearchWindow
+	if s == nil || key != w32.VK_RETURN {
+		return false
+	}
+	switch target {
+	case s.patternEdit:
+		s.search()
+	case s.results:
+		s.openResult(w32.TreeView_GetSelection(s.results))
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+// Package search finds text in a single document, for the find and replace
+// bar of the editor, and in many files, for the project search.
+package search
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is what the user searches for.
+type Query struct {
+	Pattern       string
+	CaseSensitive bool
+	// WholeWord only finds matches that are not part of a longer word.
+	WholeWord bool
+	// Regexp treats Pattern as a regular expression in Go syntax.
+	Regexp bool
+}
+
+// Match is a found part of the text, Start and End are byte offsets.
+type Match struct {
+	Start int
+	End   int
+	// groups are the submatch indices of a regular expression.
+	groups []int
+}
+
+func (q Query) compile() (*regexp.Regexp, error) {
+	pattern := q.Pattern
+	if !q.Regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !q.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// FindAll returns all non-overlapping, non-empty matches in text, in order. An
+// empty pattern matches nothing. The error is about an invalid regular
+// expression.
+func (q Query) FindAll(text string) ([]Match, error) {
+	if q.Pattern == "" {
+		return nil, nil
+	}
+	re, err := q.compile()
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	for _, groups := range re.FindAllStringSubmatchIndex(text, -1) {
+		m := Match{Start: groups[0], End: groups[1], groups: groups}
+		if m.Start == m.End {
+			continue
+		}
+		if q.WholeWord && !isWholeWord(text, m) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// Expand returns the text that replaces the match m in text. For regular
+// expressions, $1 or ${name} in replacement stand for the submatches.
+func (q Query) Expand(text string, m Match, replacement string) string {
+	if !q.Regexp {
+		return replacement
+	}
+	re, err := q.compile()
+	if err != nil {
+		return replacement
+	}
+	return string(re.ExpandString(nil, replacement, text, m.groups))
+}
+
+func isWholeWord(text string, m Match) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:m.Start])
+	after, _ := utf8.DecodeRuneInString(text[m.End:])
+	first, _ := utf8.DecodeRuneInString(text[m.Start:m.End])
+	last, _ := utf8.DecodeLastRuneInString(text[m.Start:m.End])
+	return !(isWordChar(before) && isWordChar(first)) &&
+		!(isWordChar(last) && isWordChar(after))
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Next returns the index of the first match that starts at or after pos. If
+// there is none, the search wraps around to the first match. matches must not
+// be empty.
+func Next(matches []Match, pos int) int {
+	for i, m := range matches {
+		if m.Start >= pos {
+			return i
+		}
+	}
+	return 0
+}
+
+// Previous returns the index of the last match that starts before pos. If
+// there is none, the search wraps around to the last match. matches must not
+// be empty.
+func Previous(matches []Match, pos int) int {
+	for i := len(matches) - 1; i >= 0; i-- {
+		if matches[i].Start < pos {
+			return i
+		}
+	}
+	return len(matches) - 1
+}
+
+// FileResult lists the lines of a file that contain matches.
+type FileResult struct {
+	Path  string
+	Lines []LineResult
+}
+
+// LineResult is a line that contains at least one match.
+type LineResult struct {
+	// Line is counted from 1.
+	Line int
+	// Text is the line without leading and trailing white space.
+	Text string
+}
+
+// SearchFiles searches the files in the given order and returns those that
+// contain matches. Files that cannot be read and binary files are skipped. The
+// search stops after maxLines matching lines and reports true if it did. The
+// error is about an invalid regular expression or ctx's error if the search
+// was canceled.
+func (q Query) SearchFiles(ctx context.Context, paths []string, maxLines int) ([]FileResult, bool, error) {
+	if _, err := q.FindAll(""); err != nil {
+		return nil, false, err
+	}
+
+	var results []FileResult
+	count := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) != -1 {
+			continue
+		}
+		text := string(data)
+		matches, _ := q.FindAll(text)
+		if len(matches) == 0 {
+			continue
+		}
+
+		result := FileResult{Path: path}
+		line := 1
+		lineStart := 0
+		lastLine := 0
+		for _, m := range matches {
+			line += strings.Count(text[lineStart:m.Start], "\n")
+			if i := strings.LastIndexByte(text[:m.Start], '\n'); i != -1 {
+				lineStart = i + 1
+			}
+			if line == lastLine {
+				continue
+			}
+			lastLine = line
+
+			lineEnd := strings.IndexByte(text[lineStart:], '\n')
+			if lineEnd == -1 {
+				lineEnd = len(text) - lineStart
+			}
+			result.Lines = append(result.Lines, LineResult{
+				Line: line,
+				Text: strings.TrimSpace(text[lineStart : lineStart+lineEnd]),
+			})
+			count++
+			if count >= maxLines {
+				return append(results, result), true, nil
+			}
+		}
+		results = append(results, result)
+	}
+	return results, false, nil
+}
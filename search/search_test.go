@@ -0,0 +1,159 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// found returns the matched texts.
+func found(t *testing.T, q Query, text string) []string {
+	t.Helper()
+	matches, err := q.FindAll(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, m := range matches {
+		list = append(list, text[m.Start:m.End])
+	}
+	return list
+}
+
+func checkFound(t *testing.T, q Query, text string, want ...string) {
+	t.Helper()
+	if got := found(t, q, text); !reflect.DeepEqual(got, want) {
+		t.Errorf("%+v in %q: want %q but have %q", q, text, want, got)
+	}
+}
+
+func TestCaseSensitivity(t *testing.T) {
+	text := "Go go GO gO"
+	checkFound(t, Query{Pattern: "go"}, text, "Go", "go", "GO", "gO")
+	checkFound(t, Query{Pattern: "go", CaseSensitive: true}, text, "go")
+	checkFound(t, Query{Pattern: "Ä"}, "ä Ä", "ä", "Ä")
+}
+
+func TestLiteralPatternIsNotARegexp(t *testing.T) {
+	checkFound(t, Query{Pattern: "a.b"}, "a.b axb", "a.b")
+	checkFound(t, Query{Pattern: "("}, "f(x)", "(")
+}
+
+func TestWholeWord(t *testing.T) {
+	q := Query{Pattern: "x", WholeWord: true}
+	checkFound(t, q, "x xy yx x_ _x x.x (x) äx", "x", "x", "x", "x")
+	checkFound(t, Query{Pattern: ".x", WholeWord: true}, "a.x .xa", ".x")
+}
+
+func TestRegexp(t *testing.T) {
+	q := Query{Pattern: `\d+`, Regexp: true}
+	checkFound(t, q, "a1 b22 c333", "1", "22", "333")
+
+	q = Query{Pattern: `func \w+`, Regexp: true, CaseSensitive: true}
+	checkFound(t, q, "func main\nFunc x\nfunc f", "func main", "func f")
+}
+
+func TestRegexpWholeWord(t *testing.T) {
+	q := Query{Pattern: `i\w*`, Regexp: true, WholeWord: true}
+	checkFound(t, q, "if x, in := bi; i", "if", "in", "i")
+}
+
+func TestEmptyMatchesAreSkipped(t *testing.T) {
+	checkFound(t, Query{Pattern: "a*", Regexp: true}, "baab", "aa")
+}
+
+func TestEmptyPatternFindsNothing(t *testing.T) {
+	checkFound(t, Query{}, "text")
+}
+
+func TestInvalidRegexp(t *testing.T) {
+	_, err := Query{Pattern: "(", Regexp: true}.FindAll("(")
+	if err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	text := "x := 1; y := 2"
+
+	q := Query{Pattern: `(\w) := (\d)`, Regexp: true}
+	matches, _ := q.FindAll(text)
+	var replaced []string
+	for _, m := range matches {
+		replaced = append(replaced, q.Expand(text, m, "var $1 = ${2}0"))
+	}
+	if want := []string{"var x = 10", "var y = 20"}; !reflect.DeepEqual(replaced, want) {
+		t.Errorf("want %q but have %q", want, replaced)
+	}
+
+	q = Query{Pattern: "1"}
+	matches, _ = q.FindAll(text)
+	if r := q.Expand(text, matches[0], "$1"); r != "$1" {
+		t.Errorf("literal replacement must be kept, but have %q", r)
+	}
+}
+
+func TestNextAndPrevious(t *testing.T) {
+	matches := []Match{{Start: 2, End: 3}, {Start: 5, End: 7}, {Start: 9, End: 10}}
+
+	for pos, want := range map[int]int{0: 0, 2: 0, 3: 1, 5: 1, 8: 2, 10: 0} {
+		if got := Next(matches, pos); got != want {
+			t.Errorf("next from %d: want %d but have %d", pos, want, got)
+		}
+	}
+	for pos, want := range map[int]int{0: 2, 2: 2, 3: 0, 5: 0, 6: 1, 10: 2} {
+		if got := Previous(matches, pos); got != want {
+			t.Errorf("previous from %d: want %d but have %d", pos, want, got)
+		}
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.go", "package main\n\nimport \"bufio\"\n\n\tvar s bufio.Scanner; var t bufio.Scanner\r\n")
+	b := write("b.go", "package main\n")
+	c := write("c.bin", "bufio\x00")
+	d := write("d.txt", "bufio")
+
+	q := Query{Pattern: "bufio"}
+	results, truncated, err := q.SearchFiles(
+		context.Background(), []string{a, b, c, filepath.Join(dir, "missing"), d}, 100,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileResult{
+		{Path: a, Lines: []LineResult{
+			{Line: 3, Text: `import "bufio"`},
+			{Line: 5, Text: "var s bufio.Scanner; var t bufio.Scanner"},
+		}},
+		{Path: d, Lines: []LineResult{{Line: 1, Text: "bufio"}}},
+	}
+	if truncated || !reflect.DeepEqual(results, want) {
+		t.Errorf("want %v but have %v (truncated: %v)", want, results, truncated)
+	}
+
+	results, truncated, _ = q.SearchFiles(context.Background(), []string{a, d}, 1)
+	if !truncated || len(results) != 1 || len(results[0].Lines) != 1 {
+		t.Errorf("search must stop after 1 line, have %v", results)
+	}
+
+	if _, _, err := (Query{Pattern: "(", Regexp: true}).SearchFiles(context.Background(), []string{a}, 100); err == nil {
+		t.Error("invalid regexp must be reported")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if results, _, err := q.SearchFiles(ctx, []string{a, d}, 100); err != context.Canceled || results != nil {
+		t.Errorf("canceled search must stop, have %v, %v", results, err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package symbols lists the top-level declarations of a Go file, for the
+// symbol picker of the editor.
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Kind int
+
+const (
+	Func Kind = iota
+	Method
+	Type
+	Const
+	Var
+)
+
+// String returns the keyword that declares the symbol.
+func (k Kind) String() string {
+	switch k {
+	case Type:
+		return "type"
+	case Const:
+		return "const"
+	case Var:
+		return "var"
+	default:
+		return "func"
+	}
+}
+
+// Symbol is a declared name. Methods are named after their receiver type, e.g.
+// "Buffer.Len".
+type Symbol struct {
+	Name string
+	Kind Kind
+	// Receiver is the receiver type name of a method.
+	Receiver string
+	// Offset is the byte offset of the name in the code.
+	Offset int
+	// Line is counted from 1.
+	Line int
+}
+
+// Parse returns the top-level symbols of the Go code, in the order in which
+// they appear. Code with syntax errors yields the symbols that could be
+// parsed.
+func Parse(code string) []Symbol {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", code, parser.SkipObjectResolution|parser.AllErrors)
+	if file == nil {
+		return nil
+	}
+
+	var list []Symbol
+	add := func(name *ast.Ident, kind Kind, receiver string) {
+		if name == nil || name.Name == "_" || !name.Pos().IsValid() {
+			return
+		}
+		pos := fset.Position(name.Pos())
+		s := Symbol{
+			Name:     name.Name,
+			Kind:     kind,
+			Receiver: receiver,
+			Offset:   pos.Offset,
+			Line:     pos.Line,
+		}
+		if receiver != "" {
+			s.Name = receiver + "." + name.Name
+		}
+		list = append(list, s)
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				add(decl.Name, Method, receiverType(decl.Recv.List[0].Type))
+			} else {
+				add(decl.Name, Func, "")
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					add(spec.Name, Type, "")
+				case *ast.ValueSpec:
+					kind := Var
+					if decl.Tok == token.CONST {
+						kind = Const
+					}
+					for _, name := range spec.Names {
+						add(name, kind, "")
+					}
+				}
+			}
+		}
+	}
+	return list
+}
+
+// receiverType returns the type name of a method receiver like *T or T[K].
+func receiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return "?"
+		}
+	}
+}
+
+// Filter returns the symbols whose names contain the characters of query in
+// the same order, ignoring case, e.g. "bl" finds "Buffer.Len". The order of
+// the list is kept. An empty query keeps all symbols.
+func Filter(list []Symbol, query string) []Symbol {
+	var filtered []Symbol
+	for _, s := range list {
+		if matches(s.Name, query) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+func matches(name, query string) bool {
+	query = strings.TrimSpace(query)
+	for _, q := range query {
+		q = unicode.ToLower(q)
+		for {
+			if name == "" {
+				return false
+			}
+			r, size := utf8.DecodeRuneInString(name)
+			name = name[size:]
+			if unicode.ToLower(r) == q {
+				break
+			}
+		}
+	}
+	return true
+}
+
+// Node is an entry in the outline of a file. Types contain their methods.
+type Node struct {
+	Symbol
+	Methods []Symbol
+}
+
+// Outline returns the symbols of the list, which must be in the order of
+// Parse, with the methods nested in their types. Methods of types that are not
+// in the list stay at the top level.
+func Outline(list []Symbol) []Node {
+	types := map[string]int{}
+	for _, s := range list {
+		if s.Kind == Type {
+			if _, ok := types[s.Name]; !ok {
+				types[s.Name] = -1
+			}
+		}
+	}
+
+	var nodes []Node
+	var methods []Symbol
+	for _, s := range list {
+		if s.Kind == Method {
+			if _, ok := types[s.Receiver]; ok {
+				methods = append(methods, s)
+				continue
+			}
+		}
+		if s.Kind == Type && types[s.Name] == -1 {
+			types[s.Name] = len(nodes)
+		}
+		nodes = append(nodes, Node{Symbol: s})
+	}
+	for _, m := range methods {
+		i := types[m.Receiver]
+		nodes[i].Methods = append(nodes[i].Methods, m)
+	}
+	return nodes
+}
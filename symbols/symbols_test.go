@@ -0,0 +1,103 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+)
+
+func names(list []Symbol) []string {
+	var s []string
+	for _, sym := range list {
+		s = append(s, sym.Kind.String()+" "+sym.Name)
+	}
+	return s
+}
+
+const code = `package main
+
+import "fmt"
+
+const (
+	a, b = 1, 2
+	_    = 3
+)
+
+var x int
+
+type Buffer[T any] struct{}
+
+func (b *Buffer[T]) Len() int { return 0 }
+
+func (Point) String() string { return "" }
+
+type Point struct{ X, Y int }
+
+func main() {
+	fmt.Println(x)
+}
+`
+
+func TestParse(t *testing.T) {
+	list := Parse(code)
+	want := []string{
+		"const a", "const b", "var x", "type Buffer", "func Buffer.Len",
+		"func Point.String", "type Point", "func main",
+	}
+	if got := names(list); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q but have %q", want, got)
+	}
+	if list[0].Line != 6 || code[list[0].Offset:list[0].Offset+1] != "a" {
+		t.Errorf("wrong position of a: %+v", list[0])
+	}
+	if list[len(list)-1].Line != 20 {
+		t.Errorf("main must be in line 20 but is in %d", list[len(list)-1].Line)
+	}
+}
+
+func TestParseInvalidCode(t *testing.T) {
+	// The user is typing the body of g.
+	list := Parse("package main\n\nfunc f() {}\n\nfunc g() {\n\tx := ")
+	if got, want := names(list), []string{"func f", "func g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q but have %q", want, got)
+	}
+	if list := Parse("not Go"); len(list) != 0 {
+		t.Errorf("want no symbols but have %v", list)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	list := Parse(code)
+	for query, want := range map[string][]string{
+		"":     names(list),
+		"bl":   {"func Buffer.Len"},
+		"POI":  {"func Point.String", "type Point"},
+		"ma":   {"func main"},
+		"xyz":  nil,
+		" b ":  {"const b", "type Buffer", "func Buffer.Len"},
+		"ptst": {"func Point.String"},
+	} {
+		if got := names(Filter(list, query)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: want %q but have %q", query, want, got)
+		}
+	}
+}
+
+func TestOutline(t *testing.T) {
+	list := Parse(code + "\nfunc (Other) M() {}\n")
+	var got []string
+	for _, n := range Outline(list) {
+		got = append(got, n.Kind.String()+" "+n.Name)
+		for _, m := range n.Methods {
+			got = append(got, "  "+m.Name)
+		}
+	}
+	want := []string{
+		"const a", "const b", "var x",
+		"type Buffer", "  Buffer.Len",
+		"type Point", "  Point.String",
+		"func main", "func Other.M",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q but have %q", want, got)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	input := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Wie heißt du? ")
+	name, _ := input.ReadString('\n')
+	name = strings.TrimSpace(name)
+
+	fmt.Print("Wie alt bist du? ")
+	line, _ := input.ReadString('\n')
+	age, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		fmt.Println("Das ist keine Zahl.")
+		return
+	}
+
+	fmt.Printf("Hallo %s, in 10 Jahren bist du %d.\n", name, age+10)
+}
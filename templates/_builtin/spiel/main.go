@@ -0,0 +1,26 @@
+package main
+
+import "github.com/gonutz/prototype/draw"
+
+func main() {
+	x, y := 300, 220
+	draw.RunWindow("Spiel", 640, 480, func(window draw.Window) {
+		if window.WasKeyPressed(draw.KeyEscape) {
+			window.Close()
+		}
+		if window.IsKeyDown(draw.KeyLeft) {
+			x -= 4
+		}
+		if window.IsKeyDown(draw.KeyRight) {
+			x += 4
+		}
+		if window.IsKeyDown(draw.KeyUp) {
+			y -= 4
+		}
+		if window.IsKeyDown(draw.KeyDown) {
+			y += 4
+		}
+		window.FillRect(x, y, 40, 40, draw.LightBlue)
+		window.DrawText("Pfeiltasten: bewegen, Escape: beenden", 10, 10, draw.White)
+	})
+}
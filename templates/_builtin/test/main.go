@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+// Add returns the sum of a and b.
+func Add(a, b int) int {
+	return a + b
+}
+
+func main() {
+	fmt.Println("2 + 3 =", Add(2, 3))
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// Run the tests in the command line (F12) with: go test
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+	}{
+		{1, 2, 3},
+		{0, 0, 0},
+		{-5, 5, 0},
+	}
+	for _, test := range tests {
+		got := Add(test.a, test.b)
+		if got != test.want {
+			t.Errorf("Add(%d, %d) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/gonutz/gool/indent"
+)
+
+// Snippet is a piece of code that is inserted by typing its keyword and
+// pressing Tab. $0 in the code marks where the caret goes.
+type Snippet struct {
+	Keyword string
+	Code    string
+}
+
+var Snippets = []Snippet{
+	{"for", "for i := 0; i < $0; i++ {\n\t\n}"},
+	{"iferr", "if err != nil {\n\treturn err$0\n}"},
+	{"func", "func $0() {\n\t\n}"},
+	{"main", "func main() {\n\t$0\n}"},
+}
+
+// ExpandSnippet returns the edit that replaces the keyword in front of the
+// caret with its snippet. The keyword must be the only text on its line in
+// front of the caret. The snippet's lines get the indentation of that line.
+func ExpandSnippet(code string, caret int, snippets []Snippet) (indent.Edit, bool) {
+	if caret < len(code) && isWordByte(code[caret]) {
+		return indent.Edit{}, false
+	}
+	lineStart := strings.LastIndexByte(code[:caret], '\n') + 1
+	line := code[lineStart:caret]
+	word := strings.TrimLeft(line, " \t")
+	indentation := line[:len(line)-len(word)]
+
+	for _, s := range snippets {
+		if s.Keyword != word {
+			continue
+		}
+		text := strings.ReplaceAll(s.Code, "\n", "\n"+indentation)
+		at := strings.Index(text, "$0")
+		if at == -1 {
+			at = len(text)
+		} else {
+			text = text[:at] + text[at+2:]
+		}
+		start := caret - len(word)
+		return indent.Edit{Start: start, End: caret, Text: text, Caret: start + at}, true
+	}
+	return indent.Edit{}, false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
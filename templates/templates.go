@@ -0,0 +1,147 @@
+// Package templates creates new projects from templates. Some templates are
+// built into gool, teachers can add their own as folders in a templates
+// folder. All files of a template folder are copied into the new project.
+package templates
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed _builtin
+var builtinFiles embed.FS
+
+// builtins are the folders in _builtin in the order they are listed, with
+// their names for the user.
+var builtins = []struct {
+	folder string
+	name   string
+}{
+	{"konsole", "Konsolenprogramm"},
+	{"eingabe", "Eingabe lesen"},
+	{"spiel", "Spiel (gonutz/prototype)"},
+	{"webserver", "HTTP-Server"},
+	{"test", "Unit-Test-Beispiel"},
+}
+
+// HelloWorld is the name of the template for a simple console program.
+const HelloWorld = "Konsolenprogramm"
+
+// Template is a project template.
+type Template struct {
+	// Name is the name of the template folder or, for built-in templates, a
+	// description.
+	Name    string
+	Builtin bool
+	files   fs.FS
+}
+
+// List returns the built-in templates and those in the folders of dir, which
+// does not have to exist. A folder with the name of a built-in template
+// replaces it.
+func List(dir string) ([]Template, error) {
+	var list []Template
+	for _, b := range builtins {
+		files, err := fs.Sub(builtinFiles, path.Join("_builtin", b.folder))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Template{Name: b.name, Builtin: true, files: files})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var own []Template
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		t := Template{Name: e.Name(), files: os.DirFS(filepath.Join(dir, e.Name()))}
+		replaced := false
+		for i := range list {
+			if strings.EqualFold(list[i].Name, t.Name) {
+				list[i] = t
+				replaced = true
+			}
+		}
+		if !replaced {
+			own = append(own, t)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool {
+		return strings.ToLower(own[i].Name) < strings.ToLower(own[j].Name)
+	})
+	return append(list, own...), nil
+}
+
+// Find returns the template with the given name.
+func Find(list []Template, name string) (Template, bool) {
+	for _, t := range list {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
+
+// Create copies the template's files into the new folder dir. It returns the
+// paths of the created files.
+func (t Template) Create(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.New("the folder " + dir + " already exists")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+
+	var created []string
+	err := fs.WalkDir(t.files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		data, err := fs.ReadFile(t.files, name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0666); err != nil {
+			return err
+		}
+		created = append(created, target)
+		return nil
+	})
+	return created, err
+}
+
+// MainFile returns the file that should be opened after creating a project
+// from the given files: main.go or else the first Go file. It returns "" if
+// there is no Go file.
+func MainFile(files []string) string {
+	first := ""
+	for _, f := range files {
+		if !strings.HasSuffix(strings.ToLower(f), ".go") {
+			continue
+		}
+		if strings.EqualFold(filepath.Base(f), "main.go") {
+			return f
+		}
+		if first == "" {
+			first = f
+		}
+	}
+	return first
+}
@@ -0,0 +1,153 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func names(list []Template) []string {
+	var s []string
+	for _, t := range list {
+		s = append(s, t.Name)
+	}
+	return s
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	list, err := List(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Konsolenprogramm",
+		"Eingabe lesen",
+		"Spiel (gonutz/prototype)",
+		"HTTP-Server",
+		"Unit-Test-Beispiel",
+	}
+	if got := names(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v but have %v", want, got)
+	}
+	if _, ok := Find(list, HelloWorld); !ok {
+		t.Error("the hello world template is missing")
+	}
+}
+
+func TestOwnTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Zeichnen", "anfang", "Konsolenprogramm", ".git"} {
+		os.MkdirAll(filepath.Join(dir, name), 0777)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0666)
+	os.WriteFile(filepath.Join(dir, "Konsolenprogramm", "main.go"), []byte("package main\n"), 0666)
+
+	list, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Konsolenprogramm",
+		"Eingabe lesen",
+		"Spiel (gonutz/prototype)",
+		"HTTP-Server",
+		"Unit-Test-Beispiel",
+		"anfang",
+		"Zeichnen",
+	}
+	if got := names(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v but have %v", want, got)
+	}
+	if list[0].Builtin {
+		t.Error("a folder must replace the built-in template of the same name")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	own := filepath.Join(dir, "templates", "mine")
+	os.MkdirAll(filepath.Join(own, "assets"), 0777)
+	os.WriteFile(filepath.Join(own, "game.go"), []byte("package main\n"), 0666)
+	os.WriteFile(filepath.Join(own, "assets", "hero.png"), []byte("png"), 0666)
+
+	list, err := List(filepath.Join(dir, "templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, _ := Find(list, "mine")
+	project := filepath.Join(dir, "projects", "new")
+	files, err := mine.Create(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(project, "assets", "hero.png"),
+		filepath.Join(project, "game.go"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("want files %v but have %v", want, files)
+	}
+	if data, _ := os.ReadFile(want[0]); string(data) != "png" {
+		t.Errorf("file content was not copied, have %q", data)
+	}
+	if main := MainFile(files); main != want[1] {
+		t.Errorf("want main file %s but have %s", want[1], main)
+	}
+
+	if _, err := mine.Create(project); err == nil {
+		t.Error("existing folders must not be overwritten")
+	}
+}
+
+func TestCreateBuiltin(t *testing.T) {
+	list, _ := List("")
+	test, _ := Find(list, "Unit-Test-Beispiel")
+	project := filepath.Join(t.TempDir(), "p")
+	files, err := test.Create(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || MainFile(files) != filepath.Join(project, "main.go") {
+		t.Errorf("unexpected files %v", files)
+	}
+}
+
+func TestExpandSnippet(t *testing.T) {
+	tests := []struct {
+		before string
+		// after is empty if there is no snippet.
+		after string
+	}{
+		{"for|", "for i := 0; i < |; i++ {\n\t\n}"},
+		{"\tfor|", "\tfor i := 0; i < |; i++ {\n\t\t\n\t}"},
+		{"x\n\t\tiferr|\n", "x\n\t\tif err != nil {\n\t\t\treturn err|\n\t\t}\n"},
+		{"func|", "func |() {\n\t\n}"},
+		{"main| ", "func main() {\n\t|\n} "},
+		{"x := for|", ""},
+		{"fo|", ""},
+		{"for|x", ""},
+		{"|", ""},
+	}
+	for _, test := range tests {
+		caret := 0
+		for i := range test.before {
+			if test.before[i] == '|' {
+				caret = i
+			}
+		}
+		code := test.before[:caret] + test.before[caret+1:]
+		e, ok := ExpandSnippet(code, caret, Snippets)
+		if test.after == "" {
+			if ok {
+				t.Errorf("%q: want no snippet but have %v", test.before, e)
+			}
+			continue
+		}
+		got := e.Apply(code)
+		got = got[:e.Caret] + "|" + got[e.Caret:]
+		if !ok || got != test.after {
+			t.Errorf("%q: want\n%q\nbut have\n%q", test.before, test.after, got)
+		}
+	}
+}
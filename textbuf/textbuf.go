@@ -0,0 +1,424 @@
+// Package textbuf implements the text buffer of the code editor. The text is
+// stored in a piece table, every change is recorded for unlimited undo and
+// redo, and the number of line breaks is kept per piece so lines can be found
+// without scanning the whole text.
+//
+// Positions are byte offsets into the text, lines are separated by "\n".
+package textbuf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Change replaces the text between Start and End with Text.
+type Change struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// piece is a part of the text, either from the original text or from the
+// appended text.
+type piece struct {
+	added    bool
+	start    int
+	length   int
+	newlines int
+}
+
+// edit is a recorded change, it replaced deleted with inserted at start.
+type edit struct {
+	start    int
+	deleted  string
+	inserted string
+}
+
+// Buffer is an editable text. The zero value is an empty buffer.
+type Buffer struct {
+	original string
+	// added is only ever appended to, so the pieces that refer to it stay
+	// valid.
+	added    strings.Builder
+	pieces   []piece
+	length   int
+	newlines int
+
+	undo [][]edit
+	redo [][]edit
+	// coalesce is true if the next typed character may be added to the last
+	// undo group.
+	coalesce bool
+	// groupDepth counts the open BeginGroup calls.
+	groupDepth int
+	groupOpen  bool
+}
+
+// New returns a buffer that contains text and has no undo history.
+func New(text string) *Buffer {
+	b := &Buffer{original: text}
+	if text != "" {
+		n := strings.Count(text, "\n")
+		b.pieces = []piece{{start: 0, length: len(text), newlines: n}}
+		b.length = len(text)
+		b.newlines = n
+	}
+	return b
+}
+
+// Len returns the length of the text in bytes.
+func (b *Buffer) Len() int {
+	return b.length
+}
+
+// String returns the whole text.
+func (b *Buffer) String() string {
+	var s strings.Builder
+	s.Grow(b.length)
+	for _, p := range b.pieces {
+		s.WriteString(b.source(p))
+	}
+	return s.String()
+}
+
+// Slice returns the text between start and end.
+func (b *Buffer) Slice(start, end int) string {
+	var s strings.Builder
+	s.Grow(end - start)
+	pos := 0
+	for _, p := range b.pieces {
+		if pos >= end {
+			break
+		}
+		if pos+p.length > start {
+			from := max(start-pos, 0)
+			to := min(end-pos, p.length)
+			s.WriteString(b.source(p)[from:to])
+		}
+		pos += p.length
+	}
+	return s.String()
+}
+
+func (b *Buffer) source(p piece) string {
+	if p.added {
+		return b.added.String()[p.start : p.start+p.length]
+	}
+	return b.original[p.start : p.start+p.length]
+}
+
+// LineCount returns the number of lines, which is at least 1.
+func (b *Buffer) LineCount() int {
+	return b.newlines + 1
+}
+
+// LineStart returns the position of the first character in the given line.
+// Lines are counted from 0. Lines after the last line start at the end of the
+// text.
+func (b *Buffer) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	if line > b.newlines {
+		return b.length
+	}
+	pos := 0
+	for _, p := range b.pieces {
+		if line > p.newlines {
+			line -= p.newlines
+			pos += p.length
+			continue
+		}
+		text := b.source(p)
+		for i := 0; i < len(text); i++ {
+			if text[i] == '\n' {
+				line--
+				if line == 0 {
+					return pos + i + 1
+				}
+			}
+		}
+	}
+	return b.length
+}
+
+// LineOf returns the line that contains the given position.
+func (b *Buffer) LineOf(pos int) int {
+	line := 0
+	start := 0
+	for _, p := range b.pieces {
+		if start+p.length <= pos {
+			line += p.newlines
+			start += p.length
+			continue
+		}
+		return line + strings.Count(b.source(p)[:pos-start], "\n")
+	}
+	return line
+}
+
+// Replace replaces the text between start and end with text. Consecutive typed
+// characters and deleted characters are undone together.
+func (b *Buffer) Replace(start, end int, text string) {
+	if start == end && text == "" {
+		return
+	}
+	e := edit{start: start, deleted: b.Slice(start, end), inserted: text}
+	b.replace(start, end, text)
+	b.record(e)
+}
+
+// SetText replaces the whole text. Only the part that differs from the current
+// text is changed, as one step that can be undone. The change is returned.
+func (b *Buffer) SetText(text string) Change {
+	b.StopCoalescing()
+	c := b.Update(text)
+	b.StopCoalescing()
+	return c
+}
+
+// Update changes the text to the given text like SetText but treats the
+// difference like typing, e.g. to follow the edits made in a text control.
+func (b *Buffer) Update(text string) Change {
+	old := b.String()
+	prefix := 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix &&
+		old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+	// Do not split UTF-8 sequences.
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+	c := Change{Start: prefix, End: len(old) - suffix, Text: text[prefix : len(text)-suffix]}
+	b.Replace(c.Start, c.End, c.Text)
+	return c
+}
+
+// BeginGroup starts a group of changes that are undone in one step. Groups
+// can be nested, the outermost EndGroup ends the group.
+func (b *Buffer) BeginGroup() {
+	if b.groupDepth == 0 {
+		b.groupOpen = false
+	}
+	b.groupDepth++
+}
+
+func (b *Buffer) EndGroup() {
+	if b.groupDepth > 0 {
+		b.groupDepth--
+	}
+	if b.groupDepth == 0 {
+		b.coalesce = false
+	}
+}
+
+// StopCoalescing makes the next change a new undo step, even if it continues
+// the last typed characters, e.g. after the caret was moved.
+func (b *Buffer) StopCoalescing() {
+	b.coalesce = false
+}
+
+func (b *Buffer) CanUndo() bool {
+	return len(b.undo) > 0
+}
+
+func (b *Buffer) CanRedo() bool {
+	return len(b.redo) > 0
+}
+
+// Undo reverts the last undo step. It returns the changes that were made to
+// the text, in order, so a view of the text can follow them.
+func (b *Buffer) Undo() []Change {
+	if len(b.undo) == 0 {
+		return nil
+	}
+	group := b.undo[len(b.undo)-1]
+	b.undo = b.undo[:len(b.undo)-1]
+	b.redo = append(b.redo, group)
+	b.coalesce = false
+
+	var changes []Change
+	for i := len(group) - 1; i >= 0; i-- {
+		e := group[i]
+		c := Change{
+			Start: e.start,
+			End:   e.start + len(e.inserted),
+			Text:  e.deleted,
+		}
+		b.replace(c.Start, c.End, c.Text)
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// Redo repeats the last undone step. It returns the changes that were made to
+// the text, in order.
+func (b *Buffer) Redo() []Change {
+	if len(b.redo) == 0 {
+		return nil
+	}
+	group := b.redo[len(b.redo)-1]
+	b.redo = b.redo[:len(b.redo)-1]
+	b.undo = append(b.undo, group)
+	b.coalesce = false
+
+	var changes []Change
+	for _, e := range group {
+		c := Change{
+			Start: e.start,
+			End:   e.start + len(e.deleted),
+			Text:  e.inserted,
+		}
+		b.replace(c.Start, c.End, c.Text)
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+func (b *Buffer) record(e edit) {
+	b.redo = nil
+
+	if b.groupDepth > 0 && b.groupOpen {
+		last := len(b.undo) - 1
+		b.undo[last] = append(b.undo[last], e)
+		return
+	}
+
+	if b.coalesce && b.groupDepth == 0 && len(b.undo) > 0 {
+		group := b.undo[len(b.undo)-1]
+		if merged, ok := coalesce(group[len(group)-1], e); ok {
+			group[len(group)-1] = merged
+			return
+		}
+	}
+
+	b.undo = append(b.undo, []edit{e})
+	b.groupOpen = b.groupDepth > 0
+	b.coalesce = b.groupDepth == 0 && isTyping(e)
+}
+
+// isTyping reports whether the edit is a single typed or deleted character,
+// which can be merged with the following ones.
+func isTyping(e edit) bool {
+	return isSingleChar(e.inserted) || e.inserted == "" && isSingleChar(e.deleted)
+}
+
+func isSingleChar(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return s != "" && size == len(s) && r != '\n'
+}
+
+// coalesce merges the typed character e into the edit last. Typing is merged
+// until a new word starts, deleting is merged as long as it continues at the
+// same place.
+func coalesce(last, e edit) (edit, bool) {
+	if e.deleted == "" && isSingleChar(e.inserted) &&
+		last.inserted != "" && e.start == last.start+len(last.inserted) {
+		prev, _ := utf8.DecodeLastRuneInString(last.inserted)
+		next, _ := utf8.DecodeRuneInString(e.inserted)
+		if unicode.IsSpace(prev) && !unicode.IsSpace(next) {
+			return last, false
+		}
+		last.inserted += e.inserted
+		return last, true
+	}
+
+	if e.inserted == "" && isSingleChar(e.deleted) && last.inserted == "" {
+		switch {
+		case e.start+len(e.deleted) == last.start:
+			// Backspace.
+			last.start = e.start
+			last.deleted = e.deleted + last.deleted
+			return last, true
+		case e.start == last.start:
+			// Delete key.
+			last.deleted += e.deleted
+			return last, true
+		}
+	}
+
+	return last, false
+}
+
+// replace changes the pieces without recording the change.
+func (b *Buffer) replace(start, end int, text string) {
+	var before, after []piece
+	pos := 0
+	for _, p := range b.pieces {
+		pieceEnd := pos + p.length
+		if pieceEnd <= start {
+			before = append(before, p)
+		} else if pos >= end {
+			after = append(after, p)
+		} else {
+			if pos < start {
+				before = append(before, b.cut(p, 0, start-pos))
+			}
+			if end < pieceEnd {
+				after = append(after, b.cut(p, end-pos, p.length))
+			}
+		}
+		pos = pieceEnd
+	}
+
+	if text != "" {
+		n := strings.Count(text, "\n")
+		// Typing appends to the last inserted piece.
+		if len(before) > 0 {
+			last := &before[len(before)-1]
+			if last.added && last.start+last.length == b.added.Len() {
+				b.added.WriteString(text)
+				last.length += len(text)
+				last.newlines += n
+				text = ""
+			}
+		}
+		if text != "" {
+			before = append(before, piece{
+				added:    true,
+				start:    b.added.Len(),
+				length:   len(text),
+				newlines: n,
+			})
+			b.added.WriteString(text)
+		}
+	}
+
+	b.pieces = append(before, after...)
+	b.length = 0
+	b.newlines = 0
+	for _, p := range b.pieces {
+		b.length += p.length
+		b.newlines += p.newlines
+	}
+}
+
+// cut returns the part of p from start to end, relative to p.
+func (b *Buffer) cut(p piece, start, end int) piece {
+	q := piece{added: p.added, start: p.start + start, length: end - start}
+	q.newlines = strings.Count(b.source(q), "\n")
+	return q
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,255 @@
+package textbuf
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func checkText(t *testing.T, b *Buffer, want string) {
+	t.Helper()
+	if got := b.String(); got != want {
+		t.Fatalf("want text %q but have %q", want, got)
+	}
+	if b.Len() != len(want) {
+		t.Fatalf("want length %d but have %d", len(want), b.Len())
+	}
+}
+
+// typeText types text one character at a time at pos.
+func typeText(b *Buffer, pos int, text string) {
+	for _, r := range text {
+		b.Replace(pos, pos, string(r))
+		pos += len(string(r))
+	}
+}
+
+func TestReplaceMatchesStringOperations(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	want := "first line\nsecond line\nthird\n"
+	b := New(want)
+	for i := 0; i < 2000; i++ {
+		start := r.Intn(len(want) + 1)
+		end := start + r.Intn(len(want)-start+1)
+		text := []string{"", "x", "\n", "ab\ncd", "äö"}[r.Intn(5)]
+		b.Replace(start, end, text)
+		want = want[:start] + text + want[end:]
+		checkText(t, b, want)
+		if b.LineCount() != strings.Count(want, "\n")+1 {
+			t.Fatalf("want %d lines but have %d",
+				strings.Count(want, "\n")+1, b.LineCount())
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	b := New("hello world")
+	b.Replace(5, 5, ",")
+	b.Replace(0, 1, "H")
+	checkText(t, b, "Hello, world")
+	if s := b.Slice(3, 9); s != "lo, wo" {
+		t.Errorf("want %q but have %q", "lo, wo", s)
+	}
+	if s := b.Slice(4, 4); s != "" {
+		t.Errorf("want empty slice but have %q", s)
+	}
+}
+
+func TestLineIndex(t *testing.T) {
+	b := New("a\nbb\n")
+	b.Replace(5, 5, "ccc\n\ndd")
+	checkText(t, b, "a\nbb\nccc\n\ndd")
+
+	if n := b.LineCount(); n != 5 {
+		t.Errorf("want 5 lines but have %d", n)
+	}
+	for line, want := range []int{0, 2, 5, 9, 10, 12, 12} {
+		if got := b.LineStart(line); got != want {
+			t.Errorf("line %d: want start %d but have %d", line, want, got)
+		}
+	}
+	for pos, want := range []int{0, 0, 1, 1, 1, 2, 2, 2, 2, 3, 4, 4, 4} {
+		if got := b.LineOf(pos); got != want {
+			t.Errorf("position %d: want line %d but have %d", pos, want, got)
+		}
+	}
+}
+
+func TestEmptyBuffer(t *testing.T) {
+	var b Buffer
+	checkText(t, &b, "")
+	if b.LineCount() != 1 || b.LineStart(1) != 0 || b.LineOf(0) != 0 {
+		t.Error("empty buffer must have a single empty line")
+	}
+	if b.Undo() != nil || b.Redo() != nil {
+		t.Error("empty buffer must have nothing to undo or redo")
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	b := New("abc")
+	b.Replace(1, 2, "XY")
+	b.StopCoalescing()
+	b.Replace(0, 0, "\n")
+	checkText(t, b, "\naXYc")
+
+	changes := b.Undo()
+	checkText(t, b, "aXYc")
+	if want := []Change{{Start: 0, End: 1, Text: ""}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes %v but have %v", want, changes)
+	}
+	b.Undo()
+	checkText(t, b, "abc")
+	if b.CanUndo() {
+		t.Error("everything was undone")
+	}
+
+	changes = b.Redo()
+	checkText(t, b, "aXYc")
+	if want := []Change{{Start: 1, End: 2, Text: "XY"}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes %v but have %v", want, changes)
+	}
+	b.Redo()
+	checkText(t, b, "\naXYc")
+	if b.CanRedo() {
+		t.Error("everything was redone")
+	}
+}
+
+func TestNewChangeClearsRedo(t *testing.T) {
+	b := New("a")
+	b.Replace(1, 1, "\n")
+	b.Undo()
+	b.Replace(0, 1, "b")
+	if b.CanRedo() {
+		t.Error("redo must not be possible after a new change")
+	}
+}
+
+func TestTypingIsUndoneByWord(t *testing.T) {
+	b := New("")
+	typeText(b, 0, "hello world")
+	checkText(t, b, "hello world")
+
+	b.Undo()
+	checkText(t, b, "hello ")
+	b.Undo()
+	checkText(t, b, "")
+}
+
+func TestNewLineEndsTyping(t *testing.T) {
+	b := New("")
+	typeText(b, 0, "ab\ncd")
+	b.Undo()
+	checkText(t, b, "ab\n")
+	b.Undo()
+	checkText(t, b, "ab")
+	b.Undo()
+	checkText(t, b, "")
+}
+
+func TestTypingElsewhereIsNewStep(t *testing.T) {
+	b := New("----")
+	typeText(b, 4, "ab")
+	typeText(b, 0, "cd")
+	checkText(t, b, "cd----ab")
+	b.Undo()
+	checkText(t, b, "----ab")
+}
+
+func TestStopCoalescing(t *testing.T) {
+	b := New("")
+	typeText(b, 0, "ab")
+	b.StopCoalescing()
+	typeText(b, 2, "cd")
+	b.Undo()
+	checkText(t, b, "ab")
+}
+
+func TestBackspaceAndDeleteAreCoalesced(t *testing.T) {
+	b := New("abcdef")
+	b.Replace(2, 3, "")
+	b.Replace(1, 2, "")
+	b.Replace(0, 1, "")
+	checkText(t, b, "def")
+	b.Undo()
+	checkText(t, b, "abcdef")
+
+	b.Replace(1, 2, "")
+	b.Replace(1, 2, "")
+	checkText(t, b, "adef")
+	b.Undo()
+	checkText(t, b, "abcdef")
+}
+
+func TestTypingOverSelection(t *testing.T) {
+	b := New("a selection")
+	b.Replace(2, 11, "w")
+	typeText(b, 3, "ord")
+	checkText(t, b, "a word")
+	b.Undo()
+	checkText(t, b, "a selection")
+}
+
+func TestGroups(t *testing.T) {
+	b := New("x x x")
+	b.BeginGroup()
+	b.Replace(0, 1, "y")
+	b.BeginGroup()
+	b.Replace(2, 3, "y")
+	b.EndGroup()
+	b.Replace(4, 5, "y")
+	b.EndGroup()
+	checkText(t, b, "y y y")
+
+	changes := b.Undo()
+	checkText(t, b, "x x x")
+	if len(changes) != 3 || changes[0].Start != 4 || changes[2].Start != 0 {
+		t.Errorf("undo must revert the changes in reverse order: %v", changes)
+	}
+	b.Redo()
+	checkText(t, b, "y y y")
+}
+
+func TestSetTextIsOneStep(t *testing.T) {
+	b := New("")
+	typeText(b, 0, "func")
+	b.SetText("func main() {}")
+	checkText(t, b, "func main() {}")
+	b.Undo()
+	checkText(t, b, "func")
+	b.Undo()
+	checkText(t, b, "")
+}
+
+func TestSetTextOnlyChangesDifference(t *testing.T) {
+	b := New("a\nb\nc")
+	change := b.SetText("a\nX\nc")
+	if want := (Change{Start: 2, End: 3, Text: "X"}); change != want {
+		t.Errorf("want change %v but have %v", want, change)
+	}
+	changes := b.Undo()
+	if want := []Change{{Start: 2, End: 3, Text: "b"}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes %v but have %v", want, changes)
+	}
+}
+
+func TestUpdateDoesNotSplitRunes(t *testing.T) {
+	b := New("ä")
+	b.Update("ö")
+	checkText(t, b, "ö")
+	changes := b.Undo()
+	if want := []Change{{Start: 0, End: 2, Text: "ä"}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("want changes %v but have %v", want, changes)
+	}
+}
+
+func TestUpdateFollowsTyping(t *testing.T) {
+	b := New("")
+	for _, s := range []string{"a", "ab", "abc"} {
+		b.Update(s)
+	}
+	b.Undo()
+	checkText(t, b, "")
+}
@@ -0,0 +1,300 @@
+// Package textfile converts between the bytes of a text file and the text that
+// is shown in the editor. It remembers the file's encoding, byte order mark and
+// line ending style so saving writes the file back the way it was.
+package textfile
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF16LE
+	UTF16BE
+	// Windows1252 is the legacy "ANSI" code page of western Windows systems.
+	// Files that are not valid UTF-8 are read with it.
+	Windows1252
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case UTF16LE:
+		return "UTF-16 LE"
+	case UTF16BE:
+		return "UTF-16 BE"
+	case Windows1252:
+		return "Windows-1252"
+	default:
+		return "UTF-8"
+	}
+}
+
+type LineEnding int
+
+const (
+	LF LineEnding = iota
+	CRLF
+	CR
+)
+
+func (l LineEnding) String() string {
+	switch l {
+	case CRLF:
+		return "CRLF"
+	case CR:
+		return "CR"
+	default:
+		return "LF"
+	}
+}
+
+// Format describes how text is stored in a file.
+type Format struct {
+	Encoding   Encoding
+	BOM        bool
+	LineEnding LineEnding
+}
+
+// ErrNotRoundTrip is returned by Decode if saving the decoded text with the
+// detected format would not reproduce the file, e.g. because it mixes line
+// endings or contains bytes that are invalid in its encoding.
+var ErrNotRoundTrip = errors.New("the file cannot be saved exactly as it was read")
+
+// ErrUnencodable is returned by Encode if the text contains characters that
+// the format's encoding cannot represent.
+var ErrUnencodable = errors.New("the text contains characters that the encoding cannot represent")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Decode returns the text of a file with all line endings converted to "\n"
+// and the format of the file. If the text is valid but writing it back with
+// Encode would not produce the same bytes, the error is ErrNotRoundTrip.
+func Decode(data []byte) (string, Format, error) {
+	var f Format
+	var text string
+
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		f.Encoding, f.BOM = UTF8, true
+		text = string(data[len(bomUTF8):])
+	case bytes.HasPrefix(data, bomUTF16LE):
+		f.Encoding, f.BOM = UTF16LE, true
+		text = decodeUTF16(data[len(bomUTF16LE):], false)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		f.Encoding, f.BOM = UTF16BE, true
+		text = decodeUTF16(data[len(bomUTF16BE):], true)
+	case utf8.Valid(data):
+		if e, ok := guessUTF16(data); ok {
+			f.Encoding = e
+			text = decodeUTF16(data, e == UTF16BE)
+		} else {
+			text = string(data)
+		}
+	default:
+		if e, ok := guessUTF16(data); ok {
+			f.Encoding = e
+			text = decodeUTF16(data, e == UTF16BE)
+		} else {
+			f.Encoding = Windows1252
+			text = decodeWindows1252(data)
+		}
+	}
+
+	f.LineEnding = detectLineEnding(text)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	if again, err := Encode(text, f); err != nil || !bytes.Equal(again, data) {
+		return text, f, ErrNotRoundTrip
+	}
+	return text, f, nil
+}
+
+// Encode converts text with "\n" line endings to the bytes of a file in the
+// given format. If some characters cannot be represented in the encoding, they
+// are replaced by '?' and the error is ErrUnencodable.
+func Encode(text string, f Format) ([]byte, error) {
+	switch f.LineEnding {
+	case CRLF:
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	case CR:
+		text = strings.ReplaceAll(text, "\n", "\r")
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch f.Encoding {
+	case UTF16LE, UTF16BE:
+		bigEndian := f.Encoding == UTF16BE
+		if f.BOM {
+			if bigEndian {
+				buf.Write(bomUTF16BE)
+			} else {
+				buf.Write(bomUTF16LE)
+			}
+		}
+		for _, u := range utf16.Encode([]rune(text)) {
+			if bigEndian {
+				buf.WriteByte(byte(u >> 8))
+				buf.WriteByte(byte(u))
+			} else {
+				buf.WriteByte(byte(u))
+				buf.WriteByte(byte(u >> 8))
+			}
+		}
+	case Windows1252:
+		for _, r := range text {
+			b, ok := encodeWindows1252(r)
+			if !ok {
+				b, err = '?', ErrUnencodable
+			}
+			buf.WriteByte(b)
+		}
+	default:
+		if f.BOM {
+			buf.Write(bomUTF8)
+		}
+		buf.WriteString(text)
+	}
+	return buf.Bytes(), err
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		}
+	}
+	text := string(utf16.Decode(units))
+	if len(data)%2 == 1 {
+		// A dangling byte cannot be represented, the round trip check in
+		// Decode will notice that it is missing.
+		text += string(utf8.RuneError)
+	}
+	return text
+}
+
+// IsText reports whether data, the start of a file, looks like text in one of
+// the encodings that Decode supports. Binary files like programs and images
+// contain zero bytes or many other control characters.
+func IsText(data []byte) bool {
+	if bytes.HasPrefix(data, bomUTF8) ||
+		bytes.HasPrefix(data, bomUTF16LE) ||
+		bytes.HasPrefix(data, bomUTF16BE) {
+		return true
+	}
+	if _, ok := guessUTF16(data[:len(data)/2*2]); ok {
+		return true
+	}
+	control := 0
+	for _, b := range data {
+		switch {
+		case b == 0:
+			return false
+		case b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == 0x1B:
+			// Tabs, line breaks, form feeds and escape sequences for
+			// terminal colors are common in text.
+		case b < ' ' || b == 0x7F:
+			control++
+		}
+	}
+	return control*20 <= len(data)
+}
+
+// guessUTF16 detects UTF-16 text without byte order mark. Text files with
+// mostly ASCII characters have a zero byte in every second position.
+func guessUTF16(data []byte) (Encoding, bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return 0, false
+	}
+	var evenZeros, oddZeros int
+	for i, b := range data {
+		if b == 0 {
+			if i%2 == 0 {
+				evenZeros++
+			} else {
+				oddZeros++
+			}
+		}
+	}
+	half := len(data) / 2
+	switch {
+	case evenZeros == 0 && oddZeros > half/2:
+		return UTF16LE, true
+	case oddZeros == 0 && evenZeros > half/2:
+		return UTF16BE, true
+	}
+	return 0, false
+}
+
+// detectLineEnding returns the most common line ending in text, LF for text
+// without line breaks.
+func detectLineEnding(text string) LineEnding {
+	var lf, crlf, cr int
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				crlf++
+				i++
+			} else {
+				cr++
+			}
+		case '\n':
+			lf++
+		}
+	}
+	switch {
+	case crlf > lf && crlf >= cr:
+		return CRLF
+	case cr > lf && cr > crlf:
+		return CR
+	default:
+		return LF
+	}
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F, all other bytes are the same as in
+// Latin-1. Undefined bytes map to the C1 control characters of the same value,
+// so every byte round-trips.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	for _, c := range data {
+		if 0x80 <= c && c <= 0x9F {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+func encodeWindows1252(r rune) (byte, bool) {
+	if r < 0x80 || 0xA0 <= r && r <= 0xFF {
+		return byte(r), true
+	}
+	for i, w := range windows1252 {
+		if w == r {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,96 @@
+package textfile
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		text   string
+		format Format
+	}{
+		{"empty", nil, "", Format{}},
+		{"UTF-8", []byte("a\nä\n"), "a\nä\n", Format{UTF8, false, LF}},
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBFa\nä\n"), "a\nä\n", Format{UTF8, true, LF}},
+		{"CRLF", []byte("a\r\nb\r\n"), "a\nb\n", Format{UTF8, false, CRLF}},
+		{"CR", []byte("a\rb\r"), "a\nb\n", Format{UTF8, false, CR}},
+		{"UTF-16 LE BOM", []byte("\xFF\xFEa\x00\n\x00"), "a\n", Format{UTF16LE, true, LF}},
+		{"UTF-16 BE BOM", []byte("\xFE\xFF\x00a\x00\n"), "a\n", Format{UTF16BE, true, LF}},
+		{"UTF-16 LE", []byte("a\x00b\x00\r\x00\n\x00"), "ab\n", Format{UTF16LE, false, CRLF}},
+		{"UTF-16 BE", []byte("\x00a\x00b\x00\n"), "ab\n", Format{UTF16BE, false, LF}},
+		{"Windows-1252", []byte("gr\xFC\xDFe\r\n"), "grüße\n", Format{Windows1252, false, CRLF}},
+	}
+	for _, test := range tests {
+		text, f, err := Decode(test.data)
+		if err != nil {
+			t.Errorf("%s: decode failed: %v", test.name, err)
+			continue
+		}
+		if text != test.text || f != test.format {
+			t.Errorf("%s: want %q %+v but have %q %+v", test.name, test.text, test.format, text, f)
+		}
+		data, err := Encode(text, f)
+		if err != nil || !bytes.Equal(data, test.data) {
+			t.Errorf("%s: want %q but encoded %q, %v", test.name, test.data, data, err)
+		}
+	}
+}
+
+func TestNotRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		text string
+	}{
+		{"mixed line endings", []byte("a\r\nb\nc\r\n"), "a\nb\nc\n"},
+		{"dangling UTF-16 byte", []byte("\xFF\xFEa\x00b"), "a�"},
+	}
+	for _, test := range tests {
+		text, _, err := Decode(test.data)
+		if err != ErrNotRoundTrip {
+			t.Errorf("%s: want ErrNotRoundTrip but have %v", test.name, err)
+		}
+		if text != test.text {
+			t.Errorf("%s: want text %q but have %q", test.name, test.text, text)
+		}
+	}
+}
+
+func TestEncodeUnencodable(t *testing.T) {
+	data, err := Encode("a€☺", Format{Encoding: Windows1252})
+	if err != ErrUnencodable || string(data) != "a\x80?" {
+		t.Errorf("want %q, ErrUnencodable but have %q, %v", "a\x80?", data, err)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		text bool
+	}{
+		{"empty", nil, true},
+		{"ASCII", []byte("package main\r\n\tfunc main() {}\n"), true},
+		{"UTF-8", []byte("grüße"), true},
+		{"Windows-1252", []byte("gr\xFC\xDFe"), true},
+		{"terminal colors", []byte("\x1B[31mrot\x1B[0m\n"), true},
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBFa"), true},
+		{"UTF-16 LE BOM", []byte("\xFF\xFEa\x00"), true},
+		{"UTF-16 BE BOM", []byte("\xFE\xFF\x00a"), true},
+		{"UTF-16 LE", []byte("a\x00b\x00c\x00\n\x00"), true},
+		{"UTF-16 BE", []byte("\x00a\x00b\x00c\x00\n"), true},
+		{"UTF-16 with odd length", []byte("a\x00b\x00c\x00\n\x00d"), true},
+		{"NUL byte", []byte("text\x00text"), false},
+		{"control characters", []byte("\x01\x02\x03\x04abc"), false},
+		{"PE header", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xFF\xFF\x00\x00"), false},
+		{"PNG header", []byte("\x89PNG\r\n\x1A\n\x00\x00\x00\rIHDR"), false},
+	}
+	for _, test := range tests {
+		if got := IsText(test.data); got != test.text {
+			t.Errorf("%s: want %v but have %v", test.name, test.text, got)
+		}
+	}
+}
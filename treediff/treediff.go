@@ -0,0 +1,92 @@
+// Package treediff compares two versions of a tree, e.g. of the project
+// folders, and lists the items that have to be inserted and removed to turn
+// the old tree into the new one. Items that are in both trees are kept, so a
+// tree view can keep them expanded and selected.
+package treediff
+
+// Node is an item in a tree. Its Key identifies it in the whole tree, e.g. a
+// file path.
+type Node struct {
+	Key      string
+	Folder   bool
+	Children []Node
+}
+
+type Kind int
+
+const (
+	Insert Kind = iota
+	Remove
+)
+
+// Change inserts or removes Node. Changes must be applied in order. Among
+// siblings, nodes are removed before others are inserted, so a file is gone
+// before a folder with the same key takes its place.
+type Change struct {
+	Kind Kind
+	// Node is inserted with all its children or removed with them.
+	Node Node
+	// Parent is the key of the parent node, it is empty for top-level nodes.
+	Parent string
+	// After is the key of the sibling that an inserted node is placed after.
+	// It is empty if the node becomes the first child.
+	After string
+}
+
+// Diff returns the changes that turn the old list of top-level nodes into
+// the new one. Nodes with the same key and kind are kept and their children
+// are compared.
+func Diff(old, new []Node) []Change {
+	return diff("", old, new, nil)
+}
+
+func diff(parent string, old, new []Node, changes []Change) []Change {
+	// common[i][j] is the length of the longest common sub-sequence of
+	// old[i:] and new[j:].
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if same(old[i], new[j]) {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	// The removals go first, the inserts and the changes of kept nodes are
+	// appended to them afterwards.
+	var rest []Change
+	after := ""
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		if i < len(old) && j < len(new) && same(old[i], new[j]) {
+			rest = diff(new[j].Key, old[i].Children, new[j].Children, rest)
+			after = new[j].Key
+			i++
+			j++
+		} else if i < len(old) && (j == len(new) || common[i+1][j] >= common[i][j+1]) {
+			changes = append(changes, Change{Kind: Remove, Node: old[i], Parent: parent})
+			i++
+		} else {
+			rest = append(rest, Change{
+				Kind:   Insert,
+				Node:   new[j],
+				Parent: parent,
+				After:  after,
+			})
+			after = new[j].Key
+			j++
+		}
+	}
+	return append(changes, rest...)
+}
+
+func same(a, b Node) bool {
+	return a.Key == b.Key && a.Folder == b.Folder
+}
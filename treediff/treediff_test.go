@@ -0,0 +1,165 @@
+package treediff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func file(key string) Node {
+	return Node{Key: key}
+}
+
+func folder(key string, children ...Node) Node {
+	return Node{Key: key, Folder: true, Children: children}
+}
+
+func TestNoChanges(t *testing.T) {
+	tree := []Node{
+		folder("a", folder("a/b", file("a/b/x.go")), file("a/main.go")),
+		folder("c"),
+	}
+	if changes := Diff(tree, tree); len(changes) != 0 {
+		t.Errorf("want no changes but have %v", changes)
+	}
+	if changes := Diff(nil, nil); len(changes) != 0 {
+		t.Errorf("want no changes but have %v", changes)
+	}
+}
+
+func TestInsertAndRemove(t *testing.T) {
+	old := []Node{
+		folder("a", file("a/1.go"), file("a/3.go")),
+		folder("b", file("b/x.go")),
+		folder("c"),
+	}
+	new := []Node{
+		folder("a", file("a/0.go"), file("a/1.go"), file("a/2.go"), file("a/3.go")),
+		folder("c", file("c/y.go")),
+		folder("d"),
+	}
+	want := []Change{
+		{Kind: Remove, Node: folder("b", file("b/x.go")), Parent: ""},
+		{Kind: Insert, Node: file("a/0.go"), Parent: "a", After: ""},
+		{Kind: Insert, Node: file("a/2.go"), Parent: "a", After: "a/1.go"},
+		{Kind: Insert, Node: file("c/y.go"), Parent: "c", After: ""},
+		{Kind: Insert, Node: folder("d"), Parent: "", After: "c"},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, got)
+	}
+}
+
+func TestReplaceFileWithFolder(t *testing.T) {
+	old := []Node{file("x"), file("y")}
+	new := []Node{folder("x", file("x/z")), file("y")}
+	want := []Change{
+		{Kind: Remove, Node: file("x")},
+		{Kind: Insert, Node: folder("x", file("x/z"))},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, got)
+	}
+}
+
+func TestReplaceFileWithFolderBeforeIt(t *testing.T) {
+	// The folder a is inserted before b, the file a is removed after b. The
+	// file must still be removed first, both have the same key.
+	old := []Node{folder("b"), file("a")}
+	new := []Node{folder("a"), folder("b")}
+	want := []Change{
+		{Kind: Remove, Node: file("a")},
+		{Kind: Insert, Node: folder("a")},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, got)
+	}
+}
+
+func TestFromEmpty(t *testing.T) {
+	new := []Node{folder("a", file("a/x")), folder("b")}
+	want := []Change{
+		{Kind: Insert, Node: folder("a", file("a/x"))},
+		{Kind: Insert, Node: folder("b"), After: "a"},
+	}
+	if got := Diff(nil, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, got)
+	}
+	want = []Change{
+		{Kind: Remove, Node: folder("a", file("a/x"))},
+		{Kind: Remove, Node: folder("b")},
+	}
+	if got := Diff(new, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("want\n%v\nbut have\n%v", want, got)
+	}
+}
+
+// TestApply applies the changes to a list of keys in tree order, the way a
+// tree view is updated, and checks that the result is the new tree.
+func TestApply(t *testing.T) {
+	old := []Node{
+		folder("p1", file("p1/a"), file("p1/b"), file("p1/c")),
+		folder("p2", folder("p2/sub", file("p2/sub/x"))),
+		folder("p4"),
+		folder("p5", folder("p5/b"), file("p5/a")),
+	}
+	new := []Node{
+		folder("p0"),
+		folder("p1", file("p1/c"), file("p1/d")),
+		folder("p2", folder("p2/sub", file("p2/sub/x")), file("p2/y")),
+		folder("p3", file("p3/main")),
+		folder("p5", folder("p5/a", file("p5/a/x")), folder("p5/b")),
+	}
+	items := map[string][]string{}
+	var add func(parent string, nodes []Node)
+	add = func(parent string, nodes []Node) {
+		for _, n := range nodes {
+			items[parent] = append(items[parent], n.Key)
+			add(n.Key, n.Children)
+		}
+	}
+	add("", old)
+
+	for _, c := range Diff(old, new) {
+		list := items[c.Parent]
+		if c.Kind == Remove {
+			for i := range list {
+				if list[i] == c.Node.Key {
+					list = append(list[:i], list[i+1:]...)
+					break
+				}
+			}
+			items[c.Parent] = list
+			continue
+		}
+		at := 0
+		if c.After != "" {
+			at = -1
+			for i := range list {
+				if list[i] == c.After {
+					at = i + 1
+				}
+			}
+			if at == -1 {
+				t.Fatalf("%v: the previous sibling does not exist", c)
+			}
+		}
+		list = append(list[:at], append([]string{c.Node.Key}, list[at:]...)...)
+		items[c.Parent] = list
+		delete(items, c.Node.Key)
+		add(c.Node.Key, c.Node.Children)
+	}
+
+	want := map[string][]string{}
+	add = func(parent string, nodes []Node) {
+		for _, n := range nodes {
+			want[parent] = append(want[parent], n.Key)
+			add(n.Key, n.Children)
+		}
+	}
+	add("", new)
+	for _, key := range []string{"", "p0", "p1", "p2", "p2/sub", "p3"} {
+		if !reflect.DeepEqual(items[key], want[key]) {
+			t.Errorf("%q: want %v but have %v", key, want[key], items[key])
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package watch reports changes to the files in a folder and all its
+// sub-folders. It uses ReadDirectoryChangesW on Windows and inotify on Linux.
+package watch
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrUnsupported = errors.New("watching folders is not supported on this system")
+
+// delay is how long changes are collected before they are reported. Saving
+// or building usually changes several files at once.
+const delay = 100 * time.Millisecond
+
+// Watcher watches a folder and all its sub-folders.
+type Watcher struct {
+	// Changes receives the paths of the files and folders that were created,
+	// changed, renamed or deleted. Changes that happen in quick succession
+	// are reported together, each path once. The channel is closed when the
+	// watcher is closed.
+	Changes <-chan []string
+
+	events    chan string
+	done      chan struct{}
+	closeOnce sync.Once
+	system    io.Closer
+}
+
+// New starts watching dir.
+func New(dir string) (*Watcher, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(chan []string)
+	w := &Watcher{
+		Changes: changes,
+		events:  make(chan string, 64),
+		done:    make(chan struct{}),
+	}
+	w.system, err = startSystem(dir, w.send)
+	if err != nil {
+		return nil, err
+	}
+	go w.collect(changes)
+	return w, nil
+}
+
+// Close stops watching and closes Changes.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.system.Close()
+	})
+	return err
+}
+
+// send is called by the system watcher for every change.
+func (w *Watcher) send(path string) {
+	select {
+	case w.events <- path:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) collect(changes chan<- []string) {
+	defer close(changes)
+
+	var (
+		pending []string
+		seen    = map[string]bool{}
+		timeout <-chan time.Time
+	)
+	for {
+		select {
+		case path := <-w.events:
+			if !seen[path] {
+				seen[path] = true
+				pending = append(pending, path)
+			}
+			if timeout == nil {
+				timeout = time.After(delay)
+			}
+		case <-timeout:
+			select {
+			case changes <- pending:
+			case <-w.done:
+				return
+			}
+			pending = nil
+			seen = map[string]bool{}
+			timeout = nil
+		case <-w.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+package watch
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const changeMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+type inotifyWatcher struct {
+	root string
+	file *os.File
+	conn syscall.RawConn
+	send func(path string)
+	// folders maps watch descriptors to their folders. inotify does not
+	// watch sub-folders, each folder has its own watch.
+	folders map[int32]string
+}
+
+func startSystem(dir string, send func(path string)) (io.Closer, error) {
+	// The file is non-blocking so that closing it stops a pending read.
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	file := os.NewFile(uintptr(fd), "inotify")
+	conn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		root:    dir,
+		file:    file,
+		conn:    conn,
+		send:    send,
+		folders: map[int32]string{},
+	}
+	if err := w.addFolder(dir); err != nil {
+		file.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// addFolder watches dir and its sub-folders. Only errors for dir itself are
+// returned, sub-folders might be gone already.
+func (w *inotifyWatcher) addFolder(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			return nil
+		}
+		if err == nil {
+			var wd int
+			controlErr := w.conn.Control(func(fd uintptr) {
+				wd, err = syscall.InotifyAddWatch(int(fd), path, changeMask)
+			})
+			if controlErr != nil {
+				err = controlErr
+			} else if err != nil {
+				err = os.NewSyscallError("inotify_add_watch", err)
+			}
+			if err == nil {
+				w.folders[int32(wd)] = path
+			}
+		}
+		if err != nil && path == dir {
+			return err
+		}
+		return nil
+	})
+}
+
+// removeFolder stops watching dir and its sub-folders.
+func (w *inotifyWatcher) removeFolder(dir string) {
+	for wd, path := range w.folders {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			w.conn.Control(func(fd uintptr) {
+				syscall.InotifyRmWatch(int(fd), uint32(wd))
+			})
+			delete(w.folders, wd)
+		}
+	}
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(e.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			if e.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				w.send(w.root)
+				continue
+			}
+			if e.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.folders, e.Wd)
+				continue
+			}
+			dir, ok := w.folders[e.Wd]
+			if !ok {
+				continue
+			}
+			path := dir
+			if name != "" {
+				path = filepath.Join(dir, name)
+			}
+			if e.Mask&syscall.IN_ISDIR != 0 {
+				if e.Mask&syscall.IN_MOVED_FROM != 0 {
+					w.removeFolder(path)
+				}
+				if e.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					w.addFolder(path)
+				}
+			}
+			w.send(path)
+		}
+	}
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
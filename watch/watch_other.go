@@ -0,0 +1,9 @@
+//go:build !windows && !linux
+
+package watch
+
+import "io"
+
+func startSystem(dir string, send func(path string)) (io.Closer, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,95 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor reads changes until path is reported.
+func waitFor(t *testing.T, w *Watcher, path string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case changes := <-w.Changes:
+			for _, c := range changes {
+				if c == path {
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no change reported for %s", path)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	if err := os.Mkdir(old, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(dir)
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	file := filepath.Join(dir, "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0666)
+	waitFor(t, w, file)
+
+	os.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0666)
+	waitFor(t, w, file)
+
+	nested := filepath.Join(old, "x.go")
+	os.WriteFile(nested, nil, 0666)
+	waitFor(t, w, nested)
+
+	// Folders that are created later are watched as well.
+	created := filepath.Join(dir, "new")
+	os.Mkdir(created, 0777)
+	waitFor(t, w, created)
+	inCreated := filepath.Join(created, "y.go")
+	os.WriteFile(inCreated, nil, 0666)
+	waitFor(t, w, inCreated)
+
+	os.Remove(nested)
+	waitFor(t, w, nested)
+}
+
+func TestClose(t *testing.T) {
+	w, err := New(t.TempDir())
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-w.Changes:
+		if ok {
+			t.Error("no changes expected after closing")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Changes was not closed")
+	}
+	w.Close()
+}
+
+func TestMissingFolder(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Error("error expected for a missing folder")
+	}
+}
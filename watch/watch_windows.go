@@ -0,0 +1,105 @@
+//go:build windows
+
+package watch
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const changeMask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME |
+	syscall.FILE_NOTIFY_CHANGE_DIR_NAME |
+	syscall.FILE_NOTIFY_CHANGE_SIZE |
+	syscall.FILE_NOTIFY_CHANGE_LAST_WRITE |
+	syscall.FILE_NOTIFY_CHANGE_CREATION
+
+// quitKey is posted to the completion port to stop watching.
+const quitKey = 1
+
+type windowsWatcher struct {
+	port    syscall.Handle
+	stopped chan struct{}
+}
+
+func startSystem(dir string, send func(path string)) (io.Closer, error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := syscall.CreateFile(
+		path,
+		syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED,
+		0,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	port, err := syscall.CreateIoCompletionPort(folder, 0, 0, 1)
+	if err != nil {
+		syscall.CloseHandle(folder)
+		return nil, err
+	}
+	w := &windowsWatcher{port: port, stopped: make(chan struct{})}
+	go w.run(dir, folder, send)
+	return w, nil
+}
+
+func (w *windowsWatcher) run(dir string, folder syscall.Handle, send func(path string)) {
+	defer close(w.stopped)
+	defer syscall.CloseHandle(folder)
+
+	// The system writes into buf while a read is pending, so it must not be
+	// released before the read is done.
+	buf := make([]byte, 64*1024)
+	var overlapped syscall.Overlapped
+	for {
+		err := syscall.ReadDirectoryChanges(
+			folder, &buf[0], uint32(len(buf)), true, changeMask, nil, &overlapped, 0,
+		)
+		if err != nil {
+			return
+		}
+
+		var n, key uint32
+		var done *syscall.Overlapped
+		err = syscall.GetQueuedCompletionStatus(w.port, &n, &key, &done, syscall.INFINITE)
+		if key == quitKey {
+			syscall.CancelIoEx(folder, &overlapped)
+			syscall.GetQueuedCompletionStatus(w.port, &n, &key, &done, syscall.INFINITE)
+			return
+		}
+		if err != nil {
+			// The folder was probably deleted.
+			return
+		}
+
+		if n == 0 {
+			// There were more changes than fit into buf.
+			send(dir)
+			continue
+		}
+		for offset := uint32(0); ; {
+			info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := unsafe.Slice(&info.FileName, info.FileNameLength/2)
+			send(filepath.Join(dir, syscall.UTF16ToString(name)))
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+	}
+}
+
+func (w *windowsWatcher) Close() error {
+	err := syscall.PostQueuedCompletionStatus(w.port, 0, quitKey, nil)
+	<-w.stopped
+	syscall.CloseHandle(w.port)
+	return err
+}
@@ -0,0 +1,583 @@
+package main
+
+// This file contains the Win32 functions, constants and types that are missing
+// from github.com/gonutz/w32.
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32/v3"
+)
+
+var (
+	user32 = syscall.NewLazyDLL("user32.dll")
+
+	createMenu      = user32.NewProc("CreateMenu")
+	createPopupMenu = user32.NewProc("CreatePopupMenu")
+	appendMenu      = user32.NewProc("AppendMenuW")
+	destroyMenu     = user32.NewProc("DestroyMenu")
+	trackPopupMenu  = user32.NewProc("TrackPopupMenu")
+	postMessage     = user32.NewProc("PostMessageW")
+	setCursor       = user32.NewProc("SetCursor")
+	setMenu         = user32.NewProc("SetMenu")
+	checkMenuItem   = user32.NewProc("CheckMenuItem")
+)
+
+const (
+	MF_BYCOMMAND = 0x0000
+	MF_UNCHECKED = 0x0000
+	MF_STRING    = 0x0000
+	MF_GRAYED    = 0x0001
+	MF_CHECKED   = 0x0008
+	MF_POPUP     = 0x0010
+	MF_SEPARATOR = 0x0800
+
+	TPM_RIGHTBUTTON = 0x0002
+	TPM_RETURNCMD   = 0x0100
+)
+
+func CreateMenu() w32.HMENU {
+	ret, _, _ := createMenu.Call()
+	return w32.HMENU(ret)
+}
+
+func CreatePopupMenu() w32.HMENU {
+	ret, _, _ := createPopupMenu.Call()
+	return w32.HMENU(ret)
+}
+
+func AppendMenu(menu w32.HMENU, flags uint32, id uintptr, text string) {
+	var s uintptr
+	if flags&MF_SEPARATOR == 0 {
+		s = uintptr(unsafe.Pointer(w32.String(text)))
+	}
+	appendMenu.Call(uintptr(menu), uintptr(flags), id, s)
+}
+
+func SetMenu(window w32.HWND, menu w32.HMENU) {
+	setMenu.Call(uintptr(window), uintptr(menu))
+}
+
+// CheckMenuItem sets or removes the check mark of the menu item with the given
+// ID.
+func CheckMenuItem(menu w32.HMENU, id uintptr, checked bool) {
+	var flags uintptr = MF_BYCOMMAND | MF_UNCHECKED
+	if checked {
+		flags = MF_BYCOMMAND | MF_CHECKED
+	}
+	checkMenuItem.Call(uintptr(menu), id, flags)
+}
+
+func DestroyMenu(menu w32.HMENU) {
+	destroyMenu.Call(uintptr(menu))
+}
+
+// TrackPopupMenu shows the menu at the given screen coordinates and returns
+// the ID of the chosen item or 0 if the menu was canceled.
+func TrackPopupMenu(menu w32.HMENU, x, y int32, owner w32.HWND) uintptr {
+	ret, _, _ := trackPopupMenu.Call(
+		uintptr(menu),
+		TPM_RETURNCMD|TPM_RIGHTBUTTON,
+		uintptr(x),
+		uintptr(y),
+		0,
+		uintptr(owner),
+		0,
+	)
+	return ret
+}
+
+func SetCursor(cursor w32.HCURSOR) w32.HCURSOR {
+	ret, _, _ := setCursor.Call(uintptr(cursor))
+	return w32.HCURSOR(ret)
+}
+
+func Edit_SetCueBannerText(edit w32.HWND, text string) {
+	w32.SendMessage(
+		edit,
+		w32.EM_SETCUEBANNER,
+		w32.TRUE,
+		uintptr(unsafe.Pointer(w32.String(text))),
+	)
+}
+
+// PostMessage is like w32.SendMessage but does not wait for the message to
+// be processed. It is safe to call from any goroutine.
+func PostMessage(window w32.HWND, message uint32, w, l uintptr) {
+	postMessage.Call(uintptr(window), uintptr(message), w, l)
+}
+
+// NotifyHeader returns the NMHDR that the l parameter of WM_NOTIFY points to.
+// The header belongs to the control that sends the message, not to the Go
+// heap, so converting the uintptr is safe. go vet cannot know that, reading
+// the pointer through &l tells it that the conversion is intended. All
+// WM_NOTIFY handlers use this and NotifyTreeView.
+func NotifyHeader(l uintptr) *w32.NMHDR {
+	return (*w32.NMHDR)(*(*unsafe.Pointer)(unsafe.Pointer(&l)))
+}
+
+// NotifyTreeView returns the NMTREEVIEW of a tree view's WM_NOTIFY message.
+func NotifyTreeView(l uintptr) *w32.NMTREEVIEW {
+	return (*w32.NMTREEVIEW)(*(*unsafe.Pointer)(unsafe.Pointer(&l)))
+}
+
+const (
+	WC_LISTVIEW = "SysListView32"
+
+	LVS_REPORT           = 0x0001
+	LVS_SINGLESEL        = 0x0004
+	LVS_SHOWSELALWAYS    = 0x0008
+	LVS_EX_GRIDLINES     = 0x0001
+	LVS_EX_FULLROWSELECT = 0x0020
+
+	LVM_FIRST                    = 0x1000
+	LVM_DELETEALLITEMS           = LVM_FIRST + 9
+	LVM_GETNEXTITEM              = LVM_FIRST + 12
+	LVM_SETCOLUMNWIDTH           = LVM_FIRST + 30
+	LVM_SETEXTENDEDLISTVIEWSTYLE = LVM_FIRST + 54
+	LVM_INSERTITEMW              = LVM_FIRST + 77
+	LVM_INSERTCOLUMNW            = LVM_FIRST + 97
+	LVM_SETITEMTEXTW             = LVM_FIRST + 116
+
+	LVNI_SELECTED = 0x0002
+
+	LVCF_WIDTH = 0x0002
+	LVCF_TEXT  = 0x0004
+
+	LVIF_TEXT  = 0x0001
+	LVIF_PARAM = 0x0004
+
+	LVSCW_AUTOSIZE_USEHEADER = -2
+)
+
+type LVCOLUMN struct {
+	Mask      uint32
+	Fmt       int32
+	Cx        int32
+	Text      w32.UTF16String
+	TextMax   int32
+	SubItem   int32
+	Image     int32
+	Order     int32
+	CxMin     int32
+	CxDefault int32
+	CxIdeal   int32
+}
+
+type LVITEM struct {
+	Mask       uint32
+	Item       int32
+	SubItem    int32
+	State      uint32
+	StateMask  uint32
+	Text       w32.UTF16String
+	TextMax    int32
+	Image      int32
+	Param      uintptr
+	Indent     int32
+	GroupID    int32
+	Columns    uint32
+	ColumnsPtr *uint32
+	ColFmt     *int32
+	Group      int32
+}
+
+func ListView_InsertColumn(list w32.HWND, index int, text string, width int) {
+	col := LVCOLUMN{
+		Mask: LVCF_TEXT | LVCF_WIDTH,
+		Cx:   int32(width),
+		Text: w32.String(text),
+	}
+	w32.SendMessage(list, LVM_INSERTCOLUMNW, uintptr(index), uintptr(unsafe.Pointer(&col)))
+}
+
+// ListView_AddRow appends a row and returns its index.
+func ListView_AddRow(list w32.HWND, columns ...string) int {
+	item := LVITEM{
+		Mask: LVIF_TEXT,
+		Item: 0x7FFFFFFF,
+		Text: w32.String(columns[0]),
+	}
+	index := int32(w32.SendMessage(list, LVM_INSERTITEMW, 0, uintptr(unsafe.Pointer(&item))))
+	for i := 1; i < len(columns); i++ {
+		ListView_SetItemText(list, int(index), i, columns[i])
+	}
+	return int(index)
+}
+
+func ListView_SetItemText(list w32.HWND, row, column int, text string) {
+	item := LVITEM{
+		SubItem: int32(column),
+		Text:    w32.String(text),
+	}
+	w32.SendMessage(list, LVM_SETITEMTEXTW, uintptr(row), uintptr(unsafe.Pointer(&item)))
+}
+
+func ListView_DeleteAllItems(list w32.HWND) {
+	w32.SendMessage(list, LVM_DELETEALLITEMS, 0, 0)
+}
+
+// ListView_GetSelection returns the index of the first selected row or -1.
+func ListView_GetSelection(list w32.HWND) int {
+	minusOne := -1
+	return int(int32(w32.SendMessage(list, LVM_GETNEXTITEM, uintptr(minusOne), LVNI_SELECTED)))
+}
+
+const (
+	WC_TABCONTROL = "SysTabControl32"
+
+	TCM_FIRST          = 0x1300
+	TCM_DELETEITEM     = TCM_FIRST + 8
+	TCM_DELETEALLITEMS = TCM_FIRST + 9
+	TCM_GETCURSEL      = TCM_FIRST + 11
+	TCM_SETCURSEL      = TCM_FIRST + 12
+	TCM_HITTEST        = TCM_FIRST + 13
+	TCM_SETITEMW       = TCM_FIRST + 61
+	TCM_INSERTITEMW    = TCM_FIRST + 62
+
+	TCN_FIRST     = 0xFFFFFDDA // -550
+	TCN_SELCHANGE = TCN_FIRST - 1
+
+	TCIF_TEXT = 0x0001
+)
+
+type TCITEM struct {
+	Mask      uint32
+	State     uint32
+	StateMask uint32
+	Text      w32.UTF16String
+	TextMax   int32
+	Image     int32
+	Param     uintptr
+}
+
+type TCHITTESTINFO struct {
+	Pt    w32.POINT
+	Flags uint32
+}
+
+func TabCtrl_InsertItem(tabs w32.HWND, index int, text string) {
+	item := TCITEM{Mask: TCIF_TEXT, Text: w32.String(text)}
+	w32.SendMessage(tabs, TCM_INSERTITEMW, uintptr(index), uintptr(unsafe.Pointer(&item)))
+}
+
+func TabCtrl_SetItemText(tabs w32.HWND, index int, text string) {
+	item := TCITEM{Mask: TCIF_TEXT, Text: w32.String(text)}
+	w32.SendMessage(tabs, TCM_SETITEMW, uintptr(index), uintptr(unsafe.Pointer(&item)))
+}
+
+func TabCtrl_DeleteItem(tabs w32.HWND, index int) {
+	w32.SendMessage(tabs, TCM_DELETEITEM, uintptr(index), 0)
+}
+
+func TabCtrl_GetCurSel(tabs w32.HWND) int {
+	return int(int32(w32.SendMessage(tabs, TCM_GETCURSEL, 0, 0)))
+}
+
+func TabCtrl_SetCurSel(tabs w32.HWND, index int) {
+	w32.SendMessage(tabs, TCM_SETCURSEL, uintptr(index), 0)
+}
+
+// TabCtrl_HitTest returns the index of the tab at the client coordinates or -1.
+func TabCtrl_HitTest(tabs w32.HWND, p w32.POINT) int {
+	info := TCHITTESTINFO{Pt: p}
+	return int(int32(w32.SendMessage(tabs, TCM_HITTEST, 0, uintptr(unsafe.Pointer(&info)))))
+}
+
+// Edit_GetSel returns the start and end of the selection in an edit control.
+func Edit_GetSel(edit w32.HWND) (start, end uint32) {
+	w32.SendMessage(
+		edit,
+		w32.EM_GETSEL,
+		uintptr(unsafe.Pointer(&start)),
+		uintptr(unsafe.Pointer(&end)),
+	)
+	return
+}
+
+func Edit_SetSel(edit w32.HWND, start, end uint32) {
+	w32.SendMessage(edit, w32.EM_SETSEL, uintptr(start), uintptr(end))
+}
+
+const (
+	ENM_CHANGE    = 0x0001
+	ENM_SCROLL    = 0x0004
+	ENM_SELCHANGE = 0x0008
+
+	EN_SELCHANGE = 0x0702
+
+	SCF_DEFAULT   = 0x0000
+	SCF_SELECTION = 0x0001
+
+	CFM_COLOR         = 0x40000000
+	CFM_ALL           = 0xF800003F
+	CFM_UNDERLINETYPE = 0x00800000
+	CFM_BACKCOLOR     = 0x04000000
+
+	CFE_AUTOCOLOR     = 0x40000000
+	CFE_AUTOBACKCOLOR = 0x04000000
+
+	CFU_UNDERLINENONE = 0
+	CFU_UNDERLINEWAVE = 8
+
+	// Underline colors of CHARFORMAT2, 0 is the text color.
+	UnderlineRed        = 6
+	UnderlineDarkYellow = 14
+)
+
+type CHARFORMAT struct {
+	Size           uint32
+	Mask           uint32
+	Effects        uint32
+	Height         int32
+	Offset         int32
+	TextColor      w32.COLORREF
+	CharSet        byte
+	PitchAndFamily byte
+	FaceName       [32]uint16
+}
+
+type CHARFORMAT2 struct {
+	Size           uint32
+	Mask           uint32
+	Effects        uint32
+	Height         int32
+	Offset         int32
+	TextColor      w32.COLORREF
+	CharSet        byte
+	PitchAndFamily byte
+	FaceName       [32]uint16
+	Weight         uint16
+	Spacing        int16
+	BackColor      w32.COLORREF
+	LCID           uint32
+	Cookie         uint32
+	Style          int16
+	Kerning        uint16
+	UnderlineType  byte
+	Animation      byte
+	RevAuthor      byte
+	UnderlineColor byte
+}
+
+type CHARRANGE struct {
+	Min int32
+	Max int32
+}
+
+func RichEdit_GetDefaultCharFormat(edit w32.HWND) CHARFORMAT {
+	f := CHARFORMAT{Mask: CFM_ALL}
+	f.Size = uint32(unsafe.Sizeof(f))
+	w32.SendMessage(edit, w32.EM_GETCHARFORMAT, SCF_DEFAULT, uintptr(unsafe.Pointer(&f)))
+	return f
+}
+
+// RichEdit_SetCharFormat formats the text from start to end.
+func RichEdit_SetCharFormat(edit w32.HWND, start, end int, f CHARFORMAT) {
+	f.Size = uint32(unsafe.Sizeof(f))
+	r := CHARRANGE{Min: int32(start), Max: int32(end)}
+	w32.SendMessage(edit, w32.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&r)))
+	w32.SendMessage(edit, w32.EM_SETCHARFORMAT, SCF_SELECTION, uintptr(unsafe.Pointer(&f)))
+}
+
+// RichEdit_SetUnderline underlines the text from start to end with one of the
+// CFU_ underline types in the given color.
+func RichEdit_SetUnderline(edit w32.HWND, start, end int, underline, color byte) {
+	var f CHARFORMAT2
+	f.Size = uint32(unsafe.Sizeof(f))
+	f.Mask = CFM_UNDERLINETYPE
+	f.UnderlineType = underline
+	f.UnderlineColor = color
+	r := CHARRANGE{Min: int32(start), Max: int32(end)}
+	w32.SendMessage(edit, w32.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&r)))
+	w32.SendMessage(edit, w32.EM_SETCHARFORMAT, SCF_SELECTION, uintptr(unsafe.Pointer(&f)))
+}
+
+// RichEdit_SetBackColor sets the background color of the text from start to
+// end. Color 0 resets it to the default background.
+func RichEdit_SetBackColor(edit w32.HWND, start, end int, color w32.COLORREF) {
+	var f CHARFORMAT2
+	f.Size = uint32(unsafe.Sizeof(f))
+	f.Mask = CFM_BACKCOLOR
+	f.BackColor = color
+	if color == 0 {
+		f.Effects = CFE_AUTOBACKCOLOR
+	}
+	r := CHARRANGE{Min: int32(start), Max: int32(end)}
+	w32.SendMessage(edit, w32.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&r)))
+	w32.SendMessage(edit, w32.EM_SETCHARFORMAT, SCF_SELECTION, uintptr(unsafe.Pointer(&f)))
+}
+
+// RichEdit_PosFromChar returns the client coordinates of the character at the
+// given index.
+func RichEdit_PosFromChar(edit w32.HWND, index int) w32.POINT {
+	var p w32.POINT
+	w32.SendMessage(edit, w32.EM_POSFROMCHAR, uintptr(unsafe.Pointer(&p)), uintptr(index))
+	return p
+}
+
+// RichEdit_CharFromPos returns the index of the character closest to the given
+// client coordinates.
+func RichEdit_CharFromPos(edit w32.HWND, p w32.POINT) int {
+	return int(w32.SendMessage(edit, w32.EM_CHARFROMPOS, 0, uintptr(unsafe.Pointer(&p))))
+}
+
+func RichEdit_GetSel(edit w32.HWND) CHARRANGE {
+	var r CHARRANGE
+	w32.SendMessage(edit, w32.EM_EXGETSEL, 0, uintptr(unsafe.Pointer(&r)))
+	return r
+}
+
+func RichEdit_SetSel(edit w32.HWND, r CHARRANGE) {
+	w32.SendMessage(edit, w32.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&r)))
+}
+
+func RichEdit_GetScrollPos(edit w32.HWND) w32.POINT {
+	var p w32.POINT
+	w32.SendMessage(edit, w32.EM_GETSCROLLPOS, 0, uintptr(unsafe.Pointer(&p)))
+	return p
+}
+
+func RichEdit_SetScrollPos(edit w32.HWND, p w32.POINT) {
+	w32.SendMessage(edit, w32.EM_SETSCROLLPOS, 0, uintptr(unsafe.Pointer(&p)))
+}
+
+type GUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// comObject is the memory layout of every COM interface, a pointer to its
+// table of methods.
+type comObject struct {
+	methods *[32]uintptr
+}
+
+// call calls the method with the given index in the object's method table.
+func (o *comObject) call(method int, args ...uintptr) uintptr {
+	args = append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)
+	ret, _, _ := syscall.SyscallN(o.methods[method], args...)
+	return ret
+}
+
+func (o *comObject) release() {
+	o.call(2)
+}
+
+var IID_ITextDocument = GUID{
+	0x8CC497C0, 0xA1DF, 0x11CE,
+	[8]byte{0x80, 0x98, 0x00, 0xAA, 0x00, 0x47, 0xBE, 0x5D},
+}
+
+// TextDocument is the ITextDocument COM interface of a rich edit control.
+type TextDocument struct {
+	object *comObject
+}
+
+// RichEdit_GetTextDocument returns the ITextDocument interface of a rich edit
+// control. It must be released after use.
+func RichEdit_GetTextDocument(edit w32.HWND) (TextDocument, bool) {
+	var ole *comObject
+	w32.SendMessage(edit, w32.EM_GETOLEINTERFACE, 0, uintptr(unsafe.Pointer(&ole)))
+	if ole == nil {
+		return TextDocument{}, false
+	}
+	defer ole.release()
+	var doc *comObject
+	const queryInterface = 0
+	if ole.call(
+		queryInterface,
+		uintptr(unsafe.Pointer(&IID_ITextDocument)),
+		uintptr(unsafe.Pointer(&doc)),
+	) != 0 || doc == nil {
+		return TextDocument{}, false
+	}
+	return TextDocument{object: doc}, true
+}
+
+func (d TextDocument) Release() {
+	d.object.release()
+}
+
+const (
+	tomSuspend = -9999995
+	tomResume  = -9999994
+)
+
+// SuspendUndo stops recording changes for undo until ResumeUndo is called.
+func (d TextDocument) SuspendUndo() {
+	d.undo(tomSuspend)
+}
+
+func (d TextDocument) ResumeUndo() {
+	d.undo(tomResume)
+}
+
+func (d TextDocument) undo(count int32) {
+	const undoMethod = 22
+	d.object.call(undoMethod, uintptr(count), 0)
+}
+
+const (
+	BM_GETCHECK   = 0x00F0
+	BM_SETCHECK   = 0x00F1
+	BST_UNCHECKED = 0
+	BST_CHECKED   = 1
+	BN_CLICKED    = 0
+)
+
+func Button_IsChecked(button w32.HWND) bool {
+	return w32.SendMessage(button, BM_GETCHECK, 0, 0) == BST_CHECKED
+}
+
+func Button_SetCheck(button w32.HWND, checked bool) {
+	state := uintptr(BST_UNCHECKED)
+	if checked {
+		state = BST_CHECKED
+	}
+	w32.SendMessage(button, BM_SETCHECK, state, 0)
+}
+
+const (
+	LBS_NOTIFY           = 0x0001
+	LBS_NOINTEGRALHEIGHT = 0x0100
+
+	LB_ADDSTRING           = 0x0180
+	LB_RESETCONTENT        = 0x0184
+	LB_SETCURSEL           = 0x0186
+	LB_GETCURSEL           = 0x0188
+	LB_GETCOUNT            = 0x018B
+	LB_GETITEMHEIGHT       = 0x01A1
+	LB_SETHORIZONTALEXTENT = 0x0194
+
+	LBN_DBLCLK = 2
+)
+
+func ListBox_AddString(list w32.HWND, text string) {
+	w32.SendMessage(list, LB_ADDSTRING, 0, uintptr(unsafe.Pointer(w32.String(text))))
+}
+
+func ListBox_ResetContent(list w32.HWND) {
+	w32.SendMessage(list, LB_RESETCONTENT, 0, 0)
+}
+
+func ListBox_GetCount(list w32.HWND) int {
+	return int(int32(w32.SendMessage(list, LB_GETCOUNT, 0, 0)))
+}
+
+// ListBox_GetCurSel returns the index of the selected item or -1 if no item is
+// selected.
+func ListBox_GetCurSel(list w32.HWND) int {
+	return int(int32(w32.SendMessage(list, LB_GETCURSEL, 0, 0)))
+}
+
+func ListBox_SetCurSel(list w32.HWND, index int) {
+	w32.SendMessage(list, LB_SETCURSEL, uintptr(index), 0)
+}
+
+func ListBox_GetItemHeight(list w32.HWND) int {
+	return int(int32(w32.SendMessage(list, LB_GETITEMHEIGHT, 0, 0)))
+}
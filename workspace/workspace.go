@@ -0,0 +1,228 @@
+// Package workspace manages go.work files that combine several projects into
+// one multi-module workspace, so projects can import each other's packages.
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/gool/paths"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// FileName is the name of workspace files.
+const FileName = "go.work"
+
+// defaultGoVersion is used for new workspaces if the module to add does not
+// declare a Go version.
+const defaultGoVersion = "1.19"
+
+// Find returns the path of the go.work file that applies to dir. It looks in
+// dir and its parent folders, but not above root. If there is no workspace,
+// Find returns "".
+func Find(dir, root string) string {
+	dir, root = filepath.Clean(dir), filepath.Clean(root)
+	for {
+		path := filepath.Join(dir, FileName)
+		if fileExists(path) {
+			return path
+		}
+		if paths.Same(dir, root) || !paths.IsInside(dir, root) {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Modules returns the absolute paths of all module folders that the workspace
+// file uses.
+func Modules(workPath string) ([]string, error) {
+	work, err := read(workPath)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, use := range work.Use {
+		dirs = append(dirs, absUse(workPath, use.Path))
+	}
+	return dirs, nil
+}
+
+// Contains reports whether the workspace file uses the module in moduleDir.
+func Contains(workPath, moduleDir string) bool {
+	dirs, err := Modules(workPath)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		if paths.Same(dir, moduleDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds the module in moduleDir to the workspace file, creating the file if
+// it does not exist yet. The module must have a go.mod file. The workspace's
+// Go version is raised to the module's Go version if necessary, otherwise the
+// go command refuses to use the workspace.
+func Add(workPath, moduleDir string) error {
+	mod, err := readMod(moduleDir)
+	if err != nil {
+		return err
+	}
+
+	var work *modfile.WorkFile
+	if fileExists(workPath) {
+		work, err = read(workPath)
+	} else {
+		work, err = modfile.ParseWork(workPath, nil, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	goVersion := defaultGoVersion
+	if work.Go != nil {
+		goVersion = work.Go.Version
+	}
+	if mod.Go != nil && semver.Compare("v"+mod.Go.Version, "v"+goVersion) > 0 {
+		goVersion = mod.Go.Version
+	}
+	if work.Go == nil || work.Go.Version != goVersion {
+		if err := work.AddGoStmt(goVersion); err != nil {
+			return err
+		}
+	}
+
+	for _, use := range work.Use {
+		if paths.Same(absUse(workPath, use.Path), moduleDir) {
+			return nil // Already part of the workspace.
+		}
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(workPath), moduleDir)
+	if err != nil {
+		return err
+	}
+	modulePath := ""
+	if mod.Module != nil {
+		modulePath = mod.Module.Mod.Path
+	}
+	if err := work.AddUse(useDir(rel), modulePath); err != nil {
+		return err
+	}
+
+	return write(workPath, work)
+}
+
+// Remove removes the module in moduleDir from the workspace file. If no modules
+// remain, the workspace file is deleted.
+func Remove(workPath, moduleDir string) error {
+	work, err := read(workPath)
+	if err != nil {
+		return err
+	}
+
+	for _, use := range work.Use {
+		if paths.Same(absUse(workPath, use.Path), moduleDir) {
+			if err := work.DropUse(use.Path); err != nil {
+				return err
+			}
+		}
+	}
+	work.Cleanup()
+
+	if len(work.Use) == 0 {
+		return os.Remove(workPath)
+	}
+	return write(workPath, work)
+}
+
+// Move updates the workspace file after the folder oldDir was renamed to
+// newDir. The modules in oldDir and its sub-folders are used at their new
+// place. If newDir is "", the folder was deleted and its modules are removed.
+func Move(workPath, oldDir, newDir string) error {
+	if newDir != "" && paths.Same(oldDir, newDir) {
+		// Only the case changed, which Windows ignores.
+		return nil
+	}
+	dirs, err := Modules(workPath)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if !paths.Same(dir, oldDir) && !paths.IsInside(dir, oldDir) {
+			continue
+		}
+		// Add the new place first, so the file is not deleted for being
+		// empty in between.
+		if newDir != "" {
+			rel, err := filepath.Rel(oldDir, dir)
+			if err != nil {
+				return err
+			}
+			if err := Add(workPath, filepath.Join(newDir, rel)); err != nil {
+				return err
+			}
+		}
+		if err := Remove(workPath, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func read(workPath string) (*modfile.WorkFile, error) {
+	data, err := os.ReadFile(workPath)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.ParseWork(workPath, data, nil)
+}
+
+func write(workPath string, work *modfile.WorkFile) error {
+	work.SortBlocks()
+	work.Cleanup()
+	return os.WriteFile(workPath, modfile.Format(work.Syntax), 0666)
+}
+
+func readMod(moduleDir string) (*modfile.File, error) {
+	modPath := filepath.Join(moduleDir, "go.mod")
+	data, err := os.ReadFile(modPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New("the project has no go.mod file")
+		}
+		return nil, err
+	}
+	return modfile.ParseLax(modPath, data, nil)
+}
+
+// useDir formats a relative folder the way "go work use" writes it.
+func useDir(rel string) string {
+	rel = filepath.ToSlash(rel)
+	if rel == "." || strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
+
+func absUse(workPath, use string) string {
+	if filepath.IsAbs(use) {
+		return filepath.Clean(use)
+	}
+	return filepath.Join(filepath.Dir(workPath), filepath.FromSlash(use))
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,156 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	group := filepath.Join(root, "group")
+	project := filepath.Join(group, "game")
+	os.MkdirAll(project, 0777)
+	os.MkdirAll(filepath.Join(root, "alone"), 0777)
+
+	if work := Find(project, root); work != "" {
+		t.Errorf("want no workspace but have %s", work)
+	}
+
+	writeFile(t, filepath.Join(filepath.Dir(root), FileName), "go 1.19\n")
+	defer os.Remove(filepath.Join(filepath.Dir(root), FileName))
+	if work := Find(project, root); work != "" {
+		t.Errorf("workspaces above root must be ignored, have %s", work)
+	}
+
+	rootWork := filepath.Join(root, FileName)
+	writeFile(t, rootWork, "go 1.19\n")
+	groupWork := filepath.Join(group, FileName)
+	writeFile(t, groupWork, "go 1.19\n")
+	for dir, want := range map[string]string{
+		project:                      groupWork,
+		group:                        groupWork,
+		filepath.Join(root, "alone"): rootWork,
+		root:                         rootWork,
+	} {
+		if got := Find(dir, root); got != want {
+			t.Errorf("%s: want %s but have %s", dir, want, got)
+		}
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "sub", "b")
+	writeFile(t, filepath.Join(a, "go.mod"), "module gool.local/a\n\ngo 1.18\n")
+	writeFile(t, filepath.Join(b, "go.mod"), "module gool.local/b\n\ngo 1.21\n")
+	noMod := filepath.Join(root, "c")
+	os.MkdirAll(noMod, 0777)
+
+	work := filepath.Join(root, FileName)
+	if err := Add(work, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(work, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(work, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(work, noMod); err == nil {
+		t.Error("projects without go.mod cannot be added")
+	}
+
+	data, _ := os.ReadFile(work)
+	text := string(data)
+	if !strings.Contains(text, "go 1.21") {
+		t.Errorf("the Go version must be raised to the module's, have\n%s", text)
+	}
+	if strings.Count(text, "./a") != 1 || !strings.Contains(text, "./sub/b") {
+		t.Errorf("unexpected use directives\n%s", text)
+	}
+
+	modules, err := Modules(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("want modules %v but have %v", want, modules)
+	}
+	if !Contains(work, a) || !Contains(work, strings.ToUpper(b)) || Contains(work, noMod) {
+		t.Error("Contains does not match the used modules")
+	}
+
+	if err := Remove(work, a); err != nil {
+		t.Fatal(err)
+	}
+	if Contains(work, a) || !Contains(work, b) {
+		t.Error("only a must be removed")
+	}
+	if err := Remove(work, b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(work); !os.IsNotExist(err) {
+		t.Error("empty workspaces must be deleted")
+	}
+}
+
+func TestMove(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "group", "b")
+	c := filepath.Join(root, "group", "c")
+	for _, dir := range []string{a, b, c} {
+		writeFile(t, filepath.Join(dir, "go.mod"), "module gool.local/"+filepath.Base(dir)+"\n")
+	}
+	work := filepath.Join(root, FileName)
+	for _, dir := range []string{a, b, c} {
+		if err := Add(work, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	group := filepath.Join(root, "group")
+	renamed := filepath.Join(root, "spiele")
+	if err := os.Rename(group, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := Move(work, group, renamed); err != nil {
+		t.Fatal(err)
+	}
+	modules, _ := Modules(work)
+	want := []string{a, filepath.Join(renamed, "b"), filepath.Join(renamed, "c")}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("want modules %v after renaming but have %v", want, modules)
+	}
+
+	if err := Move(work, a, strings.ToUpper(a)); err != nil || !Contains(work, a) {
+		t.Errorf("changing the case must keep the module, have %v", err)
+	}
+
+	if err := Move(work, renamed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if modules, _ := Modules(work); !reflect.DeepEqual(modules, []string{a}) {
+		t.Errorf("deleted modules must be removed, have %v", modules)
+	}
+	if err := Move(work, a, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(work); !os.IsNotExist(err) {
+		t.Error("the workspace must be deleted with its last module")
+	}
+}